@@ -0,0 +1,84 @@
+package crdt
+
+import (
+	"cmp"
+	"sync"
+	"time"
+)
+
+// Timestamp is a hybrid logical clock timestamp. Timestamps are totally ordered by
+// wall time, then logical counter, then node, so two replicas never produce equal
+// timestamps.
+type Timestamp struct {
+	Wall    int64  `json:"wall"`
+	Logical uint32 `json:"logical"`
+	Node    string `json:"node"`
+}
+
+// Compare returns -1, 0 or 1 depending on whether t is before, equal to or after o.
+func (t Timestamp) Compare(o Timestamp) int {
+	if c := cmp.Compare(t.Wall, o.Wall); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(t.Logical, o.Logical); c != 0 {
+		return c
+	}
+	return cmp.Compare(t.Node, o.Node)
+}
+
+// After returns true if t is after o.
+func (t Timestamp) After(o Timestamp) bool {
+	return t.Compare(o) > 0
+}
+
+// Clock is a hybrid logical clock for a single replica.
+type Clock struct {
+	mu   sync.Mutex
+	node string
+	now  func() time.Time
+	last Timestamp
+}
+
+// NewClock creates a clock for the given replica. If now is nil, time.Now is used.
+func NewClock(node string, now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+	return &Clock{
+		node: node,
+		now:  now,
+		last: Timestamp{Node: node},
+	}
+}
+
+// Now returns a timestamp that is after every timestamp previously returned or observed.
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixNano()
+	if wall > c.last.Wall {
+		c.last = Timestamp{Wall: wall, Node: c.node}
+	} else {
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	}
+	return c.last
+}
+
+// Observe advances the clock past a timestamp received from another replica.
+func (c *Clock) Observe(t Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := c.now().UnixNano()
+	switch {
+	case wall > c.last.Wall && wall > t.Wall:
+		c.last = Timestamp{Wall: wall, Node: c.node}
+	case t.Wall > c.last.Wall:
+		c.last = Timestamp{Wall: t.Wall, Logical: t.Logical + 1, Node: c.node}
+	case c.last.Wall > t.Wall:
+		c.last = Timestamp{Wall: c.last.Wall, Logical: c.last.Logical + 1, Node: c.node}
+	default:
+		c.last = Timestamp{Wall: c.last.Wall, Logical: max(c.last.Logical, t.Logical) + 1, Node: c.node}
+	}
+}
@@ -0,0 +1,35 @@
+package crdt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockNow(t *testing.T) {
+	now := time.Unix(100, 0)
+	c := NewClock("a", func() time.Time { return now })
+
+	first := c.Now()
+	second := c.Now()
+	if !second.After(first) {
+		t.Fatalf("Now() = %v, want after %v", second, first)
+	}
+	if second.Wall != first.Wall || second.Logical != first.Logical+1 {
+		t.Fatalf("Now() = %v, want logical increment of %v", second, first)
+	}
+}
+
+func TestClockObserve(t *testing.T) {
+	c := NewClock("a", func() time.Time { return time.Unix(100, 0) })
+
+	remote := Timestamp{Wall: time.Unix(200, 0).UnixNano(), Logical: 3, Node: "b"}
+	c.Observe(remote)
+
+	got := c.Now()
+	if !got.After(remote) {
+		t.Fatalf("Now() = %v, want after observed %v", got, remote)
+	}
+	if got.Node != "a" {
+		t.Fatalf("Now().Node = %q, want %q", got.Node, "a")
+	}
+}
@@ -0,0 +1,461 @@
+package crdt
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrCounterField    = errors.New("field is a counter")
+	ErrNotCounterField = errors.New("field is not a counter")
+)
+
+// BaseNode is the node the values of counter fields loaded by FromFeature are recorded
+// under. Replicas loaded from the same feature record the same base, so merging them
+// counts it once. Replicas must not use it as their own node.
+const BaseNode = "_base"
+
+// Document is a conflict-free replicated representation of a feature.
+// Every top-level field is a last-writer-wins register, list fields are observed-remove
+// sets and fields marked as counters in the schema are positive-negative counters.
+// Replicas that have merged the same updates produce the same feature, regardless of
+// the order the updates arrived in.
+//
+// Nested objects are replaced as a whole, and list fields do not keep duplicates.
+// Elements of list fields are ordered by the time they were first added.
+type Document struct {
+	mu     sync.Mutex
+	schema feature.Schema
+	intro  *feature.SchemaIntrospector
+	clock  *Clock
+	node   string
+
+	// seq is a local sequence number that is bumped on every change, including merged
+	// ones. It is used to compute deltas and is never sent to other replicas.
+	seq       uint64
+	registers map[string]*register
+	sets      map[string]*orSet
+	counters  map[string]map[string]*counterEntry
+}
+
+type register struct {
+	Register
+	seq uint64
+}
+
+type orSet struct {
+	time    Timestamp
+	deleted bool
+	seq     uint64
+	adds    map[Timestamp]*element
+	removes map[Timestamp]uint64
+}
+
+type element struct {
+	Element
+	seq uint64
+}
+
+type counterEntry struct {
+	CounterEntry
+	seq uint64
+}
+
+// Option configures a document.
+type Option func(*Document)
+
+// WithClock sets the function used to read the wall time of the hybrid logical clock.
+func WithClock(now func() time.Time) Option {
+	return func(d *Document) {
+		d.clock = NewClock(d.node, now)
+	}
+}
+
+// New creates an empty document for the replica identified by node.
+func New(sch feature.Schema, node string, opts ...Option) *Document {
+	d := &Document{
+		schema:    sch,
+		intro:     feature.NewSchemaIntrospector(sch),
+		clock:     NewClock(node, nil),
+		node:      node,
+		registers: make(map[string]*register),
+		sets:      make(map[string]*orSet),
+		counters:  make(map[string]map[string]*counterEntry),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// FromFeature creates a document for the replica identified by node, holding the values of f.
+// Counter fields start at their value in f, which is shared by every replica loaded from
+// the same feature.
+func FromFeature(f *feature.Feature, node string, opts ...Option) (*Document, error) {
+	d := New(f.Schema(), node, opts...)
+	m := f.Map()
+	for _, k := range m.Keys() {
+		v, _ := m.Get(k)
+		if d.isCounter(k) {
+			n, err := m.GetInt(k)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", k, err)
+			}
+			d.seedCounter(k, n)
+			continue
+		}
+		if err := d.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *Document) isCounter(field string) bool {
+	f, err := d.intro.GetField(field)
+	return err == nil && f.Counter()
+}
+
+func (d *Document) next() uint64 {
+	d.seq++
+	return d.seq
+}
+
+// Set writes a field. Lists are stored as sets, every other value replaces the previous one.
+func (d *Document) Set(field string, value any) error {
+	if d.isCounter(field) {
+		return fmt.Errorf("%w: %s", ErrCounterField, field)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	if list, ok := value.([]any); ok {
+		return d.setList(field, list, now)
+	}
+
+	raw, err := encodeValue(value)
+	if err != nil {
+		return fmt.Errorf("field %q: %w", field, err)
+	}
+	d.registers[field] = &register{Register: Register{Value: raw, Time: now}, seq: d.next()}
+	return nil
+}
+
+func (d *Document) setList(field string, list []any, now Timestamp) error {
+	s := d.set(field)
+	s.time, s.deleted, s.seq = now, false, d.next()
+
+	wanted := make(map[string]bool, len(list))
+	for _, v := range list {
+		raw, err := encodeValue(v)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", field, err)
+		}
+		wanted[string(raw)] = true
+	}
+
+	present := make(map[string]bool)
+	for tag, e := range s.adds {
+		if _, removed := s.removes[tag]; removed {
+			continue
+		}
+		if wanted[string(e.Value)] {
+			present[string(e.Value)] = true
+			continue
+		}
+		s.removes[tag] = d.next()
+	}
+
+	for _, v := range list {
+		raw, _ := encodeValue(v)
+		if present[string(raw)] {
+			continue
+		}
+		present[string(raw)] = true
+		tag := d.clock.Now()
+		s.adds[tag] = &element{Element: Element{Value: raw, Tag: tag}, seq: d.next()}
+	}
+	return nil
+}
+
+func (d *Document) set(field string) *orSet {
+	s, ok := d.sets[field]
+	if !ok {
+		s = &orSet{
+			adds:    make(map[Timestamp]*element),
+			removes: make(map[Timestamp]uint64),
+		}
+		d.sets[field] = s
+	}
+	return s
+}
+
+// Delete removes a field. Counters cannot be deleted.
+func (d *Document) Delete(field string) error {
+	if d.isCounter(field) {
+		return fmt.Errorf("%w: %s", ErrCounterField, field)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.clock.Now()
+	d.registers[field] = &register{Register: Register{Deleted: true, Time: now}, seq: d.next()}
+	if s, ok := d.sets[field]; ok {
+		s.time, s.deleted, s.seq = now, true, d.next()
+	}
+	return nil
+}
+
+// seedCounter records the loaded value of a counter field under BaseNode.
+func (d *Document) seedCounter(field string, n int64) {
+	e := &counterEntry{seq: d.next()}
+	if n >= 0 {
+		e.P = n
+	} else {
+		e.N = -n
+	}
+	d.counters[field] = map[string]*counterEntry{BaseNode: e}
+}
+
+// Increment adds delta, which may be negative, to a counter field.
+func (d *Document) Increment(field string, delta int64) error {
+	if !d.isCounter(field) {
+		return fmt.Errorf("%w: %s", ErrNotCounterField, field)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries, ok := d.counters[field]
+	if !ok {
+		entries = make(map[string]*counterEntry)
+		d.counters[field] = entries
+	}
+	e, ok := entries[d.node]
+	if !ok {
+		e = &counterEntry{}
+		entries[d.node] = e
+	}
+	if delta >= 0 {
+		e.P += delta
+	} else {
+		e.N -= delta
+	}
+	e.seq = d.next()
+	return nil
+}
+
+// Merge merges a state or delta received from another replica into the document.
+func (d *Document) Merge(s State) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for field, r := range s.Registers {
+		d.clock.Observe(r.Time)
+		if local, ok := d.registers[field]; ok && !r.Time.After(local.Time) {
+			continue
+		}
+		d.registers[field] = &register{Register: r, seq: d.next()}
+	}
+
+	for field, remote := range s.Sets {
+		local := d.set(field)
+		if remote.Time.After(local.time) {
+			d.clock.Observe(remote.Time)
+			local.time, local.deleted, local.seq = remote.Time, remote.Deleted, d.next()
+		}
+		for _, e := range remote.Adds {
+			if _, ok := local.adds[e.Tag]; !ok {
+				d.clock.Observe(e.Tag)
+				local.adds[e.Tag] = &element{Element: e, seq: d.next()}
+			}
+		}
+		for _, tag := range remote.Removes {
+			if _, ok := local.removes[tag]; !ok {
+				local.removes[tag] = d.next()
+			}
+		}
+	}
+
+	for field, remote := range s.Counters {
+		entries, ok := d.counters[field]
+		if !ok {
+			entries = make(map[string]*counterEntry)
+			d.counters[field] = entries
+		}
+		for node, re := range remote {
+			e, ok := entries[node]
+			if !ok {
+				e = &counterEntry{}
+				entries[node] = e
+			}
+			if re.P > e.P || re.N > e.N {
+				e.P, e.N = max(e.P, re.P), max(e.N, re.N)
+				e.seq = d.next()
+			}
+		}
+	}
+}
+
+// MergeDocument merges the full state of another document into d.
+func (d *Document) MergeDocument(other *Document) {
+	s, _ := other.Delta(0)
+	d.Merge(s)
+}
+
+// Delta returns every change the document has seen after the sequence number since,
+// together with the current sequence number. Pass the returned sequence number to the
+// next call to only get newer changes. Delta(0) returns the full state.
+func (d *Document) Delta(since uint64) (State, uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := State{
+		Registers: make(map[string]Register),
+		Sets:      make(map[string]Set),
+		Counters:  make(map[string]Counter),
+	}
+
+	for field, r := range d.registers {
+		if r.seq > since {
+			s.Registers[field] = r.Register
+		}
+	}
+
+	for field, set := range d.sets {
+		delta := Set{Time: set.time, Deleted: set.deleted}
+		changed := set.seq > since
+		for _, e := range set.adds {
+			if e.seq > since {
+				delta.Adds = append(delta.Adds, e.Element)
+			}
+		}
+		for tag, seq := range set.removes {
+			if seq > since {
+				delta.Removes = append(delta.Removes, tag)
+			}
+		}
+		if changed || len(delta.Adds) > 0 || len(delta.Removes) > 0 {
+			slices.SortFunc(delta.Adds, func(a, b Element) int { return a.Tag.Compare(b.Tag) })
+			slices.SortFunc(delta.Removes, Timestamp.Compare)
+			s.Sets[field] = delta
+		}
+	}
+
+	for field, entries := range d.counters {
+		c := make(Counter)
+		for node, e := range entries {
+			if e.seq > since {
+				c[node] = e.CounterEntry
+			}
+		}
+		if len(c) > 0 {
+			s.Counters[field] = c
+		}
+	}
+
+	return s, d.seq
+}
+
+// Feature converts the document back to a plain feature.
+func (d *Document) Feature() (*feature.Feature, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	m := jsonchamp.New()
+
+	for _, field := range d.fields() {
+		r, hasRegister := d.registers[field]
+		s, hasSet := d.sets[field]
+
+		if hasSet && (!hasRegister || s.time.After(r.Time)) {
+			if s.deleted {
+				continue
+			}
+			list, err := s.values()
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", field, err)
+			}
+			m = m.Set(field, list)
+			continue
+		}
+
+		if hasRegister && !r.Deleted {
+			v, err := decodeValue(r.Value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", field, err)
+			}
+			m = m.Set(field, v)
+		}
+	}
+
+	for field, entries := range d.counters {
+		var total int64
+		for _, e := range entries {
+			total += e.P - e.N
+		}
+		m = m.Set(field, total)
+	}
+
+	return feature.New(d.schema, feature.WithMap(m), feature.WithSchemaVersion(len(d.schema.Migrations))), nil
+}
+
+func (d *Document) fields() []string {
+	var fields []string
+	for field := range d.registers {
+		fields = append(fields, field)
+	}
+	for field := range d.sets {
+		if _, ok := d.registers[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// values returns the elements of the set that have not been removed, ordered by the
+// tag of their first add.
+func (s *orSet) values() ([]any, error) {
+	first := make(map[string]Timestamp)
+	raws := make(map[string][]byte)
+	for tag, e := range s.adds {
+		if _, removed := s.removes[tag]; removed {
+			continue
+		}
+		key := string(e.Value)
+		if t, ok := first[key]; !ok || tag.Compare(t) < 0 {
+			first[key] = tag
+			raws[key] = e.Value
+		}
+	}
+
+	keys := make([]string, 0, len(first))
+	for key := range first {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(first[a].Compare(first[b]), cmp.Compare(a, b))
+	})
+
+	list := make([]any, 0, len(keys))
+	for _, key := range keys {
+		v, err := decodeValue(raws[key])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
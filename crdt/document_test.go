@@ -0,0 +1,209 @@
+package crdt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+var testSchema = feature.Schema{
+	Migrations: feature.Migrations{
+		{Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString}},
+			feature.AddField{Field: feature.Field{Name: "views", Type: feature.FieldTypeInteger, Counter: true}},
+		}},
+	},
+}
+
+func mustFeature(t *testing.T, d *Document) *jsonchamp.Map {
+	t.Helper()
+	f, err := d.Feature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Map()
+}
+
+func TestDocumentConverges(t *testing.T) {
+	a := New(testSchema, "a")
+	b := New(testSchema, "b")
+
+	if err := a.Set("status", "open"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set("tags", []any{"x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Increment("views", 2); err != nil {
+		t.Fatal(err)
+	}
+	b.MergeDocument(a)
+
+	// Concurrent updates on both replicas.
+	if err := a.Set("status", "closed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set("tags", []any{"x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set("tags", []any{"x", "y", "z"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Increment("views", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Increment("views", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	aState, _ := a.Delta(0)
+	bState, _ := b.Delta(0)
+
+	// Merge in different orders, and more than once.
+	c := New(testSchema, "c")
+	c.Merge(aState)
+	c.Merge(bState)
+	d := New(testSchema, "d")
+	d.Merge(bState)
+	d.Merge(aState)
+	d.Merge(bState)
+	a.Merge(bState)
+	b.Merge(aState)
+
+	want := mustFeature(t, c)
+	for name, doc := range map[string]*Document{"a": a, "b": b, "d": d} {
+		if got := mustFeature(t, doc); !got.Equals(want) {
+			t.Fatalf("replica %s = %v, want %v", name, got, want)
+		}
+	}
+
+	views, err := want.GetInt("views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if views != 6 {
+		t.Fatalf("views = %d, want 6", views)
+	}
+	tags, _ := want.Get("tags")
+	// "y" was removed by a, "z" was added concurrently by b.
+	if !jsonchamp.NewFromItems("t", tags).Equals(jsonchamp.NewFromItems("t", []any{"x", "z"})) {
+		t.Fatalf("tags = %v, want [x z]", tags)
+	}
+}
+
+func TestDocumentDelta(t *testing.T) {
+	a := New(testSchema, "a")
+	b := New(testSchema, "b")
+
+	if err := a.Set("status", "open"); err != nil {
+		t.Fatal(err)
+	}
+	delta, seq := a.Delta(0)
+	b.Merge(delta)
+
+	if err := a.Set("priority", 1); err != nil {
+		t.Fatal(err)
+	}
+	delta, _ = a.Delta(seq)
+	if len(delta.Registers) != 1 {
+		t.Fatalf("Delta() registers = %v, want only the new change", delta.Registers)
+	}
+
+	// Deltas survive encoding.
+	js, err := json.Marshal(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded State
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	b.Merge(decoded)
+
+	if got, want := mustFeature(t, b), mustFeature(t, a); !got.Equals(want) {
+		t.Fatalf("Feature() = %v, want %v", got, want)
+	}
+}
+
+func TestDocumentDeleteWins(t *testing.T) {
+	a := New(testSchema, "a")
+	if err := a.Set("status", "open"); err != nil {
+		t.Fatal(err)
+	}
+	b := New(testSchema, "b")
+	b.MergeDocument(a)
+
+	if err := b.Delete("status"); err != nil {
+		t.Fatal(err)
+	}
+	a.MergeDocument(b)
+
+	if got := mustFeature(t, a); got.Contains("status") {
+		t.Fatalf("Feature() = %v, want status deleted", got)
+	}
+}
+
+func TestDocumentCounterFields(t *testing.T) {
+	d := New(testSchema, "a")
+	if err := d.Set("views", 1); err == nil {
+		t.Fatal("Set() on counter field should fail")
+	}
+	if err := d.Increment("status", 1); err == nil {
+		t.Fatal("Increment() on non-counter field should fail")
+	}
+}
+
+func TestFromFeature(t *testing.T) {
+	m := jsonchamp.NewFromItems(
+		"status", "open",
+		"views", 4,
+		"total", 12.5,
+		"address", jsonchamp.NewFromItems("city", "Oslo"),
+		"tags", []any{"a", "b"},
+	)
+	d, err := FromFeature(feature.New(testSchema, feature.WithMap(m)), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mustFeature(t, d); !got.Equals(m) {
+		t.Fatalf("Feature() = %v, want %v", got, m)
+	}
+}
+
+func TestFromFeatureCountersMergeOnce(t *testing.T) {
+	f := feature.New(testSchema, feature.WithMap(jsonchamp.NewFromItems("views", 10)))
+	a, err := FromFeature(f, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := FromFeature(f, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.MergeDocument(b)
+	b.MergeDocument(a)
+	for name, d := range map[string]*Document{"a": a, "b": b} {
+		if got, _ := mustFeature(t, d).GetInt("views"); got != 10 {
+			t.Fatalf("views on %s = %d after merging, want 10", name, got)
+		}
+	}
+
+	if err := a.Increment("views", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Increment("views", -1); err != nil {
+		t.Fatal(err)
+	}
+	a.MergeDocument(b)
+	b.MergeDocument(a)
+	for name, d := range map[string]*Document{"a": a, "b": b} {
+		if got, _ := mustFeature(t, d).GetInt("views"); got != 11 {
+			t.Fatalf("views on %s = %d after merging increments, want 11", name, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package crdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+// State is the replicated state of a document, or a delta of it. States are plain
+// data and can be encoded as JSON to be sent between replicas.
+type State struct {
+	Registers map[string]Register `json:"registers,omitempty"`
+	Sets      map[string]Set      `json:"sets,omitempty"`
+	Counters  map[string]Counter  `json:"counters,omitempty"`
+}
+
+// Register is a last-writer-wins register holding the value of a single field.
+type Register struct {
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Time    Timestamp       `json:"time"`
+}
+
+// Set is an observed-remove set holding the elements of a list field.
+// Time and Deleted record the last time the whole field was written or deleted.
+type Set struct {
+	Time    Timestamp   `json:"time"`
+	Deleted bool        `json:"deleted,omitempty"`
+	Adds    []Element   `json:"adds,omitempty"`
+	Removes []Timestamp `json:"removes,omitempty"`
+}
+
+// Element is a value added to a set, tagged with the timestamp of the add.
+type Element struct {
+	Value json.RawMessage `json:"value"`
+	Tag   Timestamp       `json:"tag"`
+}
+
+// Counter is a positive-negative counter with the increments and decrements of each replica.
+type Counter map[string]CounterEntry
+
+// CounterEntry holds the total increments and decrements made by a single replica.
+type CounterEntry struct {
+	P int64 `json:"p"`
+	N int64 `json:"n"`
+}
+
+// encodeValue encodes a value as canonical JSON, with map keys in sorted order,
+// so that equal values always have the same encoding.
+func encodeValue(v any) (json.RawMessage, error) {
+	buf := &bytes.Buffer{}
+	if err := writeValue(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeValue(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case *jsonchamp.Map:
+		keys := v.Keys()
+		slices.Sort(keys)
+		buf.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			key, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteString(":")
+			child, _ := v.Get(k)
+			if err := writeValue(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case []any:
+		buf.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case float64:
+		// Keep a decimal point so the value decodes as a float again.
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	default:
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(js)
+	}
+	return nil
+}
+
+// decodeValue decodes a value encoded by encodeValue into the types used by jsonchamp.
+func decodeValue(raw json.RawMessage) (any, error) {
+	wrapped := make([]byte, 0, len(raw)+6)
+	wrapped = append(wrapped, `{"v":`...)
+	wrapped = append(wrapped, raw...)
+	wrapped = append(wrapped, '}')
+
+	m := jsonchamp.New()
+	if err := json.Unmarshal(wrapped, &m); err != nil {
+		return nil, err
+	}
+	v, _ := m.Get("v")
+	return v, nil
+}
@@ -0,0 +1,91 @@
+package ddl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/features/query"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// MigrationsTable returns the statement creating the table that records the applied
+// migrations of every schema, unless it exists.
+func MigrationsTable(d Dialect, opts ...Option) string {
+	g := applyOptions(opts)
+	timestamp := "TIMESTAMP"
+	if d == PostgreSQL {
+		timestamp = "TIMESTAMPTZ"
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  "schema_urn" TEXT NOT NULL,
+  "version" INTEGER NOT NULL,
+  "description" TEXT NOT NULL,
+  "checksum" TEXT NOT NULL,
+  "applied_at" %s NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("schema_urn", "version")
+)`, query.QuoteIdentifier(g.migrationsTable), timestamp)
+}
+
+// Record returns the statement recording the migration as applied.
+func (m Migration) Record(opts ...Option) string {
+	g := applyOptions(opts)
+	return fmt.Sprintf(`INSERT INTO %s ("schema_urn", "version", "description", "checksum") VALUES (%s, %d, %s, %s)`,
+		query.QuoteIdentifier(g.migrationsTable), quote(m.Schema), m.Version, quote(m.Description), quote(m.Checksum))
+}
+
+// Pending returns the migrations that have not been applied yet, given the checksums of
+// the applied ones by version, as read from the migrations table. It fails with
+// ErrChecksumMismatch if an applied migration has changed since, and with
+// ErrUnknownMigration if a version was applied that is not among the migrations.
+func Pending(migrations []Migration, applied map[int]string) ([]Migration, error) {
+	var pending []Migration
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		sum, ok := applied[m.Version]
+		switch {
+		case !ok:
+			pending = append(pending, m)
+		case sum != m.Checksum:
+			return nil, fmt.Errorf("%w: version %d of %q was applied with checksum %s, but is now %s", ErrChecksumMismatch, m.Version, m.Schema, sum, m.Checksum)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: version %d was applied", ErrUnknownMigration, version)
+		}
+	}
+	slices.SortFunc(pending, func(a, b Migration) int { return a.Version - b.Version })
+	return pending, nil
+}
+
+// Script returns a SQL script applying the migrations in one transaction, creating the
+// migrations table if needed and recording every migration in it.
+func Script(migrations []Migration, opts ...Option) string {
+	if len(migrations) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN;\n\n")
+	b.WriteString(MigrationsTable(migrations[0].Dialect, opts...) + ";\n")
+	for _, m := range migrations {
+		b.WriteString("\n-- " + m.Schema + " version " + strconv.Itoa(m.Version))
+		if m.Description != "" {
+			b.WriteString(": " + strings.ReplaceAll(m.Description, "\n", " "))
+		}
+		b.WriteString("\n")
+		for _, s := range m.Statements {
+			b.WriteString(s + ";\n")
+		}
+		b.WriteString(m.Record(opts...) + ";\n")
+	}
+	b.WriteString("\nCOMMIT;\n")
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package ddl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPending(t *testing.T) {
+	migrations, err := Migrations(orderSchema, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := Pending(migrations, map[int]string{1: migrations[0].Checksum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].Version != 2 || pending[1].Version != 3 {
+		t.Fatalf("expected versions 2 and 3 to be pending, got %+v", pending)
+	}
+
+	if _, err := Pending(migrations, map[int]string{1: "changed"}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := Pending(migrations, map[int]string{4: "future"}); !errors.Is(err, ErrUnknownMigration) {
+		t.Fatalf("expected ErrUnknownMigration, got %v", err)
+	}
+
+	postgres, err := Migrations(orderSchema, PostgreSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if postgres[2].Checksum == migrations[2].Checksum {
+		t.Fatalf("expected the checksums of different statements to differ")
+	}
+}
+
+func TestScript(t *testing.T) {
+	migrations, err := Migrations(orderSchema, PostgreSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := Script(migrations[1:], WithMigrationsTable("applied"))
+
+	for _, want := range []string{
+		"BEGIN;\n\nCREATE TABLE IF NOT EXISTS \"applied\" (",
+		"\"applied_at\" TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,",
+		"\n-- urn:features:order_line version 2: Drop note, add tags\nALTER TABLE \"order_line\" DROP COLUMN \"note\";\n",
+		"INSERT INTO \"applied\" (\"schema_urn\", \"version\", \"description\", \"checksum\") VALUES ('urn:features:order_line', 3, 'Count is a number', '" + migrations[2].Checksum + "');\n",
+		"\nCOMMIT;\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain\n%s\ngot\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "version 1") {
+		t.Errorf("expected only the given migrations in the script, got\n%s", script)
+	}
+	if Script(nil) != "" {
+		t.Errorf("expected no script without migrations")
+	}
+}
@@ -0,0 +1,506 @@
+package ddl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+)
+
+var (
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// Dialect is the SQL dialect statements are written in.
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	PostgreSQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case PostgreSQL:
+		return "postgresql"
+	default:
+		return "dialect(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+type generator struct {
+	table           string
+	keyColumn       string
+	migrationsTable string
+}
+
+type Option func(*generator)
+
+// WithTable sets the name of the table holding the features. It defaults to the last
+// part of the schema URN, so features of urn:features:order_line go to order_line.
+func WithTable(name string) Option {
+	return func(g *generator) {
+		g.table = name
+	}
+}
+
+// WithKeyColumn sets the name of the primary key column holding the feature key. It
+// defaults to "key".
+func WithKeyColumn(name string) Option {
+	return func(g *generator) {
+		g.keyColumn = name
+	}
+}
+
+// WithMigrationsTable sets the name of the table recording applied migrations. It
+// defaults to "feature_migrations".
+func WithMigrationsTable(name string) Option {
+	return func(g *generator) {
+		g.migrationsTable = name
+	}
+}
+
+func applyOptions(opts []Option) *generator {
+	g := &generator{
+		keyColumn:       "key",
+		migrationsTable: "feature_migrations",
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+func newGenerator(urn string, opts []Option) (*generator, error) {
+	g := applyOptions(opts)
+	if g.table == "" {
+		g.table = urn[strings.LastIndexByte(urn, ':')+1:]
+	}
+	if g.table == "" {
+		return nil, fmt.Errorf("%w: no table name for URN %q", ErrInvalidSchema, urn)
+	}
+	return g, nil
+}
+
+// Migration holds the statements of one feature.Migration.
+type Migration struct {
+	Schema      string
+	Dialect     Dialect
+	Version     int
+	Description string
+	Statements  []string
+	// Checksum is the SHA-256 of the statements, in hex. It is recorded in the migrations
+	// table when the migration is applied, to notice migrations changed afterwards.
+	Checksum string
+}
+
+// CreateTable returns the statements creating the table of the schema at its latest
+// version, with a column per field of the reduced schema and an index per index of
+// the schema.
+func CreateTable(sch feature.Schema, d Dialect, opts ...Option) ([]string, error) {
+	g, err := newGenerator(sch.Schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(sch); err != nil {
+		return nil, err
+	}
+	indexes, err := sch.Indexes()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrInvalidSchema, sch.Schema, err)
+	}
+	t := &table{}
+	for _, f := range feature.NewSchemaIntrospector(sch).Fields() {
+		t.fields = append(t.fields, f.Field())
+	}
+	for _, name := range slices.Sorted(maps.Keys(indexes)) {
+		t.indexes = append(t.indexes, indexes[name])
+	}
+	statements, err := g.create(d, g.table, t)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrInvalidSchema, sch.Schema, err)
+	}
+	return statements, nil
+}
+
+// Migrations returns the statements of every migration of the schema. The first
+// migration creates the table, and later ones alter it: AddField adds a column with the
+// default of the field, RemoveField drops the column and AlterField changes its type,
+// default and nullability. SQLite can not alter columns, so there the table is copied
+// into a new one instead.
+func Migrations(sch feature.Schema, d Dialect, opts ...Option) ([]Migration, error) {
+	g, err := newGenerator(sch.Schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(sch); err != nil {
+		return nil, err
+	}
+	t := &table{}
+	migrations := make([]Migration, len(sch.Migrations))
+	for i, migration := range sch.Migrations {
+		var statements []string
+		if i == 0 {
+			for _, op := range migration.Operations {
+				if err = t.apply(op); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				statements, err = g.create(d, g.table, t)
+			}
+		} else {
+			for _, op := range migration.Operations {
+				var s []string
+				s, err = g.alter(d, t, op)
+				if err != nil {
+					break
+				}
+				statements = append(statements, s...)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q version %d: %w", ErrInvalidSchema, sch.Schema, i+1, err)
+		}
+		migrations[i] = Migration{
+			Schema:      sch.Schema,
+			Dialect:     d,
+			Version:     i + 1,
+			Description: migration.Description,
+			Statements:  statements,
+			Checksum:    checksum(statements),
+		}
+	}
+	return migrations, nil
+}
+
+// validate checks every version of the schema with Schema.Indexes, which reduces the
+// fields and indexes of the version the way the feature package does.
+func validate(sch feature.Schema) error {
+	for i := range sch.Migrations {
+		version := sch
+		version.Migrations = sch.Migrations[:i+1]
+		if _, err := version.Indexes(); err != nil {
+			return fmt.Errorf("%w: %q version %d: %w", ErrInvalidSchema, sch.Schema, i+1, err)
+		}
+	}
+	return nil
+}
+
+func checksum(statements []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(statements, ";\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// table tracks the columns and indexes of a table through migrations that have been
+// validated. Within a migration, a column can only be dropped after the indexes using
+// it, which the databases would otherwise refuse or drop along with it.
+type table struct {
+	fields  []feature.Field
+	indexes []feature.Index
+}
+
+func (t *table) field(name string) int {
+	return slices.IndexFunc(t.fields, func(f feature.Field) bool { return f.Name == name })
+}
+
+func (t *table) index(name string) int {
+	return slices.IndexFunc(t.indexes, func(idx feature.Index) bool { return idx.Name == name })
+}
+
+func (t *table) apply(op feature.Operation) error {
+	switch op := op.(type) {
+	case feature.AddField:
+		t.fields = append(t.fields, op.Field)
+	case feature.AlterField:
+		if i := t.field(op.Field.Name); i >= 0 {
+			t.fields[i] = op.Field
+		}
+	case feature.RemoveField:
+		for _, idx := range t.indexes {
+			if slices.Contains(idx.Properties(), op.FieldName) {
+				return fmt.Errorf("%w: field '%s' is used by index '%s', which must be removed first", feature.ErrInvalidIndex, op.FieldName, idx.Name)
+			}
+		}
+		if i := t.field(op.FieldName); i >= 0 {
+			t.fields = slices.Delete(t.fields, i, i+1)
+		}
+	case feature.AddIndex:
+		t.indexes = append(t.indexes, op.Index)
+	case feature.RemoveIndex:
+		if i := t.index(op.Name); i >= 0 {
+			t.indexes = slices.Delete(t.indexes, i, i+1)
+		}
+	}
+	return nil
+}
+
+// create returns the statements creating a table with the columns and indexes of t.
+func (g *generator) create(d Dialect, name string, t *table) ([]string, error) {
+	columns := []string{query.QuoteIdentifier(g.keyColumn) + " TEXT PRIMARY KEY"}
+	for _, f := range t.fields {
+		if f.Name == g.keyColumn {
+			return nil, fmt.Errorf("field '%s' has the name of the key column", f.Name)
+		}
+		column, err := columnDefinition(d, f)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", query.QuoteIdentifier(name), strings.Join(columns, ",\n  "))}
+	for _, idx := range t.indexes {
+		if s, ok := g.createIndex(idx); ok {
+			statements = append(statements, s)
+		}
+	}
+	return statements, nil
+}
+
+// alter applies the operation to t and returns the statements altering the table.
+func (g *generator) alter(d Dialect, t *table, op feature.Operation) ([]string, error) {
+	var previous feature.Field
+	if op, ok := op.(feature.AlterField); ok {
+		if i := t.field(op.Field.Name); i >= 0 {
+			previous = t.fields[i]
+		}
+	}
+	var removed feature.Index
+	if op, ok := op.(feature.RemoveIndex); ok {
+		if i := t.index(op.Name); i >= 0 {
+			removed = t.indexes[i]
+		}
+	}
+	if err := t.apply(op); err != nil {
+		return nil, err
+	}
+
+	tableName := query.QuoteIdentifier(g.table)
+	switch op := op.(type) {
+	case feature.AddField:
+		if op.Field.Name == g.keyColumn {
+			return nil, fmt.Errorf("field '%s' has the name of the key column", op.Field.Name)
+		}
+		column, err := columnDefinition(d, op.Field)
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, column)}, nil
+	case feature.RemoveField:
+		return []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, query.QuoteIdentifier(op.FieldName))}, nil
+	case feature.AlterField:
+		if d == SQLite {
+			return g.rebuild(t, op.Field)
+		}
+		return alterColumn(tableName, previous, op.Field)
+	case feature.AddIndex:
+		if s, ok := g.createIndex(op.Index); ok {
+			return []string{s}, nil
+		}
+		return nil, nil
+	case feature.RemoveIndex:
+		if _, ok := g.createIndex(removed); ok {
+			return []string{"DROP INDEX " + g.indexName(removed)}, nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// alterColumn returns the PostgreSQL statements changing a column to the field.
+func alterColumn(tableName string, previous, f feature.Field) ([]string, error) {
+	typ, err := columnType(PostgreSQL, f)
+	if err != nil {
+		return nil, err
+	}
+	column := query.QuoteIdentifier(f.Name)
+	actions := []string{fmt.Sprintf("ALTER COLUMN %s TYPE %s USING %s::%s", column, typ, column, typ)}
+	var def string
+	if f.Default != nil {
+		if def, err = literal(f.Default); err != nil {
+			return nil, err
+		}
+		actions = append(actions, fmt.Sprintf("ALTER COLUMN %s SET DEFAULT %s", column, def))
+	} else if previous.Default != nil {
+		actions = append(actions, fmt.Sprintf("ALTER COLUMN %s DROP DEFAULT", column))
+	}
+	statements := []string{fmt.Sprintf("ALTER TABLE %s %s", tableName, strings.Join(actions, ", "))}
+
+	switch {
+	case f.Required && !previous.Required:
+		statements = append(statements,
+			fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", tableName, column, def, column),
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", tableName, column))
+	case !f.Required && previous.Required:
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", tableName, column))
+	}
+	return statements, nil
+}
+
+// rebuild returns the SQLite statements copying the table into a new one with the
+// altered field, as SQLite can not change the type of a column.
+func (g *generator) rebuild(t *table, altered feature.Field) ([]string, error) {
+	tableName := query.QuoteIdentifier(g.table)
+	newName := g.table + "_new"
+	statements, err := g.create(SQLite, newName, &table{fields: t.fields})
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []string{query.QuoteIdentifier(g.keyColumn)}
+	values := []string{query.QuoteIdentifier(g.keyColumn)}
+	for _, f := range t.fields {
+		column := query.QuoteIdentifier(f.Name)
+		columns = append(columns, column)
+		if f.Name != altered.Name {
+			values = append(values, column)
+			continue
+		}
+		typ, err := columnType(SQLite, f)
+		if err != nil {
+			return nil, err
+		}
+		value := fmt.Sprintf("CAST(%s AS %s)", column, typ)
+		if f.Default != nil {
+			def, err := literal(f.Default)
+			if err != nil {
+				return nil, err
+			}
+			value = fmt.Sprintf("COALESCE(%s, %s)", value, def)
+		}
+		values = append(values, value)
+	}
+	statements = append(statements,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", query.QuoteIdentifier(newName), strings.Join(columns, ", "), strings.Join(values, ", "), tableName),
+		"DROP TABLE "+tableName,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", query.QuoteIdentifier(newName), tableName))
+	// Indexes were dropped with the old table.
+	for _, idx := range t.indexes {
+		if s, ok := g.createIndex(idx); ok {
+			statements = append(statements, s)
+		}
+	}
+	return statements, nil
+}
+
+// createIndex returns the statement creating an index over the property columns of the
+// index. Indexes without properties only hold literals and are left out.
+func (g *generator) createIndex(idx feature.Index) (string, bool) {
+	props := idx.Properties()
+	if len(props) == 0 {
+		return "", false
+	}
+	columns := make([]string, len(props))
+	for i, p := range props {
+		columns[i] = query.QuoteIdentifier(p)
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", g.indexName(idx), query.QuoteIdentifier(g.table), strings.Join(columns, ", ")), true
+}
+
+// indexName returns the name of the index in the database, which is prefixed with the
+// table name since index names are shared by all tables.
+func (g *generator) indexName(idx feature.Index) string {
+	return query.QuoteIdentifier(g.table + "_" + idx.Name)
+}
+
+func columnDefinition(d Dialect, f feature.Field) (string, error) {
+	typ, err := columnType(d, f)
+	if err != nil {
+		return "", err
+	}
+	column := query.QuoteIdentifier(f.Name) + " " + typ
+	if f.Required {
+		column += " NOT NULL"
+	}
+	if f.Default != nil {
+		def, err := literal(f.Default)
+		if err != nil {
+			return "", err
+		}
+		column += " DEFAULT " + def
+	}
+	return column, nil
+}
+
+// columnType returns the SQL type of a column holding the values of a field. Objects
+// and arrays are stored as JSON.
+func columnType(d Dialect, f feature.Field) (string, error) {
+	switch d {
+	case SQLite:
+		switch f.Type {
+		case feature.FieldTypeString, feature.FieldTypeObject, feature.FieldTypeArray:
+			return "TEXT", nil
+		case feature.FieldTypeInteger:
+			return "INTEGER", nil
+		case feature.FieldTypeNumber:
+			return "REAL", nil
+		case feature.FieldTypeBoolean:
+			return "BOOLEAN", nil
+		}
+	case PostgreSQL:
+		switch f.Type {
+		case feature.FieldTypeString:
+			switch f.Format {
+			case "date":
+				return "DATE", nil
+			case "date-time":
+				return "TIMESTAMPTZ", nil
+			case "uuid":
+				return "UUID", nil
+			}
+			return "TEXT", nil
+		case feature.FieldTypeInteger:
+			return "BIGINT", nil
+		case feature.FieldTypeNumber:
+			return "DOUBLE PRECISION", nil
+		case feature.FieldTypeBoolean:
+			return "BOOLEAN", nil
+		case feature.FieldTypeObject, feature.FieldTypeArray:
+			return "JSONB", nil
+		}
+	default:
+		return "", fmt.Errorf("unknown dialect %s", d)
+	}
+	return "", fmt.Errorf("field '%s' has type '%s', which has no column type", f.Name, f.Type)
+}
+
+// literal returns a value as a SQL literal. Objects and arrays become JSON text.
+func literal(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quote(v), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return quote(string(data)), nil
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
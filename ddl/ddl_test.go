@@ -0,0 +1,223 @@
+package ddl
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/features/feature"
+)
+
+var orderSchema = feature.Schema{
+	Schema: "urn:features:order_line",
+	Migrations: feature.Migrations{
+		{Description: "Initial schema", Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+			feature.AddField{Field: feature.Field{Name: "note", Type: feature.FieldTypeString}},
+			feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeString, Default: "1"}},
+			feature.AddIndex{Index: feature.Index{Name: "by_sku", Parts: []feature.IndexPart{{Literal: "SKU#"}, {Property: "sku"}}}},
+		}},
+		{Description: "Drop note, add tags", Operations: []feature.Operation{
+			feature.RemoveField{FieldName: "note"},
+			feature.AddField{Field: feature.Field{Name: "tags", Type: feature.FieldTypeArray, Default: []any{}}},
+		}},
+		{Description: "Count is a number", Operations: []feature.Operation{
+			feature.AlterField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true, Default: 1}},
+		}},
+	},
+}
+
+func TestCreateTable(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{SQLite, []string{
+			"CREATE TABLE \"order_line\" (\n  \"key\" TEXT PRIMARY KEY,\n  \"sku\" TEXT NOT NULL,\n  \"count\" INTEGER NOT NULL DEFAULT 1,\n  \"tags\" TEXT DEFAULT '[]'\n)",
+			`CREATE INDEX "order_line_by_sku" ON "order_line" ("sku")`,
+		}},
+		{PostgreSQL, []string{
+			"CREATE TABLE \"order_line\" (\n  \"key\" TEXT PRIMARY KEY,\n  \"sku\" TEXT NOT NULL,\n  \"count\" BIGINT NOT NULL DEFAULT 1,\n  \"tags\" JSONB DEFAULT '[]'\n)",
+			`CREATE INDEX "order_line_by_sku" ON "order_line" ("sku")`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.String(), func(t *testing.T) {
+			got, err := CreateTable(orderSchema, tt.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected\n%q\ngot\n%q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    [][]string
+	}{
+		{SQLite, [][]string{
+			{
+				"CREATE TABLE \"lines\" (\n  \"id\" TEXT PRIMARY KEY,\n  \"sku\" TEXT NOT NULL,\n  \"note\" TEXT,\n  \"count\" TEXT DEFAULT '1'\n)",
+				`CREATE INDEX "lines_by_sku" ON "lines" ("sku")`,
+			},
+			{
+				`ALTER TABLE "lines" DROP COLUMN "note"`,
+				`ALTER TABLE "lines" ADD COLUMN "tags" TEXT DEFAULT '[]'`,
+			},
+			{
+				"CREATE TABLE \"lines_new\" (\n  \"id\" TEXT PRIMARY KEY,\n  \"sku\" TEXT NOT NULL,\n  \"count\" INTEGER NOT NULL DEFAULT 1,\n  \"tags\" TEXT DEFAULT '[]'\n)",
+				`INSERT INTO "lines_new" ("id", "sku", "count", "tags") SELECT "id", "sku", COALESCE(CAST("count" AS INTEGER), 1), "tags" FROM "lines"`,
+				`DROP TABLE "lines"`,
+				`ALTER TABLE "lines_new" RENAME TO "lines"`,
+				`CREATE INDEX "lines_by_sku" ON "lines" ("sku")`,
+			},
+		}},
+		{PostgreSQL, [][]string{
+			{
+				"CREATE TABLE \"lines\" (\n  \"id\" TEXT PRIMARY KEY,\n  \"sku\" TEXT NOT NULL,\n  \"note\" TEXT,\n  \"count\" TEXT DEFAULT '1'\n)",
+				`CREATE INDEX "lines_by_sku" ON "lines" ("sku")`,
+			},
+			{
+				`ALTER TABLE "lines" DROP COLUMN "note"`,
+				`ALTER TABLE "lines" ADD COLUMN "tags" JSONB DEFAULT '[]'`,
+			},
+			{
+				`ALTER TABLE "lines" ALTER COLUMN "count" TYPE BIGINT USING "count"::BIGINT, ALTER COLUMN "count" SET DEFAULT 1`,
+				`UPDATE "lines" SET "count" = 1 WHERE "count" IS NULL`,
+				`ALTER TABLE "lines" ALTER COLUMN "count" SET NOT NULL`,
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.String(), func(t *testing.T) {
+			got, err := Migrations(orderSchema, tt.dialect, WithTable("lines"), WithKeyColumn("id"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d migrations, got %d", len(tt.want), len(got))
+			}
+			for i, m := range got {
+				if m.Version != i+1 || m.Schema != orderSchema.Schema || m.Description != orderSchema.Migrations[i].Description {
+					t.Errorf("unexpected migration %d: %+v", i, m)
+				}
+				if !slices.Equal(m.Statements, tt.want[i]) {
+					t.Errorf("version %d: expected\n%q\ngot\n%q", m.Version, tt.want[i], m.Statements)
+				}
+				if len(m.Checksum) != 64 {
+					t.Errorf("version %d: expected a SHA-256 checksum, got %q", m.Version, m.Checksum)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrations_RemoveIndexedField(t *testing.T) {
+	sch := feature.Schema{Schema: "urn:features:order_line", Migrations: feature.Migrations{
+		{Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+			feature.AddIndex{Index: feature.Index{Name: "by_sku", Parts: []feature.IndexPart{{Property: "sku"}}}},
+		}},
+		{Operations: []feature.Operation{
+			feature.RemoveIndex{Name: "by_sku"},
+			feature.RemoveField{FieldName: "sku"},
+		}},
+	}}
+	migrations, err := Migrations(sch, PostgreSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`DROP INDEX "order_line_by_sku"`, `ALTER TABLE "order_line" DROP COLUMN "sku"`}
+	if got := migrations[1].Statements; !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMigrations_Invalid(t *testing.T) {
+	cases := map[string]feature.Migrations{
+		"remove missing field": {
+			{Operations: []feature.Operation{feature.RemoveField{FieldName: "missing"}}},
+		},
+		"required without default": {
+			{Operations: []feature.Operation{feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString}}}},
+			{Operations: []feature.Operation{feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true}}}},
+		},
+		"alter missing field": {
+			{Operations: []feature.Operation{feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString}}}},
+			{Operations: []feature.Operation{feature.AlterField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger}}}},
+		},
+		"unknown type": {
+			{Operations: []feature.Operation{feature.AddField{Field: feature.Field{Name: "sku", Type: "enum"}}}},
+		},
+		"remove indexed field": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddIndex{Index: feature.Index{Name: "by_sku", Parts: []feature.IndexPart{{Property: "sku"}}}},
+			}},
+			{Operations: []feature.Operation{
+				feature.RemoveField{FieldName: "sku"},
+				feature.RemoveIndex{Name: "by_sku"},
+			}},
+		},
+		"index on missing field": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString}},
+			}},
+			{Operations: []feature.Operation{
+				feature.AddIndex{Index: feature.Index{Name: "by_count", Parts: []feature.IndexPart{{Property: "count"}}}},
+			}},
+		},
+		"index without parts": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddIndex{Index: feature.Index{Name: "empty"}},
+			}},
+		},
+		"index part with literal and property": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddIndex{Index: feature.Index{Name: "by_sku", Parts: []feature.IndexPart{{Literal: "SKU", Property: "sku"}}}},
+			}},
+		},
+		"index on optional field": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString}},
+				feature.AddIndex{Index: feature.Index{Name: "by_sku", Parts: []feature.IndexPart{{Property: "sku"}}}},
+			}},
+		},
+		"invalid index removed later": {
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "note", Type: feature.FieldTypeString}},
+			}},
+			{Operations: []feature.Operation{
+				feature.AddIndex{Index: feature.Index{Name: "by_note", Parts: []feature.IndexPart{{Property: "note"}}}},
+			}},
+			{Operations: []feature.Operation{
+				feature.RemoveIndex{Name: "by_note"},
+			}},
+		},
+		"key column": {
+			{Operations: []feature.Operation{feature.AddField{Field: feature.Field{Name: "key", Type: feature.FieldTypeString}}}},
+		},
+	}
+	for name, migrations := range cases {
+		t.Run(name, func(t *testing.T) {
+			sch := feature.Schema{Schema: "urn:features:broken", Migrations: migrations}
+			if _, err := Migrations(sch, PostgreSQL); !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("expected ErrInvalidSchema, got %v", err)
+			}
+			if name == "remove indexed field" {
+				// The latest version is valid; only the order of the operations is not.
+				return
+			}
+			if _, err := CreateTable(sch, PostgreSQL); !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("expected ErrInvalidSchema from CreateTable, got %v", err)
+			}
+		})
+	}
+}
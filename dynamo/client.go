@@ -0,0 +1,95 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+var (
+	ErrConditionalCheckFailed = errors.New("conditional check failed")
+	ErrTableNotFound          = errors.New("table not found")
+	ErrIndexNotFound          = errors.New("index not found")
+	ErrInvalidExpression      = errors.New("invalid expression")
+	ErrMissingKey             = errors.New("missing key attribute")
+)
+
+// Client is the subset of a DynamoDB-compatible API used by store.Table. The inputs mirror
+// the DynamoDB request shapes, with attribute values as plain Go values: strings,
+// int64, float64, bool, lists and *jsonchamp.Map. An implementation backed by a real
+// service only has to convert between these values and its attribute value types.
+type Client interface {
+	PutItem(ctx context.Context, in PutItemInput) error
+	GetItem(ctx context.Context, in GetItemInput) (*jsonchamp.Map, error)
+	DeleteItem(ctx context.Context, in DeleteItemInput) error
+	Query(ctx context.Context, in QueryInput) (QueryOutput, error)
+}
+
+// PutItemInput writes an item, replacing any item with the same primary key.
+type PutItemInput struct {
+	TableName string
+	Item      *jsonchamp.Map
+	// ConditionExpression must hold for the existing item, if any, or the write fails
+	// with ErrConditionalCheckFailed.
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]any
+}
+
+// GetItemInput reads an item by its primary key. GetItem returns nil if there is no item.
+type GetItemInput struct {
+	TableName string
+	Key       map[string]any
+}
+
+// DeleteItemInput deletes an item by its primary key.
+type DeleteItemInput struct {
+	TableName                 string
+	Key                       map[string]any
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]any
+}
+
+// QueryInput reads the items of a single partition of the table or of a secondary index,
+// ordered by sort key.
+type QueryInput struct {
+	TableName string
+	// IndexName selects a secondary index. The table itself is queried when it is empty.
+	IndexName string
+	// KeyConditionExpression selects the partition and optionally a sort key range,
+	// for example "#pk = :pk AND begins_with(#sk, :prefix)".
+	KeyConditionExpression    string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]any
+	// ScanIndexForward orders results by ascending sort key when true.
+	ScanIndexForward bool
+	// Limit is the maximum number of items to return, or zero for no limit.
+	Limit int
+	// ExclusiveStartKey continues a query after the item with this key, as returned in
+	// QueryOutput.LastEvaluatedKey.
+	ExclusiveStartKey map[string]any
+}
+
+// QueryOutput is the result of a query.
+type QueryOutput struct {
+	Items []*jsonchamp.Map
+	// LastEvaluatedKey is set when there may be more items.
+	LastEvaluatedKey map[string]any
+}
+
+// Definition describes the key schema of a table and its secondary indexes.
+type Definition struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+	Indexes      []IndexDefinition
+}
+
+// IndexDefinition describes the key schema of a secondary index.
+type IndexDefinition struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+}
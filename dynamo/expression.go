@@ -0,0 +1,430 @@
+package dynamo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+// condition is a compiled condition or key condition expression.
+type condition struct {
+	eval func(item *jsonchamp.Map) bool
+	// attributes are the names of the attributes the expression refers to.
+	attributes []string
+}
+
+type operand func(item *jsonchamp.Map) (any, bool)
+
+// compileExpression compiles the subset of the DynamoDB expression syntax needed for
+// conditions and key conditions: comparisons (=, <>, <, <=, >, >=), BETWEEN,
+// attribute_exists, attribute_not_exists and begins_with, combined with AND, OR, NOT
+// and parentheses. Operands are attribute names, #name placeholders and :value placeholders.
+func compileExpression(expr string, names map[string]string, values map[string]any) (condition, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return condition{}, err
+	}
+	p := &exprParser{expr: expr, tokens: tokens, names: names, values: values}
+	eval, err := p.parseOr()
+	if err != nil {
+		return condition{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return condition{}, p.errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return condition{eval: eval, attributes: p.attributes}, nil
+}
+
+// compileKeyCondition compiles a key condition expression, which is narrower than a
+// condition: an equality on the partition key, optionally combined with AND and one
+// condition on the sort key, which is a comparison other than <>, BETWEEN or begins_with.
+func compileKeyCondition(expr string, names map[string]string, values map[string]any, partitionKey, sortKey string) (condition, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return condition{}, err
+	}
+	p := &exprParser{expr: expr, tokens: tokens, names: names, values: values}
+	var terms []func(*jsonchamp.Map) bool
+	for len(terms) == 0 || p.keyword("AND") {
+		attr, op, term, err := p.parseKeyTerm()
+		if err != nil {
+			return condition{}, err
+		}
+		switch {
+		case slices.Contains(p.attributes[:len(p.attributes)-1], attr):
+			return condition{}, p.errorf("key attribute %s is selected more than once", attr)
+		case attr == partitionKey && op != "=":
+			return condition{}, p.errorf("partition key %s can only be compared with =", attr)
+		case attr != partitionKey && (sortKey == "" || attr != sortKey):
+			return condition{}, p.errorf("key condition uses non-key attribute %s", attr)
+		}
+		terms = append(terms, term)
+	}
+	if p.pos < len(p.tokens) {
+		return condition{}, p.errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if !slices.Contains(p.attributes, partitionKey) {
+		return condition{}, p.errorf("key condition must select partition key %s", partitionKey)
+	}
+	return condition{
+		eval: func(item *jsonchamp.Map) bool {
+			for _, term := range terms {
+				if !term(item) {
+					return false
+				}
+			}
+			return true
+		},
+		attributes: p.attributes,
+	}, nil
+}
+
+// parseKeyTerm parses one condition of a key condition expression, returning the key
+// attribute it selects and its operator in lower case.
+func (p *exprParser) parseKeyTerm() (string, string, func(*jsonchamp.Map) bool, error) {
+	op := strings.ToLower(p.peek())
+	switch op {
+	case "begins_with":
+	case "(", "not", "attribute_exists", "attribute_not_exists":
+		return "", "", nil, p.errorf("key conditions do not support %q", p.peek())
+	default:
+		op = ""
+		if p.pos+1 < len(p.tokens) {
+			op = strings.ToLower(p.tokens[p.pos+1])
+		}
+	}
+	if op == "<>" {
+		return "", "", nil, p.errorf("key conditions do not support <>")
+	}
+	start := len(p.attributes)
+	term, err := p.parsePrimary()
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(p.attributes) != start+1 {
+		return "", "", nil, p.errorf("a key condition must compare one key attribute with values")
+	}
+	return p.attributes[start], op, term, nil
+}
+
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '=':
+			tokens = append(tokens, "=")
+			i++
+		case c == '<' || c == '>':
+			j := i + 1
+			if j < len(expr) && (expr[j] == '=' || (c == '<' && expr[j] == '>')) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		case c == '#' || c == ':' || isNameChar(c):
+			j := i + 1
+			for j < len(expr) && isNameChar(rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: %q: unexpected character %q at position %d", ErrInvalidExpression, expr, c, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isNameChar(c rune) bool {
+	return c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+type exprParser struct {
+	expr       string
+	tokens     []string
+	pos        int
+	names      map[string]string
+	values     map[string]any
+	attributes []string
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %q: %s", ErrInvalidExpression, p.expr, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) keyword(kw string) bool {
+	if strings.EqualFold(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.peek() != tok {
+		return p.errorf("expected %q, got %q", tok, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseOr() (func(*jsonchamp.Map) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item *jsonchamp.Map) bool { return l(item) || right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (func(*jsonchamp.Map) bool, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item *jsonchamp.Map) bool { return l(item) && right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (func(*jsonchamp.Map) bool, error) {
+	if p.keyword("NOT") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(item *jsonchamp.Map) bool { return !inner(item) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (func(*jsonchamp.Map) bool, error) {
+	if p.peek() == "(" {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	switch strings.ToLower(p.peek()) {
+	case "attribute_exists", "attribute_not_exists":
+		exists := strings.EqualFold(p.peek(), "attribute_exists")
+		p.pos++
+		args, err := p.parseArgs(1)
+		if err != nil {
+			return nil, err
+		}
+		return func(item *jsonchamp.Map) bool {
+			_, ok := args[0](item)
+			return ok == exists
+		}, nil
+	case "begins_with":
+		p.pos++
+		args, err := p.parseArgs(2)
+		if err != nil {
+			return nil, err
+		}
+		return func(item *jsonchamp.Map) bool {
+			v, ok := args[0](item)
+			prefix, okPrefix := args[1](item)
+			s, isString := v.(string)
+			ps, isPrefixString := prefix.(string)
+			return ok && okPrefix && isString && isPrefixString && strings.HasPrefix(s, ps)
+		}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("BETWEEN") {
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN, got %q", p.peek())
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item *jsonchamp.Map) bool {
+			return compareOperands(item, ">=", left, low) && compareOperands(item, "<=", left, high)
+		}, nil
+	}
+
+	op := p.peek()
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+		p.pos++
+	default:
+		return nil, p.errorf("expected comparison, got %q", op)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(item *jsonchamp.Map) bool {
+		return compareOperands(item, op, left, right)
+	}, nil
+}
+
+func (p *exprParser) parseArgs(n int) ([]operand, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make([]operand, n)
+	for i := range args {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return args, p.expect(")")
+}
+
+func (p *exprParser) parseOperand() (operand, error) {
+	tok := p.peek()
+	switch {
+	case tok == "" || strings.ContainsAny(tok[:1], "(),=<>"):
+		return nil, p.errorf("expected operand, got %q", tok)
+	case strings.HasPrefix(tok, ":"):
+		v, ok := p.values[tok]
+		if !ok {
+			return nil, p.errorf("value %s is not defined", tok)
+		}
+		p.pos++
+		return func(*jsonchamp.Map) (any, bool) { return v, true }, nil
+	case strings.HasPrefix(tok, "#"):
+		name, ok := p.names[tok]
+		if !ok {
+			return nil, p.errorf("name %s is not defined", tok)
+		}
+		p.pos++
+		return p.attribute(name), nil
+	default:
+		p.pos++
+		return p.attribute(tok), nil
+	}
+}
+
+func (p *exprParser) attribute(name string) operand {
+	p.attributes = append(p.attributes, name)
+	return func(item *jsonchamp.Map) (any, bool) {
+		if item == nil {
+			return nil, false
+		}
+		return item.Get(name)
+	}
+}
+
+// compareOperands compares two operands. Numbers compare numerically and strings
+// lexically; comparing values of different types is false, except for <>.
+func compareOperands(item *jsonchamp.Map, op string, left, right operand) bool {
+	a, okA := left(item)
+	b, okB := right(item)
+	if !okA || !okB {
+		return false
+	}
+	c, comparable := compareValues(a, b)
+	if !comparable {
+		equal := jsonchamp.NewFromItems("v", a).Equals(jsonchamp.NewFromItems("v", b))
+		switch op {
+		case "=":
+			return equal
+		case "<>":
+			return !equal
+		default:
+			return false
+		}
+	}
+	switch op {
+	case "=":
+		return c == 0
+	case "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// compareValues orders two strings or two numbers.
+func compareValues(a, b any) (int, bool) {
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(sa, sb), true
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
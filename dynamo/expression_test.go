@@ -0,0 +1,78 @@
+package dynamo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestCompileExpression(t *testing.T) {
+	item := jsonchamp.NewFromItems("pk", "ORDER:1", "sk", "ITEM:0042", "version", 3, "total", 9.5)
+	names := map[string]string{"#pk": "pk", "#sk": "sk", "#v": "version"}
+	values := map[string]any{
+		":pk":     "ORDER:1",
+		":prefix": "ITEM:",
+		":from":   "ITEM:0001",
+		":to":     "ITEM:0099",
+		":v":      int64(3),
+		":big":    10.0,
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: "#pk = :pk", want: true},
+		{expr: "#pk <> :pk", want: false},
+		{expr: "begins_with(#sk, :prefix)", want: true},
+		{expr: "#sk BETWEEN :from AND :to", want: true},
+		{expr: "#pk = :pk AND #sk BETWEEN :to AND :to", want: false},
+		{expr: "#v = :v", want: true},
+		{expr: "total < :big", want: true},
+		{expr: "total >= :v", want: true},
+		{expr: "attribute_exists(#v) AND attribute_not_exists(deleted)", want: true},
+		{expr: "attribute_not_exists(#pk) OR #v = :v", want: true},
+		{expr: "NOT (#v = :v)", want: false},
+		{expr: "#pk < :v", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cond, err := compileExpression(tt.expr, names, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cond.eval(item); got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A missing item only satisfies conditions that do not need it.
+	cond, err := compileExpression("attribute_not_exists(#pk)", names, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cond.eval(nil) {
+		t.Error("attribute_not_exists() should hold for a missing item")
+	}
+}
+
+func TestCompileExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"#pk = ",
+		"#missing = :pk",
+		"#pk = :missing",
+		"#pk BETWEEN :pk",
+		"begins_with(#pk)",
+		"(#pk = :pk",
+		"#pk = :pk extra",
+		"#pk ! :pk",
+	} {
+		_, err := compileExpression(expr, map[string]string{"#pk": "pk"}, map[string]any{":pk": "a"})
+		if !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("compileExpression(%q) error = %v, want %v", expr, err, ErrInvalidExpression)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+// Local is an in-process stand-in for a DynamoDB-compatible service. It keeps items in
+// memory and implements the same conditional writes, secondary indexes and queries, so
+// code written against Client can be tested without a cloud service.
+type Local struct {
+	mu     sync.RWMutex
+	tables map[string]*localTable
+}
+
+type localTable struct {
+	def   Definition
+	items map[string]*jsonchamp.Map
+}
+
+// NewLocal creates a stand-in without any tables.
+func NewLocal() *Local {
+	return &Local{tables: make(map[string]*localTable)}
+}
+
+var _ Client = (*Local)(nil)
+
+// CreateTable adds a table. Secondary indexes are sparse: an item only appears in an
+// index if it has the key attributes of the index.
+func (l *Local) CreateTable(def Definition) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if def.Name == "" || def.PartitionKey == "" {
+		return fmt.Errorf("table needs a name and a partition key")
+	}
+	if _, exists := l.tables[def.Name]; exists {
+		return fmt.Errorf("table %q already exists", def.Name)
+	}
+	for _, idx := range def.Indexes {
+		if idx.Name == "" || idx.PartitionKey == "" {
+			return fmt.Errorf("index of table %q needs a name and a partition key", def.Name)
+		}
+	}
+	l.tables[def.Name] = &localTable{def: def, items: make(map[string]*jsonchamp.Map)}
+	return nil
+}
+
+func (l *Local) table(name string) (*localTable, error) {
+	t, ok := l.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+	return t, nil
+}
+
+// PutItem implements Client.
+func (l *Local) PutItem(ctx context.Context, in PutItemInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, err := l.table(in.TableName)
+	if err != nil {
+		return err
+	}
+	id, err := t.itemID(func(name string) (any, bool) { return in.Item.Get(name) })
+	if err != nil {
+		return err
+	}
+	if err := checkCondition(t.items[id], in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return err
+	}
+	t.items[id] = in.Item
+	return nil
+}
+
+// GetItem implements Client.
+func (l *Local) GetItem(ctx context.Context, in GetItemInput) (*jsonchamp.Map, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	t, err := l.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.keyID(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	return t.items[id], nil
+}
+
+// DeleteItem implements Client. Deleting an item that does not exist is not an error,
+// unless the condition requires the item to exist.
+func (l *Local) DeleteItem(ctx context.Context, in DeleteItemInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, err := l.table(in.TableName)
+	if err != nil {
+		return err
+	}
+	id, err := t.keyID(in.Key)
+	if err != nil {
+		return err
+	}
+	if err := checkCondition(t.items[id], in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+		return err
+	}
+	delete(t.items, id)
+	return nil
+}
+
+// Query implements Client. Like the service, it only accepts key conditions selecting a
+// partition with = and optionally narrowing the sort key.
+func (l *Local) Query(ctx context.Context, in QueryInput) (QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return QueryOutput{}, err
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	t, err := l.table(in.TableName)
+	if err != nil {
+		return QueryOutput{}, err
+	}
+	pk, sk := t.def.PartitionKey, t.def.SortKey
+	if in.IndexName != "" {
+		i := slices.IndexFunc(t.def.Indexes, func(idx IndexDefinition) bool { return idx.Name == in.IndexName })
+		if i < 0 {
+			return QueryOutput{}, fmt.Errorf("%w: %s on table %s", ErrIndexNotFound, in.IndexName, in.TableName)
+		}
+		pk, sk = t.def.Indexes[i].PartitionKey, t.def.Indexes[i].SortKey
+	}
+
+	cond, err := compileKeyCondition(in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, pk, sk)
+	if err != nil {
+		return QueryOutput{}, err
+	}
+
+	sortValue := func(get func(string) (any, bool)) any {
+		if sk == "" {
+			return nil
+		}
+		v, _ := get(sk)
+		return v
+	}
+	type match struct {
+		id   string
+		sort any
+		item *jsonchamp.Map
+	}
+	var matches []match
+	for id, item := range t.items {
+		if !item.Contains(pk) || (sk != "" && !item.Contains(sk)) {
+			continue
+		}
+		if cond.eval(item) {
+			matches = append(matches, match{id: id, sort: sortValue(func(name string) (any, bool) { return item.Get(name) }), item: item})
+		}
+	}
+
+	// Items are ordered by sort key, and by primary key within equal sort keys, which
+	// only happens in secondary indexes.
+	order := func(aSort any, aID string, bSort any, bID string) int {
+		if sk != "" {
+			if c, _ := compareValues(aSort, bSort); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(aID, bID)
+	}
+	direction := 1
+	if !in.ScanIndexForward {
+		direction = -1
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		return direction * order(a.sort, a.id, b.sort, b.id)
+	})
+
+	if in.ExclusiveStartKey != nil {
+		get := func(name string) (any, bool) {
+			v, ok := in.ExclusiveStartKey[name]
+			return v, ok
+		}
+		startID, err := t.itemID(get)
+		if err != nil {
+			return QueryOutput{}, err
+		}
+		startSort := sortValue(get)
+		i := 0
+		for i < len(matches) && direction*order(matches[i].sort, matches[i].id, startSort, startID) <= 0 {
+			i++
+		}
+		matches = matches[i:]
+	}
+
+	var out QueryOutput
+	if in.Limit > 0 && len(matches) > in.Limit {
+		matches = matches[:in.Limit]
+		last := matches[len(matches)-1].item
+		out.LastEvaluatedKey = make(map[string]any)
+		for _, attr := range []string{t.def.PartitionKey, t.def.SortKey, pk, sk} {
+			if v, ok := last.Get(attr); ok && attr != "" {
+				out.LastEvaluatedKey[attr] = v
+			}
+		}
+	}
+	for _, m := range matches {
+		out.Items = append(out.Items, m.item)
+	}
+	return out, nil
+}
+
+func checkCondition(existing *jsonchamp.Map, expr string, names map[string]string, values map[string]any) error {
+	if expr == "" {
+		return nil
+	}
+	cond, err := compileExpression(expr, names, values)
+	if err != nil {
+		return err
+	}
+	if !cond.eval(existing) {
+		return ErrConditionalCheckFailed
+	}
+	return nil
+}
+
+// keyID identifies an item by a key holding exactly the primary key attributes.
+func (t *localTable) keyID(key map[string]any) (string, error) {
+	want := 1
+	if t.def.SortKey != "" {
+		want = 2
+	}
+	if len(key) != want {
+		return "", fmt.Errorf("%w: key of table %s must have %d attributes, got %d", ErrMissingKey, t.def.Name, want, len(key))
+	}
+	return t.itemID(func(name string) (any, bool) {
+		v, ok := key[name]
+		return v, ok
+	})
+}
+
+// itemID identifies an item by its primary key attributes.
+func (t *localTable) itemID(get func(string) (any, bool)) (string, error) {
+	var parts []string
+	for _, attr := range []string{t.def.PartitionKey, t.def.SortKey} {
+		if attr == "" {
+			continue
+		}
+		v, ok := get(attr)
+		if !ok {
+			return "", fmt.Errorf("%w: %s in table %s", ErrMissingKey, attr, t.def.Name)
+		}
+		switch n := v.(type) {
+		case int:
+			v = int64(n)
+		case string, int64, float64:
+		default:
+			return "", fmt.Errorf("%w: %s must be a string or number, got %T", ErrMissingKey, attr, v)
+		}
+		parts = append(parts, fmt.Sprintf("%T:%v", v, v))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
@@ -0,0 +1,234 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l := NewLocal()
+	err := l.CreateTable(Definition{
+		Name:         "main",
+		PartitionKey: "pk",
+		SortKey:      "sk",
+		Indexes:      []IndexDefinition{{Name: "gsi1", PartitionKey: "gsi1pk", SortKey: "gsi1sk"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLocalConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+
+	put := func(version int, cond string, values map[string]any) error {
+		return l.PutItem(ctx, PutItemInput{
+			TableName:                 "main",
+			Item:                      jsonchamp.NewFromItems("pk", "A", "sk", "1", "version", version),
+			ConditionExpression:       cond,
+			ExpressionAttributeNames:  map[string]string{"#pk": "pk", "#v": "version"},
+			ExpressionAttributeValues: values,
+		})
+	}
+
+	if err := put(1, "attribute_not_exists(#pk)", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := put(1, "attribute_not_exists(#pk)", nil); !errors.Is(err, ErrConditionalCheckFailed) {
+		t.Fatalf("second create error = %v, want %v", err, ErrConditionalCheckFailed)
+	}
+	if err := put(2, "#v = :v", map[string]any{":v": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := put(2, "#v = :v", map[string]any{":v": 1}); !errors.Is(err, ErrConditionalCheckFailed) {
+		t.Fatalf("stale update error = %v, want %v", err, ErrConditionalCheckFailed)
+	}
+
+	item, err := l.GetItem(ctx, GetItemInput{TableName: "main", Key: map[string]any{"pk": "A", "sk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := item.GetInt("version"); v != 2 {
+		t.Fatalf("version = %d, want 2", v)
+	}
+
+	del := DeleteItemInput{
+		TableName:                 "main",
+		Key:                       map[string]any{"pk": "A", "sk": "1"},
+		ConditionExpression:       "#v = :v",
+		ExpressionAttributeNames:  map[string]string{"#v": "version"},
+		ExpressionAttributeValues: map[string]any{":v": 1},
+	}
+	if err := l.DeleteItem(ctx, del); !errors.Is(err, ErrConditionalCheckFailed) {
+		t.Fatalf("stale delete error = %v, want %v", err, ErrConditionalCheckFailed)
+	}
+	del.ExpressionAttributeValues[":v"] = 2
+	if err := l.DeleteItem(ctx, del); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := l.GetItem(ctx, GetItemInput{TableName: "main", Key: map[string]any{"pk": "A", "sk": "1"}}); item != nil {
+		t.Fatalf("GetItem() after delete = %v, want nil", item)
+	}
+
+	if err := l.PutItem(ctx, PutItemInput{TableName: "main", Item: jsonchamp.NewFromItems("pk", "A")}); !errors.Is(err, ErrMissingKey) {
+		t.Fatalf("PutItem() without sort key error = %v, want %v", err, ErrMissingKey)
+	}
+	if _, err := l.GetItem(ctx, GetItemInput{TableName: "other", Key: map[string]any{"pk": "A"}}); !errors.Is(err, ErrTableNotFound) {
+		t.Fatalf("GetItem() on unknown table error = %v, want %v", err, ErrTableNotFound)
+	}
+}
+
+func TestLocalQuery(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+
+	for _, sk := range []string{"ITEM:3", "ITEM:1", "META", "ITEM:2"} {
+		item := jsonchamp.NewFromItems("pk", "ORDER:1", "sk", sk)
+		if sk != "META" {
+			item = item.Set("gsi1pk", "PRODUCT:9").Set("gsi1sk", "ORDER:1:"+sk)
+		}
+		if err := l.PutItem(ctx, PutItemInput{TableName: "main", Item: item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.PutItem(ctx, PutItemInput{TableName: "main", Item: jsonchamp.NewFromItems("pk", "ORDER:2", "sk", "ITEM:1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	sortKeys := func(out QueryOutput, attr string) []string {
+		var keys []string
+		for _, item := range out.Items {
+			k, _ := item.GetString(attr)
+			keys = append(keys, k)
+		}
+		return keys
+	}
+	equal := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	tests := []struct {
+		name  string
+		in    QueryInput
+		attr  string
+		want  []string
+		pages int
+	}{
+		{
+			name: "prefix",
+			in: QueryInput{
+				KeyConditionExpression:    "pk = :pk AND begins_with(sk, :prefix)",
+				ExpressionAttributeValues: map[string]any{":pk": "ORDER:1", ":prefix": "ITEM:"},
+				ScanIndexForward:          true,
+			},
+			attr:  "sk",
+			want:  []string{"ITEM:1", "ITEM:2", "ITEM:3"},
+			pages: 1,
+		},
+		{
+			name: "range descending",
+			in: QueryInput{
+				KeyConditionExpression:    "pk = :pk AND sk BETWEEN :from AND :to",
+				ExpressionAttributeValues: map[string]any{":pk": "ORDER:1", ":from": "ITEM:2", ":to": "META"},
+			},
+			attr:  "sk",
+			want:  []string{"META", "ITEM:3", "ITEM:2"},
+			pages: 1,
+		},
+		{
+			name: "paginated",
+			in: QueryInput{
+				KeyConditionExpression:    "pk = :pk",
+				ExpressionAttributeValues: map[string]any{":pk": "ORDER:1"},
+				ScanIndexForward:          true,
+				Limit:                     3,
+			},
+			attr:  "sk",
+			want:  []string{"ITEM:1", "ITEM:2", "ITEM:3", "META"},
+			pages: 2,
+		},
+		{
+			name: "sparse index",
+			in: QueryInput{
+				IndexName:                 "gsi1",
+				KeyConditionExpression:    "gsi1pk = :pk",
+				ExpressionAttributeValues: map[string]any{":pk": "PRODUCT:9"},
+				ScanIndexForward:          true,
+				Limit:                     2,
+			},
+			attr:  "gsi1sk",
+			want:  []string{"ORDER:1:ITEM:1", "ORDER:1:ITEM:2", "ORDER:1:ITEM:3"},
+			pages: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			in.TableName = "main"
+			var got []string
+			pages := 0
+			for {
+				out, err := l.Query(ctx, in)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				got = append(got, sortKeys(out, tt.attr)...)
+				if out.LastEvaluatedKey == nil {
+					break
+				}
+				in.ExclusiveStartKey = out.LastEvaluatedKey
+			}
+			if !equal(got, tt.want) || pages != tt.pages {
+				t.Errorf("Query() = %v in %d pages, want %v in %d pages", got, pages, tt.want, tt.pages)
+			}
+		})
+	}
+
+	_, err := l.Query(ctx, QueryInput{
+		TableName:                 "main",
+		KeyConditionExpression:    "sk = :sk",
+		ExpressionAttributeValues: map[string]any{":sk": "META"},
+	})
+	if !errors.Is(err, ErrInvalidExpression) {
+		t.Fatalf("Query() without partition key error = %v, want %v", err, ErrInvalidExpression)
+	}
+	for _, expr := range []string{
+		"pk = :pk OR pk = :sk",
+		"pk = :pk AND sk <> :sk",
+		"pk >= :pk",
+		"begins_with(pk, :pk)",
+		"pk = :pk AND pk = :sk",
+		"pk = :pk AND (sk = :sk)",
+		"pk = :pk AND sk = :sk AND gsi1pk = :pk",
+		"pk = :pk AND attribute_exists(sk)",
+		"pk = :pk AND NOT sk = :sk",
+	} {
+		_, err := l.Query(ctx, QueryInput{
+			TableName:                 "main",
+			KeyConditionExpression:    expr,
+			ExpressionAttributeValues: map[string]any{":pk": "ORDER:1", ":sk": "META"},
+		})
+		if !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("Query(%q) error = %v, want %v", expr, err, ErrInvalidExpression)
+		}
+	}
+	_, err = l.Query(ctx, QueryInput{TableName: "main", IndexName: "gsi9", KeyConditionExpression: "pk = :pk", ExpressionAttributeValues: map[string]any{":pk": "A"}})
+	if !errors.Is(err, ErrIndexNotFound) {
+		t.Fatalf("Query() on unknown index error = %v, want %v", err, ErrIndexNotFound)
+	}
+}
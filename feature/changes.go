@@ -0,0 +1,73 @@
+package feature
+
+import (
+	"slices"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeModified ChangeKind = "modified"
+	ChangeRemoved  ChangeKind = "removed"
+)
+
+// Change describes a property that changed since the feature was loaded.
+// Old is nil for added properties and New is nil for removed properties.
+type Change struct {
+	Field string
+	Kind  ChangeKind
+	Old   any
+	New   any
+}
+
+// Changes returns the top-level properties that were added, modified or removed since
+// the feature was loaded, sorted by field name.
+func (f *Feature) Changes() []Change {
+	return CompareMaps(f.original, f.m)
+}
+
+// CompareMaps returns the top-level properties that differ between from and to,
+// sorted by field name.
+func CompareMaps(from *jsonchamp.Map, to *jsonchamp.Map) []Change {
+	diff := from.Diff(to)
+
+	fields := diff.Keys()
+	slices.Sort(fields)
+
+	changes := make([]Change, 0, len(fields))
+	for _, field := range fields {
+		oldValue, hadValue := from.Get(field)
+		newValue, hasValue := to.Get(field)
+
+		change := Change{Field: field, Old: oldValue, New: newValue}
+		switch {
+		case !hadValue:
+			change.Kind = ChangeAdded
+		case !hasValue:
+			change.Kind = ChangeRemoved
+		default:
+			change.Kind = ChangeModified
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// IsDirty returns true if the feature has changed since it was loaded.
+func (f *Feature) IsDirty() bool {
+	return len(f.original.Diff(f.m).Keys()) > 0
+}
+
+// Reset discards all changes and restores the feature to the state it was loaded with.
+func (f *Feature) Reset() {
+	f.m = f.original
+}
+
+// MarkClean makes the current state the loaded state, for example after it has been
+// written to a store.
+func (f *Feature) MarkClean() {
+	f.original = f.m
+}
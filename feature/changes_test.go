@@ -0,0 +1,78 @@
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestChanges(t *testing.T) {
+	f := New(Schema{}, WithMap(jsonchamp.NewFromItems("a", 1, "b", "x", "c", true)))
+	if f.IsDirty() {
+		t.Fatal("new feature should not be dirty")
+	}
+
+	f.Set("a", 2)
+	f.Set("d", "new")
+	f.Delete("c")
+
+	want := []Change{
+		{Field: "a", Kind: ChangeModified, Old: int64(1), New: int64(2)},
+		{Field: "c", Kind: ChangeRemoved, Old: true},
+		{Field: "d", Kind: ChangeAdded, New: "new"},
+	}
+	got := f.Changes()
+	if len(got) != len(want) {
+		t.Fatalf("Changes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Changes()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	f.Reset()
+	if f.IsDirty() {
+		t.Fatalf("Reset() left changes: %v", f.Changes())
+	}
+	if v, _ := f.GetInt("a"); v != 1 {
+		t.Fatalf("a = %d after Reset(), want 1", v)
+	}
+}
+
+func TestChangesMarkClean(t *testing.T) {
+	var f *Feature
+	if err := json.Unmarshal([]byte(`{"payload": {"a": 1}}`), &f); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Set("a", 2)
+	if !f.IsDirty() {
+		t.Fatal("feature should be dirty after Set")
+	}
+
+	f.MarkClean()
+	if f.IsDirty() {
+		t.Fatalf("MarkClean() left changes: %v", f.Changes())
+	}
+}
+
+func TestDeleteMissingField(t *testing.T) {
+	items := []any{}
+	for i := range 12 {
+		items = append(items, fmt.Sprintf("field%d", i), i)
+	}
+
+	// Map hashes are seeded per map, so try enough maps to hit shared hash slots.
+	for range 500 {
+		f := New(Schema{}, WithMap(jsonchamp.NewFromItems(items...)))
+		if f.Delete("absent") {
+			t.Fatal("Delete() of a missing field reported true")
+		}
+		if f.IsDirty() || len(f.Map().Keys()) != 12 {
+			t.Fatalf("Delete() of a missing field changed the feature: %v", f.Changes())
+		}
+	}
+}
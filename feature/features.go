@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mamaar/jsonchamp"
 )
@@ -17,6 +18,8 @@ type Feature struct {
 	schema        Schema
 	schemaVersion int
 	m             *jsonchamp.Map
+	// original is the map the feature was loaded with, used to track changes.
+	original *jsonchamp.Map
 }
 
 type Option func(*Feature)
@@ -27,6 +30,13 @@ func WithMap(m *jsonchamp.Map) Option {
 	}
 }
 
+// WithSchemaVersion sets the number of schema migrations already applied to the feature.
+func WithSchemaVersion(version int) Option {
+	return func(f *Feature) {
+		f.schemaVersion = version
+	}
+}
+
 func New(sch Schema, opts ...Option) *Feature {
 	f := &Feature{
 		schema: sch,
@@ -36,6 +46,7 @@ func New(sch Schema, opts ...Option) *Feature {
 	for _, opt := range opts {
 		opt(f)
 	}
+	f.original = f.m
 
 	return f
 }
@@ -48,10 +59,24 @@ func (f *Feature) Schema() Schema {
 	return f.schema
 }
 
+// SchemaVersion returns the number of schema migrations applied to the feature.
+func (f *Feature) SchemaVersion() int {
+	return f.schemaVersion
+}
+
 func (f *Feature) Set(key string, value any) {
 	f.m = f.m.Set(key, value)
 }
 
+// Delete removes a property from the feature and reports whether it existed.
+func (f *Feature) Delete(key string) bool {
+	m, deleted := deleteKey(f.m, key)
+	if deleted {
+		f.m = m
+	}
+	return deleted
+}
+
 func (f *Feature) Get(key string) (any, bool) {
 	return f.m.Get(key)
 }
@@ -89,6 +114,15 @@ func (f *Feature) GetInt(key string) (int64, error) {
 	return v, nil
 }
 
+// GetTime returns a timestamp property stored as an RFC 3339 string.
+func (f *Feature) GetTime(key string) (time.Time, error) {
+	v, err := f.GetString(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
 func (f *Feature) SetInt(key string, value int) error {
 	f.m = f.m.Set(key, value)
 	return nil
@@ -100,18 +134,33 @@ func (f *Feature) Migrate(s Schema) error {
 	if f.schemaVersion >= len(s.Migrations) {
 		return ErrSchemaVersionNotFound
 	}
-	for i, m := range s.Migrations[f.schemaVersion:] {
+	from := f.schemaVersion
+	for i, m := range s.Migrations[from:] {
 		for _, op := range m.Operations {
 			f.m, err = op.Apply(f.m)
 			if err != nil {
 				return err
 			}
 		}
-		f.schemaVersion = i + 1
+		f.schemaVersion = from + i + 1
 	}
 	return nil
 }
 
+// MarshalJSON encodes the feature in the envelope read by UnmarshalJSON, with the URN
+// and version of its schema next to the payload.
+func (f *Feature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SchemaURN     string         `json:"schema_urn"`
+		SchemaVersion int            `json:"schema_version"`
+		Payload       *jsonchamp.Map `json:"payload"`
+	}{
+		SchemaURN:     f.schema.Schema,
+		SchemaVersion: f.schemaVersion,
+		Payload:       f.m,
+	})
+}
+
 func (f *Feature) UnmarshalJSON(data []byte) error {
 	type decode struct {
 		SchemaURN     string          `json:"schema_urn"`
@@ -130,5 +179,7 @@ func (f *Feature) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	f.m = m
+	f.original = m
+	f.schemaVersion = d.SchemaVersion
 	return nil
 }
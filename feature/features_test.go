@@ -117,3 +117,35 @@ func TestFeatures(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFeatureMarshalJSON(t *testing.T) {
+	var sch Schema
+	if err := json.Unmarshal([]byte(orderSchemaMigrations), &sch); err != nil {
+		t.Fatal(err)
+	}
+	var f Feature
+	if err := json.Unmarshal([]byte(order1Data), &f); err != nil {
+		t.Fatal(err)
+	}
+	f = *New(sch, WithMap(f.Map()), WithSchemaVersion(1))
+
+	data, err := json.Marshal(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"schema_urn":"urn:features:order","schema_version":1,"payload":{"order_id":123.456}}`
+	if string(data) != want {
+		t.Fatalf("MarshalJSON() = %s, want %s", data, want)
+	}
+
+	var decoded Feature
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.SchemaVersion() != 1 {
+		t.Fatalf("SchemaVersion() = %d, want 1", decoded.SchemaVersion())
+	}
+	if id, _ := decoded.GetFloat("order_id"); id != 123.456 {
+		t.Fatalf("order_id = %v, want 123.456", id)
+	}
+}
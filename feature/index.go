@@ -0,0 +1,220 @@
+package feature
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+var (
+	ErrInvalidIndex = errors.New("invalid index")
+)
+
+// IndexPart is a component of an index key: either a literal or a property.
+type IndexPart struct {
+	Literal  string
+	Property string
+}
+
+// Index declares a key, such as a partition key, sort key or secondary index,
+// built from literals and properties of the feature.
+type Index struct {
+	Name  string
+	Parts []IndexPart
+}
+
+// Properties returns the properties the index is built from.
+func (i Index) Properties() []string {
+	var props []string
+	for _, p := range i.Parts {
+		if p.Property != "" {
+			props = append(props, p.Property)
+		}
+	}
+	return props
+}
+
+// AddIndex declares a new index on the schema.
+type AddIndex struct {
+	Index Index
+}
+
+func NewAddIndexFromMap(m *jsonchamp.Map) (AddIndex, error) {
+	indexDef, err := m.GetMap("index")
+	if err != nil {
+		return AddIndex{}, err
+	}
+	name, err := indexDef.GetString("name")
+	if err != nil {
+		return AddIndex{}, err
+	}
+	rawParts, ok := indexDef.Get("parts")
+	if !ok {
+		return AddIndex{}, fmt.Errorf("%w: index '%s' has no parts", ErrInvalidIndex, name)
+	}
+	partList, ok := rawParts.([]any)
+	if !ok {
+		return AddIndex{}, fmt.Errorf("%w: parts of index '%s' is not a list", ErrInvalidIndex, name)
+	}
+
+	parts := make([]IndexPart, len(partList))
+	for i, raw := range partList {
+		partMap, ok := raw.(*jsonchamp.Map)
+		if !ok {
+			return AddIndex{}, fmt.Errorf("%w: part %d of index '%s' is not a map", ErrInvalidIndex, i, name)
+		}
+		if literal, err := partMap.GetString("literal"); err == nil {
+			parts[i].Literal = literal
+		}
+		if property, err := partMap.GetString("property"); err == nil {
+			parts[i].Property = property
+		}
+	}
+
+	return AddIndex{Index: Index{Name: name, Parts: parts}}, nil
+}
+
+// Apply implements Operation. Indexes do not change the data model.
+func (a AddIndex) Apply(in *jsonchamp.Map) (*jsonchamp.Map, error) {
+	return in, nil
+}
+
+var _ Operation = AddIndex{}
+
+// RemoveIndex drops an index from the schema.
+type RemoveIndex struct {
+	Name string
+}
+
+// Apply implements Operation. Indexes do not change the data model.
+func (r RemoveIndex) Apply(in *jsonchamp.Map) (*jsonchamp.Map, error) {
+	return in, nil
+}
+
+var _ Operation = RemoveIndex{}
+
+// ReduceIndexes returns the indexes declared by the migrations, by name.
+func (m Migrations) ReduceIndexes() (map[string]Index, error) {
+	indexes := make(map[string]Index)
+	for _, migration := range m {
+		for _, op := range migration.Operations {
+			switch op := op.(type) {
+			case AddIndex:
+				idx := op.Index
+				if idx.Name == "" {
+					return nil, fmt.Errorf("%w: index name must not be empty", ErrInvalidIndex)
+				}
+				if _, exists := indexes[idx.Name]; exists {
+					return nil, fmt.Errorf("%w: index '%s' already exists", ErrInvalidIndex, idx.Name)
+				}
+				if len(idx.Parts) == 0 {
+					return nil, fmt.Errorf("%w: index '%s' has no parts", ErrInvalidIndex, idx.Name)
+				}
+				for i, part := range idx.Parts {
+					if (part.Literal == "") == (part.Property == "") {
+						return nil, fmt.Errorf("%w: part %d of index '%s' must be either a literal or a property", ErrInvalidIndex, i, idx.Name)
+					}
+				}
+				indexes[idx.Name] = idx
+			case RemoveIndex:
+				if _, exists := indexes[op.Name]; !exists {
+					return nil, fmt.Errorf("%w: index '%s' does not exist", ErrInvalidIndex, op.Name)
+				}
+				delete(indexes, op.Name)
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// Indexes returns the indexes declared by the schema, after checking that every property
+// they are built from exists in the reduced schema and is required.
+func (s Schema) Indexes() (map[string]Index, error) {
+	indexes, err := s.Migrations.ReduceIndexes()
+	if err != nil {
+		return nil, err
+	}
+	reduced, err := s.Migrations.Reduce()
+	if err != nil {
+		return nil, err
+	}
+	properties, err := reduced.GetMap("properties")
+	if err != nil {
+		return nil, err
+	}
+	required, _ := reduced.Get("required")
+	var requiredFields []string
+	switch r := required.(type) {
+	case []string:
+		requiredFields = r
+	case []any:
+		for _, name := range r {
+			if name, ok := name.(string); ok {
+				requiredFields = append(requiredFields, name)
+			}
+		}
+	}
+
+	for _, idx := range indexes {
+		for _, prop := range idx.Properties() {
+			if !properties.Contains(prop) {
+				return nil, fmt.Errorf("%w: index '%s' uses unknown property '%s'", ErrInvalidIndex, idx.Name, prop)
+			}
+			if !slices.Contains(requiredFields, prop) {
+				return nil, fmt.Errorf("%w: index '%s' uses optional property '%s'", ErrInvalidIndex, idx.Name, prop)
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// KeyTemplates returns a key template for every index of the schema. Properties are
+// encoded according to their field type, so integer and number keys sort numerically.
+func (s Schema) KeyTemplates() (map[string]*KeyTemplate, error) {
+	indexes, err := s.Indexes()
+	if err != nil {
+		return nil, err
+	}
+	intro := NewSchemaIntrospector(s)
+
+	templates := make(map[string]*KeyTemplate, len(indexes))
+	for name, idx := range indexes {
+		parts := make([]KeyPart, len(idx.Parts))
+		for i, part := range idx.Parts {
+			if part.Literal != "" {
+				parts[i] = LiteralPart(part.Literal)
+				continue
+			}
+			field, err := intro.GetField(part.Property)
+			if err != nil {
+				return nil, err
+			}
+			switch field.Type() {
+			case FieldTypeInteger:
+				parts[i] = IntPart(part.Property)
+			case FieldTypeNumber:
+				parts[i] = FloatPart(part.Property)
+			default:
+				parts[i] = PropPart(part.Property)
+			}
+		}
+		templates[name] = NewKeyTemplate(parts...)
+	}
+	return templates, nil
+}
+
+// IndexMap returns the key functions of every index of the schema, for use with
+// CreateKeyedPayload.
+func (s Schema) IndexMap() (map[string]KeyFunc, error) {
+	templates, err := s.KeyTemplates()
+	if err != nil {
+		return nil, err
+	}
+	indexMap := make(map[string]KeyFunc, len(templates))
+	for name, tmpl := range templates {
+		indexMap[name] = tmpl.KeyFunc()
+	}
+	return indexMap, nil
+}
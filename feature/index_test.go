@@ -0,0 +1,110 @@
+package feature
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+var orderIndexSchema = `{
+	"schema": "urn:features:order",
+	"migrations": [
+		{
+			"description": "Initial schema",
+			"operations": [
+				{"type": "add_field", "field": {"name": "order_id", "type": "string", "required": true}},
+				{"type": "add_field", "field": {"name": "customer_id", "type": "integer", "required": true}},
+				{"type": "add_field", "field": {"name": "note", "type": "string", "required": false}},
+				{"type": "add_index", "index": {"name": "pk", "parts": [{"literal": "ORDER"}, {"property": "order_id"}]}},
+				{"type": "add_index", "index": {"name": "gsi1", "parts": [{"property": "customer_id"}]}}
+			]
+		},
+		{
+			"description": "Replace gsi1",
+			"operations": [
+				{"type": "remove_index", "name": "gsi1"},
+				{"type": "add_index", "index": {"name": "gsi2", "parts": [{"literal": "CUST"}, {"property": "customer_id"}, {"property": "order_id"}]}}
+			]
+		}
+	]
+}`
+
+func TestSchemaIndexes(t *testing.T) {
+	var sch Schema
+	if err := json.Unmarshal([]byte(orderIndexSchema), &sch); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := sch.Indexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := indexes["gsi1"]; ok {
+		t.Fatal("gsi1 should have been removed")
+	}
+	if len(indexes) != 2 {
+		t.Fatalf("Indexes() = %v, want pk and gsi2", indexes)
+	}
+
+	// Index operations must not break the reduced JSON schema.
+	if _, err := sch.ToJSONSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	indexMap, err := sch.IndexMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(sch, WithMap(jsonchamp.NewFromItems("order_id", "A1", "customer_id", 42)))
+	payload, err := CreateKeyedPayload(f, indexMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk, _ := payload.GetString("pk"); pk != "ORDER:A1" {
+		t.Errorf("pk = %q, want %q", pk, "ORDER:A1")
+	}
+	if gsi2, _ := payload.GetString("gsi2"); gsi2 != "CUST:"+EncodeIntKey(42)+":A1" {
+		t.Errorf("gsi2 = %q, want customer and order", gsi2)
+	}
+}
+
+func TestSchemaIndexesValidation(t *testing.T) {
+	fields := []Operation{
+		AddField{Field: Field{Name: "id", Type: FieldTypeString, Required: true}},
+		AddField{Field: Field{Name: "note", Type: FieldTypeString}},
+	}
+	tests := []struct {
+		name string
+		ops  []Operation
+	}{
+		{
+			name: "unknown property",
+			ops:  []Operation{AddIndex{Index: Index{Name: "pk", Parts: []IndexPart{{Property: "missing"}}}}},
+		},
+		{
+			name: "optional property",
+			ops:  []Operation{AddIndex{Index: Index{Name: "pk", Parts: []IndexPart{{Property: "note"}}}}},
+		},
+		{
+			name: "duplicate index",
+			ops: []Operation{
+				AddIndex{Index: Index{Name: "pk", Parts: []IndexPart{{Property: "id"}}}},
+				AddIndex{Index: Index{Name: "pk", Parts: []IndexPart{{Property: "id"}}}},
+			},
+		},
+		{
+			name: "remove unknown index",
+			ops:  []Operation{RemoveIndex{Name: "pk"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sch := Schema{Migrations: Migrations{{Operations: append(fields, tt.ops...)}}}
+			if _, err := sch.Indexes(); !errors.Is(err, ErrInvalidIndex) {
+				t.Fatalf("Indexes() error = %v, want %v", err, ErrInvalidIndex)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package feature
 
+import "slices"
+
 type SchemaIntrospector struct {
 	sch Schema
 }
@@ -15,13 +17,24 @@ func NewSchemaIntrospector(sch Schema) *SchemaIntrospector {
 	}
 }
 
+// GetField returns the named field of the current schema version, as last altered. A field
+// that has been removed does not exist, unless it is added again by a later migration.
 func (i *SchemaIntrospector) GetField(name string) (IntrospectedField, error) {
 	var field Field
 	for _, mig := range i.sch.Migrations {
 		for _, op := range mig.Operations {
-			if af, ok := op.(AddField); ok {
-				if af.Field.Name == name {
-					field = af.Field
+			switch op := op.(type) {
+			case AddField:
+				if op.Field.Name == name {
+					field = op.Field
+				}
+			case AlterField:
+				if op.Field.Name == name && field.Name != "" {
+					field = op.Field
+				}
+			case RemoveField:
+				if op.FieldName == name {
+					field = Field{}
 				}
 			}
 		}
@@ -39,3 +52,96 @@ func (f *IntrospectedField) Exists() bool {
 func (f *IntrospectedField) Type() FieldType {
 	return f.field.Type
 }
+
+// Counter returns true if the field is marked as a counter.
+func (f *IntrospectedField) Counter() bool {
+	return f.field.Counter
+}
+
+// Fields returns the fields of the current schema version, in the order they were added,
+// as last altered.
+func (i *SchemaIntrospector) Fields() []IntrospectedField {
+	var fields []IntrospectedField
+	for _, mig := range i.sch.Migrations {
+		for _, op := range mig.Operations {
+			switch op := op.(type) {
+			case AddField:
+				fields = append(fields, IntrospectedField{exists: true, field: op.Field})
+			case AlterField:
+				if i := slices.IndexFunc(fields, func(f IntrospectedField) bool { return f.field.Name == op.Field.Name }); i >= 0 {
+					fields[i].field = op.Field
+				}
+			case RemoveField:
+				fields = slices.DeleteFunc(fields, func(f IntrospectedField) bool {
+					return f.field.Name == op.FieldName
+				})
+			}
+		}
+	}
+	return fields
+}
+
+func (f *IntrospectedField) Name() string {
+	return f.field.Name
+}
+
+func (f *IntrospectedField) Required() bool {
+	return f.field.Required
+}
+
+// Format returns the JSON Schema format of the field, if any.
+func (f *IntrospectedField) Format() string {
+	return f.field.Format
+}
+
+// Enum returns the values the field may take, if restricted.
+func (f *IntrospectedField) Enum() []any {
+	return f.field.Enum
+}
+
+// Minimum returns the inclusive lower bound of the field, if any.
+func (f *IntrospectedField) Minimum() *float64 {
+	return f.field.Minimum
+}
+
+// Maximum returns the inclusive upper bound of the field, if any.
+func (f *IntrospectedField) Maximum() *float64 {
+	return f.field.Maximum
+}
+
+// Pattern returns the regular expression the field must match, if any.
+func (f *IntrospectedField) Pattern() string {
+	return f.field.Pattern
+}
+
+// Property returns a field of an object field.
+func (f *IntrospectedField) Property(name string) IntrospectedField {
+	for _, p := range f.field.Properties {
+		if p.Name == name {
+			return IntrospectedField{exists: true, field: p}
+		}
+	}
+	return IntrospectedField{}
+}
+
+// Properties returns the fields of an object field.
+func (f *IntrospectedField) Properties() []IntrospectedField {
+	props := make([]IntrospectedField, len(f.field.Properties))
+	for i, p := range f.field.Properties {
+		props[i] = IntrospectedField{exists: true, field: p}
+	}
+	return props
+}
+
+// Items returns the description of the elements of an array field.
+func (f *IntrospectedField) Items() IntrospectedField {
+	if f.field.Items == nil {
+		return IntrospectedField{}
+	}
+	return IntrospectedField{exists: true, field: *f.field.Items}
+}
+
+// Field returns the definition of the field.
+func (f *IntrospectedField) Field() Field {
+	return f.field
+}
@@ -36,3 +36,117 @@ func TestGetField(t *testing.T) {
 	}
 
 }
+
+func TestGetFieldCounter(t *testing.T) {
+	var m Migration
+	err := m.UnmarshalJSON([]byte(`{
+		"description": "Add views",
+		"operations": [
+			{"type": "add_field", "field": {"name": "views", "type": "integer", "required": false, "counter": true}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intro := NewSchemaIntrospector(Schema{Migrations: Migrations{&m}})
+
+	field, err := intro.GetField("views")
+	if err != nil {
+		t.Fatalf("GetField(%q) = %v; want nil", "views", err)
+	}
+
+	if !field.Counter() {
+		t.Fatalf("GetField(%q).Counter() = false; want true", "views")
+	}
+}
+
+func TestFields(t *testing.T) {
+	minimum := 1.0
+	sch := Schema{
+		Migrations: Migrations{
+			{
+				Operations: []Operation{
+					AddField{Field: Field{Name: "a", Type: FieldTypeString, Required: true}},
+					AddField{Field: Field{Name: "b", Type: FieldTypeInteger, Minimum: &minimum}},
+				},
+			},
+			{
+				Operations: []Operation{
+					RemoveField{FieldName: "a"},
+					AddField{Field: Field{Name: "c", Type: FieldTypeBoolean}},
+				},
+			},
+		},
+	}
+
+	fields := NewSchemaIntrospector(sch).Fields()
+	if len(fields) != 2 || fields[0].Name() != "b" || fields[1].Name() != "c" {
+		t.Fatalf("Fields() = %v; want b and c", fields)
+	}
+	if m := fields[0].Minimum(); m == nil || *m != 1 {
+		t.Errorf("Fields()[0].Minimum() = %v; want 1", m)
+	}
+}
+
+func TestGetFieldRemoved(t *testing.T) {
+	sch := Schema{
+		Migrations: Migrations{
+			{Operations: []Operation{
+				AddField{Field: Field{Name: "name", Type: FieldTypeString}},
+				AddField{Field: Field{Name: "age", Type: FieldTypeInteger}},
+			}},
+			{Operations: []Operation{
+				RemoveField{FieldName: "name"},
+				RemoveField{FieldName: "age"},
+			}},
+			{Operations: []Operation{
+				AddField{Field: Field{Name: "age", Type: FieldTypeNumber}},
+			}},
+		},
+	}
+	intro := NewSchemaIntrospector(sch)
+
+	name, err := intro.GetField("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name.Exists() {
+		t.Fatalf("GetField(%q).Exists() = true; want false", "name")
+	}
+
+	age, err := intro.GetField("age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !age.Exists() || age.Type() != FieldTypeNumber {
+		t.Fatalf("GetField(%q) = %v, %v; want the field added again as %v", "age", age.Exists(), age.Type(), FieldTypeNumber)
+	}
+}
+
+func TestGetFieldAltered(t *testing.T) {
+	sch := Schema{
+		Migrations: Migrations{
+			{Operations: []Operation{AddField{Field: Field{Name: "count", Type: FieldTypeString}}}},
+			{Operations: []Operation{
+				AlterField{Field: Field{Name: "count", Type: FieldTypeInteger}},
+				AlterField{Field: Field{Name: "missing", Type: FieldTypeInteger}},
+			}},
+		},
+	}
+	intro := NewSchemaIntrospector(sch)
+
+	count, err := intro.GetField("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Type() != FieldTypeInteger {
+		t.Fatalf("GetField(%q).Type() = %v; want %v", "count", count.Type(), FieldTypeInteger)
+	}
+	if fields := intro.Fields(); len(fields) != 1 || fields[0].Type() != FieldTypeInteger {
+		t.Fatalf("Fields() = %v; want count as an integer", fields)
+	}
+	if missing, _ := intro.GetField("missing"); missing.Exists() {
+		t.Fatalf("GetField(%q).Exists() = true; want false", "missing")
+	}
+}
@@ -0,0 +1,216 @@
+package feature
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKeyTemplate = errors.New("invalid key template")
+)
+
+// keyFormatter transforms the string value of a property in a key template.
+type keyFormatter func(string) (string, error)
+
+type keySegment struct {
+	literal    string
+	field      string
+	formatters []keyFormatter
+}
+
+// CompileKeyTemplate compiles a key template string into a KeyFunc.
+//
+// Text outside braces is copied literally, and {name} is replaced with the value of the
+// property. A property can be followed by formatters separated by colons, some of which
+// take an argument after an equals sign:
+//
+//	ORDER#{order_id}#{created_at:date}
+//	CUST#{email:lower:hash=12}#{seq:pad=8}
+//
+// The formatters are year, month, date and hour, which truncate RFC 3339 timestamps,
+// lower and upper, hash, which replaces the value with hex digits of its FNV-1a hash
+// (8 by default), and pad, which left pads the value with zeros. A backslash escapes
+// the next character.
+//
+// Every property must be a field known to the schema.
+func CompileKeyTemplate(sch Schema, tmpl string) (KeyFunc, error) {
+	segments, err := parseKeyTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	intro := NewSchemaIntrospector(sch)
+	for i, seg := range segments {
+		if seg.field == "" {
+			continue
+		}
+		name, formatters, _ := strings.Cut(seg.field, ":")
+		field, err := intro.GetField(name)
+		if err != nil {
+			return nil, err
+		}
+		if !field.Exists() {
+			return nil, fmt.Errorf("%w: %q refers to unknown field %q", ErrInvalidKeyTemplate, tmpl, name)
+		}
+		segments[i].field = name
+		if formatters == "" {
+			continue
+		}
+		for _, spec := range strings.Split(formatters, ":") {
+			format, err := newKeyFormatter(spec, field.Type())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: field %q: %w", ErrInvalidKeyTemplate, tmpl, name, err)
+			}
+			segments[i].formatters = append(segments[i].formatters, format)
+		}
+	}
+
+	return func(f *Feature) (Key, error) {
+		var b strings.Builder
+		for _, seg := range segments {
+			if seg.field == "" {
+				b.WriteString(seg.literal)
+				continue
+			}
+			value, err := keyValueString(f, seg.field)
+			if err != nil {
+				return "", err
+			}
+			for _, format := range seg.formatters {
+				value, err = format(value)
+				if err != nil {
+					return "", fmt.Errorf("field %q: %w", seg.field, err)
+				}
+			}
+			b.WriteString(value)
+		}
+		return Key(b.String()), nil
+	}, nil
+}
+
+func parseKeyTemplate(tmpl string) ([]keySegment, error) {
+	var segments []keySegment
+	var current strings.Builder
+	inField := false
+
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(tmpl) {
+				return nil, fmt.Errorf("%w: %q ends with an escape character", ErrInvalidKeyTemplate, tmpl)
+			}
+			i++
+			current.WriteByte(tmpl[i])
+		case c == '{' && !inField:
+			if current.Len() > 0 {
+				segments = append(segments, keySegment{literal: current.String()})
+				current.Reset()
+			}
+			inField = true
+		case c == '{':
+			return nil, fmt.Errorf("%w: %q has a nested '{' at position %d", ErrInvalidKeyTemplate, tmpl, i)
+		case c == '}' && inField:
+			if current.Len() == 0 {
+				return nil, fmt.Errorf("%w: %q has an empty field at position %d", ErrInvalidKeyTemplate, tmpl, i)
+			}
+			segments = append(segments, keySegment{field: current.String()})
+			current.Reset()
+			inField = false
+		case c == '}':
+			return nil, fmt.Errorf("%w: %q has an unmatched '}' at position %d", ErrInvalidKeyTemplate, tmpl, i)
+		default:
+			current.WriteByte(c)
+		}
+	}
+	if inField {
+		return nil, fmt.Errorf("%w: %q has an unterminated field", ErrInvalidKeyTemplate, tmpl)
+	}
+	if current.Len() > 0 {
+		segments = append(segments, keySegment{literal: current.String()})
+	}
+	return segments, nil
+}
+
+func newKeyFormatter(spec string, typ FieldType) (keyFormatter, error) {
+	name, arg, hasArg := strings.Cut(spec, "=")
+	n := 0
+	if hasArg {
+		var err error
+		n, err = strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("formatter %q needs a positive number", name)
+		}
+	}
+
+	switch name {
+	case "year", "month", "date", "hour":
+		if typ != FieldTypeString {
+			return nil, fmt.Errorf("formatter %q needs a string field, got %s", name, typ)
+		}
+		layout := map[string]string{
+			"year":  "2006",
+			"month": "2006-01",
+			"date":  "2006-01-02",
+			"hour":  "2006-01-02T15",
+		}[name]
+		return func(v string) (string, error) {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", err
+			}
+			return t.UTC().Format(layout), nil
+		}, nil
+	case "lower":
+		return func(v string) (string, error) { return strings.ToLower(v), nil }, nil
+	case "upper":
+		return func(v string) (string, error) { return strings.ToUpper(v), nil }, nil
+	case "hash":
+		if !hasArg {
+			n = 8
+		}
+		if n > 16 {
+			return nil, fmt.Errorf("formatter %q supports at most 16 digits", name)
+		}
+		return func(v string) (string, error) {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(v))
+			return fmt.Sprintf("%016x", h.Sum64())[:n], nil
+		}, nil
+	case "pad":
+		if !hasArg {
+			return nil, fmt.Errorf("formatter %q needs a width", name)
+		}
+		return func(v string) (string, error) {
+			if len(v) >= n {
+				return v, nil
+			}
+			return strings.Repeat("0", n-len(v)) + v, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown formatter %q", name)
+	}
+}
+
+func keyValueString(f *Feature, name string) (string, error) {
+	v, ok := f.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPropertyNotFound, name)
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("%w: %s is %T", ErrInvalidKey, name, v)
+	}
+}
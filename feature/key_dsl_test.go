@@ -0,0 +1,80 @@
+package feature
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestCompileKeyTemplate(t *testing.T) {
+	sch := Schema{
+		Migrations: Migrations{
+			{Operations: []Operation{
+				AddField{Field: Field{Name: "order_id", Type: FieldTypeString}},
+				AddField{Field: Field{Name: "created_at", Type: FieldTypeString}},
+				AddField{Field: Field{Name: "email", Type: FieldTypeString}},
+				AddField{Field: Field{Name: "seq", Type: FieldTypeInteger}},
+			}},
+		},
+	}
+	f := New(sch, WithMap(jsonchamp.NewFromItems(
+		"order_id", "A1",
+		"created_at", "2024-03-01T23:30:00-02:00",
+		"email", "Alice@Example.com",
+		"seq", 42,
+	)))
+
+	tests := []struct {
+		name string
+		tmpl string
+		want Key
+	}{
+		{name: "fields and literals", tmpl: "ORDER#{order_id}", want: "ORDER#A1"},
+		{name: "date truncation in UTC", tmpl: "{created_at:date}", want: "2024-03-02"},
+		{name: "month truncation", tmpl: "{created_at:month}", want: "2024-03"},
+		{name: "lowercase", tmpl: "{email:lower}", want: "alice@example.com"},
+		{name: "chained formatters", tmpl: "{email:lower:hash=4}", want: "6702"},
+		{name: "padding", tmpl: "{seq:pad=6}", want: "000042"},
+		{name: "escaped braces", tmpl: `\{{order_id}\}`, want: "{A1}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyFn, err := CompileKeyTemplate(sch, tt.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := keyFn(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileKeyTemplateErrors(t *testing.T) {
+	sch := Schema{
+		Migrations: Migrations{
+			{Operations: []Operation{
+				AddField{Field: Field{Name: "seq", Type: FieldTypeInteger}},
+			}},
+		},
+	}
+	for _, tmpl := range []string{
+		"{missing}",
+		"{seq:date}",
+		"{seq:bogus}",
+		"{seq:pad}",
+		"{seq",
+		"seq}",
+		"{}",
+		"{{seq}}",
+	} {
+		if _, err := CompileKeyTemplate(sch, tmpl); !errors.Is(err, ErrInvalidKeyTemplate) {
+			t.Errorf("CompileKeyTemplate(%q) error = %v, want %v", tmpl, err, ErrInvalidKeyTemplate)
+		}
+	}
+}
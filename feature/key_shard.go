@@ -0,0 +1,50 @@
+package feature
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// ShardKey returns a KeyFunc that derives a bucket number in [0, buckets) from a hash
+// of the given properties. The same property values always map to the same bucket.
+// Bucket numbers are zero padded to the width of the largest bucket, so they sort
+// in numeric order.
+func ShardKey(buckets int, propNames ...string) KeyFunc {
+	return func(f *Feature) (Key, error) {
+		if buckets <= 0 {
+			return "", fmt.Errorf("%w: number of buckets must be positive, got %d", ErrInvalidKey, buckets)
+		}
+		h := fnv.New64a()
+		for _, name := range propNames {
+			value, err := keyValueString(f, name)
+			if err != nil {
+				return "", err
+			}
+			_, _ = h.Write([]byte(escapeKeyComponent(value)))
+			_, _ = h.Write([]byte(KeySeparator))
+		}
+		return bucketKey(int(h.Sum64()%uint64(buckets)), buckets), nil
+	}
+}
+
+// ShardedKey appends the bucket of ShardKey to a logical key, spreading writes for
+// the same logical key over several partitions.
+func ShardedKey(logical KeyFunc, buckets int, propNames ...string) KeyFunc {
+	return CompositeKey(logical, ShardKey(buckets, propNames...))
+}
+
+// ShardKeys returns every bucket key of a logical key sharded with ShardedKey, so that
+// readers can fan out over all shards.
+func ShardKeys(logical Key, buckets int) []Key {
+	keys := make([]Key, 0, max(buckets, 0))
+	for bucket := range buckets {
+		keys = append(keys, logical+KeySeparator+bucketKey(bucket, buckets))
+	}
+	return keys
+}
+
+func bucketKey(bucket int, buckets int) Key {
+	width := len(strconv.Itoa(buckets - 1))
+	return Key(fmt.Sprintf("%0*d", width, bucket))
+}
@@ -0,0 +1,49 @@
+package feature
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestShardKey(t *testing.T) {
+	const buckets = 16
+	keyFn := ShardedKey(CompositeKey(LiteralKey("ORDER"), PropKey("day")), buckets, "order_id")
+	all := ShardKeys("ORDER:2024-03-01", buckets)
+
+	if len(all) != buckets || all[0] != "ORDER:2024-03-01:00" || all[buckets-1] != "ORDER:2024-03-01:15" {
+		t.Fatalf("ShardKeys() = %v", all)
+	}
+
+	seen := make(map[Key]bool)
+	for i := range 200 {
+		f := New(Schema{}, WithMap(jsonchamp.NewFromItems("day", "2024-03-01", "order_id", fmt.Sprint(i))))
+		k, err := keyFn(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(all, k) {
+			t.Fatalf("key %q is not one of ShardKeys()", k)
+		}
+		again, _ := keyFn(f)
+		if again != k {
+			t.Fatalf("key is not stable: %q and %q", k, again)
+		}
+		seen[k] = true
+	}
+	if len(seen) != buckets {
+		t.Fatalf("200 orders used %d of %d buckets", len(seen), buckets)
+	}
+}
+
+func TestShardKeyErrors(t *testing.T) {
+	f := New(Schema{}, WithMap(jsonchamp.NewFromItems("id", "1")))
+	if _, err := ShardKey(0, "id")(f); err == nil {
+		t.Fatal("ShardKey() with zero buckets should fail")
+	}
+	if _, err := ShardKey(4, "missing")(f); err == nil {
+		t.Fatal("ShardKey() with missing property should fail")
+	}
+}
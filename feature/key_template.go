@@ -0,0 +1,135 @@
+package feature
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// KeyPart is a single component of a KeyTemplate.
+type KeyPart interface {
+	// Name returns the name of the component in parsed keys. Literal parts have no name.
+	Name() string
+	// Build returns the unescaped component for the feature.
+	Build(f *Feature) (string, error)
+	// Parse decodes an unescaped component.
+	Parse(s string) (any, error)
+}
+
+type literalPart struct {
+	value string
+}
+
+// LiteralPart is a key component with a fixed value, such as an entity prefix.
+func LiteralPart(value string) KeyPart {
+	return literalPart{value: value}
+}
+
+func (p literalPart) Name() string {
+	return ""
+}
+
+func (p literalPart) Build(*Feature) (string, error) {
+	return p.value, nil
+}
+
+func (p literalPart) Parse(s string) (any, error) {
+	if s != p.value {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrInvalidKey, p.value, s)
+	}
+	return s, nil
+}
+
+type propPart struct {
+	name string
+}
+
+// PropPart is a key component holding the value of a string property.
+func PropPart(name string) KeyPart {
+	return propPart{name: name}
+}
+
+func (p propPart) Name() string {
+	return p.name
+}
+
+func (p propPart) Build(f *Feature) (string, error) {
+	return f.GetString(p.name)
+}
+
+func (p propPart) Parse(s string) (any, error) {
+	return s, nil
+}
+
+// KeyTemplate describes the layout of a composite key. Unlike CompositeKey, a template
+// can also parse keys back into their named components. Separators inside component
+// values are escaped so that parsing is never ambiguous.
+type KeyTemplate struct {
+	parts []KeyPart
+}
+
+// NewKeyTemplate creates a template from its parts.
+func NewKeyTemplate(parts ...KeyPart) *KeyTemplate {
+	return &KeyTemplate{parts: parts}
+}
+
+// Parts returns the parts of the template.
+func (t *KeyTemplate) Parts() []KeyPart {
+	return t.parts
+}
+
+// Build returns the key for the feature.
+func (t *KeyTemplate) Build(f *Feature) (Key, error) {
+	components := make([]string, 0, len(t.parts))
+	for _, part := range t.parts {
+		c, err := part.Build(f)
+		if err != nil {
+			return "", err
+		}
+		components = append(components, escapeKeyComponent(c))
+	}
+	return Key(strings.Join(components, KeySeparator)), nil
+}
+
+// KeyFunc returns a KeyFunc that builds keys from the template.
+func (t *KeyTemplate) KeyFunc() KeyFunc {
+	return t.Build
+}
+
+// Parse splits a key into its named components. Literal parts must match exactly.
+func (t *KeyTemplate) Parse(k Key) (map[string]any, error) {
+	components := strings.Split(string(k), KeySeparator)
+	if len(components) != len(t.parts) {
+		return nil, fmt.Errorf("%w: %q has %d components, want %d", ErrInvalidKey, k, len(components), len(t.parts))
+	}
+
+	res := make(map[string]any)
+	for i, part := range t.parts {
+		v, err := part.Parse(unescapeKeyComponent(components[i]))
+		if err != nil {
+			return nil, err
+		}
+		if part.Name() != "" {
+			res[part.Name()] = v
+		}
+	}
+	return res, nil
+}
+
+var (
+	// The separator is percent-encoded inside components, as is the percent sign itself.
+	keyEscaper   = strings.NewReplacer("%", "%25", KeySeparator, "%3A")
+	keyUnescaper = strings.NewReplacer("%25", "%", "%3A", KeySeparator)
+)
+
+func escapeKeyComponent(s string) string {
+	return keyEscaper.Replace(s)
+}
+
+func unescapeKeyComponent(s string) string {
+	return keyUnescaper.Replace(s)
+}
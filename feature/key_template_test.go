@@ -0,0 +1,70 @@
+package feature
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	tmpl := NewKeyTemplate(LiteralPart("ORDER"), PropPart("order_id"), PropPart("year"))
+
+	tests := []struct {
+		name    string
+		feat    *Feature
+		want    Key
+		wantErr bool
+	}{
+		{
+			name: "plain values",
+			feat: New(Schema{}, WithMap(jsonchamp.NewFromItems("order_id", "123", "year", "2024"))),
+			want: "ORDER:123:2024",
+		},
+		{
+			name: "separator in value is escaped",
+			feat: New(Schema{}, WithMap(jsonchamp.NewFromItems("order_id", "a:b%c", "year", "2024"))),
+			want: "ORDER:a%3Ab%25c:2024",
+		},
+		{
+			name:    "missing property",
+			feat:    New(Schema{}, WithMap(jsonchamp.NewFromItems("order_id", "123"))),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tmpl.KeyFunc()(tt.feat)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("Build() = %v, want %v", got, tt.want)
+			}
+
+			parsed, err := tmpl.Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"order_id", "year"} {
+				want, _ := tt.feat.GetString(name)
+				if parsed[name] != want {
+					t.Errorf("Parse()[%q] = %v, want %v", name, parsed[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyTemplateParseErrors(t *testing.T) {
+	tmpl := NewKeyTemplate(LiteralPart("ORDER"), PropPart("order_id"))
+
+	for _, k := range []Key{"ORDER", "ORDER:1:2", "CUSTOMER:1"} {
+		if _, err := tmpl.Parse(k); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Parse(%q) error = %v, want %v", k, err, ErrInvalidKey)
+		}
+	}
+}
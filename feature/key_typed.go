@@ -0,0 +1,169 @@
+package feature
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// The encodings below are order preserving: sorting encoded components as strings
+// gives the same order as sorting the values they encode.
+
+// KeyTimeFormat is the layout of timestamps in keys. It has a fixed width and no
+// separators, and is always in UTC.
+const KeyTimeFormat = "20060102T150405.000000000Z"
+
+type intPart struct {
+	name string
+}
+
+// IntPart is a key component holding an integer property, encoded as 16 hex digits with
+// the sign bit flipped so negative values sort before positive ones.
+func IntPart(name string) KeyPart {
+	return intPart{name: name}
+}
+
+func (p intPart) Name() string {
+	return p.name
+}
+
+func (p intPart) Build(f *Feature) (string, error) {
+	v, err := f.GetInt(p.name)
+	if err != nil {
+		return "", err
+	}
+	return EncodeIntKey(v), nil
+}
+
+func (p intPart) Parse(s string) (any, error) {
+	return DecodeIntKey(s)
+}
+
+type floatPart struct {
+	name string
+}
+
+// FloatPart is a key component holding a number property, encoded as the 16 hex digits
+// of its IEEE 754 bits, transformed so that the encoding sorts in numeric order.
+func FloatPart(name string) KeyPart {
+	return floatPart{name: name}
+}
+
+func (p floatPart) Name() string {
+	return p.name
+}
+
+func (p floatPart) Build(f *Feature) (string, error) {
+	v, ok := f.Get(p.name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPropertyNotFound, p.name)
+	}
+	switch v := v.(type) {
+	case float64:
+		return EncodeFloatKey(v), nil
+	case int64:
+		return EncodeFloatKey(float64(v)), nil
+	default:
+		return "", fmt.Errorf("%w: %s is %T, not a number", ErrInvalidKey, p.name, v)
+	}
+}
+
+func (p floatPart) Parse(s string) (any, error) {
+	return DecodeFloatKey(s)
+}
+
+type timePart struct {
+	name string
+}
+
+// TimePart is a key component holding an RFC 3339 timestamp property, encoded with
+// KeyTimeFormat.
+func TimePart(name string) KeyPart {
+	return timePart{name: name}
+}
+
+func (p timePart) Name() string {
+	return p.name
+}
+
+func (p timePart) Build(f *Feature) (string, error) {
+	t, err := f.GetTime(p.name)
+	if err != nil {
+		return "", err
+	}
+	return EncodeTimeKey(t), nil
+}
+
+func (p timePart) Parse(s string) (any, error) {
+	return DecodeTimeKey(s)
+}
+
+// IntKey returns a KeyFunc for an integer property, see IntPart.
+func IntKey(propName string) KeyFunc {
+	return NewKeyTemplate(IntPart(propName)).KeyFunc()
+}
+
+// FloatKey returns a KeyFunc for a number property, see FloatPart.
+func FloatKey(propName string) KeyFunc {
+	return NewKeyTemplate(FloatPart(propName)).KeyFunc()
+}
+
+// TimeKey returns a KeyFunc for a timestamp property, see TimePart.
+func TimeKey(propName string) KeyFunc {
+	return NewKeyTemplate(TimePart(propName)).KeyFunc()
+}
+
+// EncodeIntKey encodes an integer key component, see IntPart.
+func EncodeIntKey(v int64) string {
+	return fmt.Sprintf("%016x", uint64(v)^(1<<63))
+}
+
+// DecodeIntKey decodes a component encoded by EncodeIntKey.
+func DecodeIntKey(s string) (int64, error) {
+	u, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || len(s) != 16 {
+		return 0, fmt.Errorf("%w: invalid integer component %q", ErrInvalidKey, s)
+	}
+	return int64(u ^ (1 << 63)), nil
+}
+
+// EncodeFloatKey encodes a number key component, see FloatPart.
+func EncodeFloatKey(v float64) string {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		// Negative numbers sort in reverse, so flip every bit.
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%016x", bits)
+}
+
+// DecodeFloatKey decodes a component encoded by EncodeFloatKey.
+func DecodeFloatKey(s string) (float64, error) {
+	bits, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || len(s) != 16 {
+		return 0, fmt.Errorf("%w: invalid number component %q", ErrInvalidKey, s)
+	}
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), nil
+}
+
+// EncodeTimeKey encodes a timestamp key component, see TimePart.
+func EncodeTimeKey(t time.Time) string {
+	return t.UTC().Format(KeyTimeFormat)
+}
+
+// DecodeTimeKey decodes a component encoded by EncodeTimeKey.
+func DecodeTimeKey(s string) (time.Time, error) {
+	t, err := time.Parse(KeyTimeFormat, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid time component %q", ErrInvalidKey, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,96 @@
+package feature
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestIntKeyOrder(t *testing.T) {
+	values := []int64{math.MinInt64, -1000, -1, 0, 1, 9, 10, 1000, math.MaxInt64}
+	assertSortedEncoding(t, values, EncodeIntKey, DecodeIntKey)
+}
+
+func TestFloatKeyOrder(t *testing.T) {
+	values := []float64{math.Inf(-1), -1e10, -2.5, -0.001, 0, 0.001, 1, 2.5, 10, 1e10, math.Inf(1)}
+	assertSortedEncoding(t, values, EncodeFloatKey, DecodeFloatKey)
+}
+
+func TestTimeKeyOrder(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	values := []time.Time{
+		base.Add(-48 * time.Hour),
+		base,
+		base.Add(time.Nanosecond),
+		base.Add(time.Second),
+		base.AddDate(1, 0, 0),
+	}
+	assertSortedEncoding(t, values, EncodeTimeKey, DecodeTimeKey)
+}
+
+func assertSortedEncoding[T comparable](t *testing.T, values []T, encode func(T) string, decode func(string) (T, error)) {
+	t.Helper()
+
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		encoded[i] = encode(v)
+		decoded, err := decode(encoded[i])
+		if err != nil {
+			t.Fatalf("decode(%q) error = %v", encoded[i], err)
+		}
+		if decoded != v {
+			t.Fatalf("decode(encode(%v)) = %v", v, decoded)
+		}
+	}
+	if !slices.IsSorted(encoded) {
+		t.Fatalf("encoded values are not sorted: %v", encoded)
+	}
+}
+
+func TestTypedKeyTemplate(t *testing.T) {
+	tmpl := NewKeyTemplate(LiteralPart("ORDER"), IntPart("customer_id"), TimePart("created_at"), FloatPart("total"))
+	f := New(Schema{}, WithMap(jsonchamp.NewFromItems(
+		"customer_id", 42,
+		"created_at", "2024-03-01T12:00:00+01:00",
+		"total", 99.5,
+	)))
+
+	k, err := tmpl.Build(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := tmpl.Parse(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed["customer_id"] != int64(42) {
+		t.Errorf("customer_id = %v, want 42", parsed["customer_id"])
+	}
+	if want := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC); !parsed["created_at"].(time.Time).Equal(want) {
+		t.Errorf("created_at = %v, want %v", parsed["created_at"], want)
+	}
+	if parsed["total"] != 99.5 {
+		t.Errorf("total = %v, want 99.5", parsed["total"])
+	}
+}
+
+func TestTypedKeyFuncs(t *testing.T) {
+	f := New(Schema{}, WithMap(jsonchamp.NewFromItems("n", 7, "x", 1)))
+
+	got, err := CompositeKey(LiteralKey("N"), IntKey("n"), FloatKey("x"))(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Key("N:" + EncodeIntKey(7) + ":" + EncodeFloatKey(1))
+	if got != want {
+		t.Fatalf("CompositeKey() = %v, want %v", got, want)
+	}
+
+	if _, err := IntKey("missing")(f); err == nil {
+		t.Fatal("IntKey() on missing property should fail")
+	}
+}
@@ -25,6 +25,9 @@ const (
 	FieldTypeString  FieldType = "string"
 	FieldTypeNumber  FieldType = "number"
 	FieldTypeInteger FieldType = "integer"
+	FieldTypeBoolean FieldType = "boolean"
+	FieldTypeObject  FieldType = "object"
+	FieldTypeArray   FieldType = "array"
 )
 
 type Field struct {
@@ -32,6 +35,22 @@ type Field struct {
 	Type     FieldType
 	Required bool
 	Default  any
+	// Counter marks a numeric field that is only ever incremented or decremented,
+	// which lets replicas merge concurrent updates by summing them.
+	Counter bool
+	// Format is the JSON Schema format of a string field, such as "date" or "email".
+	Format string
+	// Enum lists the values the field may take.
+	Enum []any
+	// Minimum and Maximum bound a numeric field, inclusively.
+	Minimum *float64
+	Maximum *float64
+	// Pattern is a regular expression a string field must match.
+	Pattern string
+	// Properties are the fields of an object field.
+	Properties []Field
+	// Items describes the elements of an array field.
+	Items *Field
 }
 
 type AddField struct {
@@ -60,14 +79,119 @@ func NewAddFieldFromMap(m *jsonchamp.Map) (AddField, error) {
 	if !ok {
 		def = nil
 	}
-	return AddField{
-		Field: Field{
-			Name:     name,
-			Type:     FieldType(typ),
-			Required: required,
-			Default:  def,
-		},
-	}, nil
+	counter, err := fieldDef.GetBool("counter")
+	if err != nil && !errors.Is(err, jsonchamp.ErrKeyNotFound) {
+		return AddField{}, err
+	}
+	field := Field{
+		Name:     name,
+		Type:     FieldType(typ),
+		Required: required,
+		Default:  def,
+		Counter:  counter,
+	}
+	if err := parseFieldConstraints(fieldDef, &field); err != nil {
+		return AddField{}, fmt.Errorf("field '%s': %w", name, err)
+	}
+	if err := parseNestedFields(fieldDef, &field); err != nil {
+		return AddField{}, fmt.Errorf("field '%s': %w", name, err)
+	}
+	return AddField{Field: field}, nil
+}
+
+// parseNestedFields reads the properties of an object field and the items of an array
+// field. Nested fields have the same shape as top-level fields, except that "required"
+// is optional and items have no name.
+func parseNestedFields(fieldDef *jsonchamp.Map, field *Field) error {
+	if props, ok := fieldDef.Get("properties"); ok {
+		list, ok := props.([]any)
+		if !ok {
+			return fmt.Errorf("properties must be a list, got %T", props)
+		}
+		for i, raw := range list {
+			def, ok := raw.(*jsonchamp.Map)
+			if !ok {
+				return fmt.Errorf("property %d is not a map", i)
+			}
+			prop, err := newNestedFieldFromMap(def)
+			if err != nil {
+				return err
+			}
+			if prop.Name == "" {
+				return fmt.Errorf("property %d has no name", i)
+			}
+			field.Properties = append(field.Properties, prop)
+		}
+	}
+	if items, ok := fieldDef.Get("items"); ok {
+		def, ok := items.(*jsonchamp.Map)
+		if !ok {
+			return fmt.Errorf("items must be a map, got %T", items)
+		}
+		item, err := newNestedFieldFromMap(def)
+		if err != nil {
+			return err
+		}
+		field.Items = &item
+	}
+	return nil
+}
+
+func newNestedFieldFromMap(def *jsonchamp.Map) (Field, error) {
+	var field Field
+	var err error
+	if field.Name, err = def.GetString("name"); err != nil && !errors.Is(err, jsonchamp.ErrKeyNotFound) {
+		return Field{}, err
+	}
+	typ, err := def.GetString("type")
+	if err != nil {
+		return Field{}, err
+	}
+	field.Type = FieldType(typ)
+	if field.Required, err = def.GetBool("required"); err != nil && !errors.Is(err, jsonchamp.ErrKeyNotFound) {
+		return Field{}, err
+	}
+	if err := parseFieldConstraints(def, &field); err != nil {
+		return Field{}, err
+	}
+	if err := parseNestedFields(def, &field); err != nil {
+		return Field{}, fmt.Errorf("field '%s': %w", field.Name, err)
+	}
+	return field, nil
+}
+
+// parseFieldConstraints reads the optional constraints of a field definition.
+func parseFieldConstraints(fieldDef *jsonchamp.Map, field *Field) error {
+	var err error
+	if field.Format, err = fieldDef.GetString("format"); err != nil && !errors.Is(err, jsonchamp.ErrKeyNotFound) {
+		return err
+	}
+	if field.Pattern, err = fieldDef.GetString("pattern"); err != nil && !errors.Is(err, jsonchamp.ErrKeyNotFound) {
+		return err
+	}
+	if enum, ok := fieldDef.Get("enum"); ok {
+		values, ok := enum.([]any)
+		if !ok {
+			return fmt.Errorf("enum must be a list, got %T", enum)
+		}
+		field.Enum = values
+	}
+	for name, bound := range map[string]**float64{"minimum": &field.Minimum, "maximum": &field.Maximum} {
+		v, ok := fieldDef.Get(name)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case int64:
+			f := float64(v)
+			*bound = &f
+		case float64:
+			*bound = &v
+		default:
+			return fmt.Errorf("%s must be a number, got %T", name, v)
+		}
+	}
+	return nil
 }
 
 // Apply implements Operation.
@@ -90,30 +214,80 @@ func (a AddField) Apply(in *jsonchamp.Map) (*jsonchamp.Map, error) {
 
 var _ Operation = AddField{}
 
+// jsonSchema returns the JSON schema of the field's values.
+func (f Field) jsonSchema() *jsonchamp.Map {
+	prop := jsonchamp.NewFromItems("type", string(f.Type))
+	if f.Format != "" {
+		prop = prop.Set("format", f.Format)
+	}
+	if len(f.Enum) > 0 {
+		prop = prop.Set("enum", f.Enum)
+	}
+	if f.Minimum != nil {
+		prop = prop.Set("minimum", *f.Minimum)
+	}
+	if f.Maximum != nil {
+		prop = prop.Set("maximum", *f.Maximum)
+	}
+	if f.Pattern != "" {
+		prop = prop.Set("pattern", f.Pattern)
+	}
+	if len(f.Properties) > 0 {
+		properties := jsonchamp.New()
+		required := []string{}
+		for _, p := range f.Properties {
+			properties = properties.Set(p.Name, p.jsonSchema())
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+		prop = prop.Set("properties", properties).Set("required", required)
+	}
+	if f.Items != nil {
+		prop = prop.Set("items", f.Items.jsonSchema())
+	}
+	return prop
+}
+
 type AlterField struct {
 	Field Field
 }
 
-// Apply implements Operation.
-func (a AlterField) Apply(*jsonchamp.Map) (*jsonchamp.Map, error) {
-	panic("unimplemented")
+// Apply implements Operation. Like AddField, it sets the default of the field when the
+// feature has no value for it. Existing values are kept as they are, so a feature whose
+// value does not match the altered field fails validation rather than being converted.
+func (a AlterField) Apply(in *jsonchamp.Map) (*jsonchamp.Map, error) {
+	return AddField(a).Apply(in)
 }
 
 var _ Operation = AlterField{}
 
+// RemoveField drops a field from the schema and its value from features. Removing a
+// field that does not exist is an error, both when reducing the schema and when
+// migrating a feature, and never touches the other fields.
 type RemoveField struct {
 	FieldName string
 }
 
 // Apply implements Operation.
 func (r RemoveField) Apply(in *jsonchamp.Map) (*jsonchamp.Map, error) {
-	n, wasDeleted := in.Delete(r.FieldName)
+	n, wasDeleted := deleteKey(in, r.FieldName)
 	if !wasDeleted {
 		return nil, fmt.Errorf("field '%s' does not exist", r.FieldName)
 	}
 	return n, nil
 }
 
+// deleteKey removes a key from the map and reports whether it existed. The key is looked
+// up first, because jsonchamp's Delete of a missing key can remove another key in the
+// same hash slot and still report success.
+func deleteKey(m *jsonchamp.Map, key string) (*jsonchamp.Map, bool) {
+	if !m.Contains(key) {
+		return m, false
+	}
+	return m.Delete(key)
+}
+
 var _ Operation = RemoveField{}
 
 type Migration struct {
@@ -144,6 +318,18 @@ func unmarshalOperations(ops []any) ([]Operation, error) {
 		case "remove_field":
 			var r RemoveField
 			opsRes[i] = r
+		case "add_index":
+			addIndex, err := NewAddIndexFromMap(opMap)
+			if err != nil {
+				return nil, err
+			}
+			opsRes[i] = addIndex
+		case "remove_index":
+			name, err := opMap.GetString("name")
+			if err != nil {
+				return nil, err
+			}
+			opsRes[i] = RemoveIndex{Name: name}
 		default:
 			return nil, fmt.Errorf("operation not implemented: %s", opType)
 		}
@@ -206,19 +392,38 @@ func (m Migrations) Reduce() (*jsonchamp.Map, error) {
 					return nil, fmt.Errorf("required field must have a default value: %s", op.Field.Name)
 				}
 
-				properties = properties.Set(field.Name, jsonchamp.NewFromItems("type", string(field.Type)))
+				properties = properties.Set(field.Name, field.jsonSchema())
 				if op.Field.Required {
 					required = required.Set(field.Name, true)
 				}
 
+			case AlterField:
+				field := op.Field
+				if !properties.Contains(field.Name) {
+					return nil, fmt.Errorf("field '%s' does not exist", field.Name)
+				}
+				// A field that becomes required needs a default for the features without it.
+				if field.Required && field.Default == nil && !required.Contains(field.Name) {
+					return nil, fmt.Errorf("required field must have a default value: %s", field.Name)
+				}
+				properties = properties.Set(field.Name, field.jsonSchema())
+				if field.Required {
+					required = required.Set(field.Name, true)
+				} else {
+					required, _ = deleteKey(required, field.Name)
+				}
+
 			case RemoveField:
 				field := op.FieldName
 				var propertyWasDeleted bool
-				properties, propertyWasDeleted = properties.Delete(field)
+				properties, propertyWasDeleted = deleteKey(properties, field)
 				if !propertyWasDeleted {
 					return nil, fmt.Errorf("field '%s' does not exist", field)
 				}
-				required, _ = required.Delete(field)
+				required, _ = deleteKey(required, field)
+
+			case AddIndex, RemoveIndex:
+				// Indexes do not affect the shape of the data, see ReduceIndexes.
 
 			default:
 				return nil, fmt.Errorf("operation not implemented: %T", op)
@@ -1,7 +1,12 @@
 package feature
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+
+	"github.com/mamaar/jsonchamp"
 )
 
 func TestBuildsSchema(t *testing.T) {
@@ -47,3 +52,184 @@ func TestRemoveFieldMigration(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRemoveOptionalFieldKeepsRequired(t *testing.T) {
+	migrations := Migrations{
+		{Operations: []Operation{
+			AddField{Field: Field{Name: "sku", Type: FieldTypeString, Required: true}},
+			AddField{Field: Field{Name: "note", Type: FieldTypeString}},
+		}},
+		{Operations: []Operation{
+			AddField{Field: Field{Name: "count", Type: FieldTypeInteger, Required: true, Default: 1}},
+			RemoveField{FieldName: "note"},
+		}},
+	}
+
+	// Map hashes are seeded per map, so try enough times to hit shared hash slots.
+	for range 200 {
+		reduced, err := migrations.Reduce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		required, _ := reduced.Get("required")
+		if got := len(required.([]any)); got != 2 {
+			t.Fatalf("expected sku and count to stay required, got %v", required)
+		}
+	}
+}
+
+func TestRemoveFieldApplyKeepsOthers(t *testing.T) {
+	items := []any{}
+	for i := range 12 {
+		items = append(items, fmt.Sprintf("field%d", i), i)
+	}
+
+	// Map hashes are seeded per map, so try enough maps to hit shared hash slots.
+	for range 500 {
+		in := jsonchamp.NewFromItems(items...)
+
+		out, err := RemoveField{FieldName: "field3"}.Apply(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Contains("field3") || len(out.Keys()) != 11 {
+			t.Fatalf("removing field3 from %v left %v", in.Keys(), out.Keys())
+		}
+
+		if _, err := (RemoveField{FieldName: "absent"}).Apply(in); err == nil {
+			t.Fatal("expected an error when removing a missing field")
+		}
+		if len(in.Keys()) != 12 {
+			t.Fatalf("failed remove changed the input to %v", in.Keys())
+		}
+	}
+}
+
+func TestReduceRemoveMissingField(t *testing.T) {
+	migrations := Migrations{
+		{Operations: []Operation{
+			AddField{Field: Field{Name: "sku", Type: FieldTypeString, Required: true}},
+		}},
+		{Operations: []Operation{
+			RemoveField{FieldName: "note"},
+		}},
+	}
+
+	for range 200 {
+		if _, err := migrations.Reduce(); err == nil {
+			t.Fatal("expected an error when removing a field the schema does not have")
+		}
+	}
+}
+
+func TestReduceAlterField(t *testing.T) {
+	migrations := Migrations{
+		{Operations: []Operation{
+			AddField{Field: Field{Name: "sku", Type: FieldTypeString, Required: true}},
+			AddField{Field: Field{Name: "count", Type: FieldTypeString}},
+		}},
+		{Operations: []Operation{
+			AlterField{Field: Field{Name: "count", Type: FieldTypeInteger, Required: true, Default: 1}},
+			AlterField{Field: Field{Name: "sku", Type: FieldTypeString}},
+		}},
+	}
+	reduced, err := migrations.Reduce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _ := reduced.GetMap("properties")
+	if typ, _ := count.GetMap("count"); typ == nil {
+		t.Fatal("expected count to stay a property")
+	} else if got, _ := typ.GetString("type"); got != "integer" {
+		t.Fatalf("expected count to become an integer, got %q", got)
+	}
+	required, _ := reduced.Get("required")
+	if r := required.([]any); len(r) != 1 || r[0] != "count" {
+		t.Fatalf("expected only count to be required, got %v", required)
+	}
+
+	for name, op := range map[string]AlterField{
+		"missing field":            {Field: Field{Name: "missing", Type: FieldTypeString}},
+		"required without default": {Field: Field{Name: "count", Type: FieldTypeString, Required: true}},
+	} {
+		invalid := Migrations{migrations[0], {Operations: []Operation{op}}}
+		if _, err := invalid.Reduce(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNestedFields(t *testing.T) {
+	var sch Schema
+	err := json.Unmarshal([]byte(`{
+		"schema": "urn:features:order",
+		"migrations": [{
+			"description": "Initial schema",
+			"operations": [
+				{"type": "add_field", "field": {"name": "items", "type": "array", "required": false, "items": {
+					"type": "object",
+					"properties": [
+						{"name": "sku", "type": "string", "required": true},
+						{"name": "count", "type": "integer", "minimum": 1}
+					]
+				}}}
+			]
+		}]
+	}`), &sch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intro := NewSchemaIntrospector(sch)
+	items, _ := intro.GetField("items")
+	item := items.Items()
+	sku := item.Property("sku")
+	if item.Type() != FieldTypeObject || !sku.Required() {
+		t.Fatalf("expected object items with a required sku, got %+v", item)
+	}
+
+	validator, err := sch.ToJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := New(sch, WithMap(jsonchamp.NewFromItems("items", []any{jsonchamp.NewFromItems("sku", "A", "count", 2)})))
+	if err := validator.Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := New(sch, WithMap(jsonchamp.NewFromItems("items", []any{jsonchamp.NewFromItems("count", 0)})))
+	var verr *ValidationError
+	if err := validator.Validate(invalid); !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if len(verr.Errors) != 2 {
+		t.Fatalf("expected missing sku and too small count, got %v", verr)
+	}
+	for _, fe := range verr.Errors {
+		if len(fe.Path) != 3 || fe.Path[0] != "items" || fe.Path[1] != "0" {
+			t.Fatalf("expected errors below items/0, got %v", fe.Path)
+		}
+	}
+}
+
+func TestAlterFieldApply(t *testing.T) {
+	op := AlterField{Field: Field{Name: "count", Type: FieldTypeInteger, Required: true, Default: 1}}
+
+	out, err := op.Apply(jsonchamp.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := out.GetInt("count"); got != 1 {
+		t.Fatalf("expected the default for a missing value, got %v", got)
+	}
+	out, err = op.Apply(jsonchamp.NewFromItems("count", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := out.Get("count"); got != "3" {
+		t.Fatalf("expected an existing value to be kept, got %v", got)
+	}
+	if _, err := (AlterField{Field: Field{Name: "count", Type: FieldTypeInteger, Required: true}}).Apply(jsonchamp.New()); err == nil {
+		t.Fatal("expected an error for a missing required value without default")
+	}
+}
@@ -0,0 +1,431 @@
+package feature
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test failed")
+)
+
+// PatchOperation is a single JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a JSON Patch (RFC 6902) document.
+type Patch []PatchOperation
+
+type patchOptions struct {
+	validate bool
+}
+
+type PatchOption func(*patchOptions)
+
+// WithPatchValidation validates the patched feature against its schema before the
+// patch is committed.
+func WithPatchValidation() PatchOption {
+	return func(o *patchOptions) {
+		o.validate = true
+	}
+}
+
+// ApplyPatch applies a JSON Patch to the feature. The patch is atomic: if any
+// operation fails, or validation is requested and fails, the feature is left unchanged.
+func (f *Feature) ApplyPatch(p Patch, opts ...PatchOption) error {
+	var doc any = f.m
+	for i, op := range p {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	m, ok := doc.(*jsonchamp.Map)
+	if !ok {
+		return fmt.Errorf("%w: patched document is not an object", ErrInvalidPatch)
+	}
+	return f.commit(m, opts)
+}
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7386) to the feature. Like ApplyPatch,
+// the feature is left unchanged if the patch fails.
+func (f *Feature) ApplyMergePatch(data []byte, opts ...PatchOption) error {
+	patch, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	if _, ok := patch.(*jsonchamp.Map); !ok {
+		return fmt.Errorf("%w: merge patch is not an object", ErrInvalidPatch)
+	}
+
+	m, _ := mergePatch(f.m, patch).(*jsonchamp.Map)
+	return f.commit(m, opts)
+}
+
+func (f *Feature) commit(m *jsonchamp.Map, opts []PatchOption) error {
+	var o patchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.validate {
+		validator, err := f.schema.ToJSONSchema()
+		if err != nil {
+			return err
+		}
+		candidate := &Feature{schema: f.schema, schemaVersion: f.schemaVersion, m: m}
+		if err := validator.Validate(candidate); err != nil {
+			return err
+		}
+	}
+
+	f.m = m
+	return nil
+}
+
+// CreatePatch returns a JSON Patch that turns from into to.
+// Lists are compared as a whole and replaced when they differ.
+func CreatePatch(from *Feature, to *Feature) (Patch, error) {
+	return diffPatch(nil, from.m, from.m.Diff(to.m), to.m)
+}
+
+// CreateMergePatch returns a JSON Merge Patch that turns from into to.
+func CreateMergePatch(from *Feature, to *Feature) ([]byte, error) {
+	return json.Marshal(from.m.Diff(to.m))
+}
+
+func diffPatch(path []string, from *jsonchamp.Map, diff *jsonchamp.Map, to *jsonchamp.Map) (Patch, error) {
+	var p Patch
+
+	keys := diff.Keys()
+	slices.Sort(keys)
+	for _, k := range keys {
+		keyPath := append(slices.Clone(path), k)
+		pointer := formatPointer(keyPath)
+
+		toValue, inTo := to.Get(k)
+		fromValue, inFrom := from.Get(k)
+		switch {
+		case !inTo:
+			p = append(p, PatchOperation{Op: "remove", Path: pointer})
+		case !inFrom:
+			raw, err := json.Marshal(toValue)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, PatchOperation{Op: "add", Path: pointer, Value: raw})
+		default:
+			fromMap, fromIsMap := fromValue.(*jsonchamp.Map)
+			toMap, toIsMap := toValue.(*jsonchamp.Map)
+			subDiff, err := diff.GetMap(k)
+			if fromIsMap && toIsMap && err == nil {
+				sub, err := diffPatch(keyPath, fromMap, subDiff, toMap)
+				if err != nil {
+					return nil, err
+				}
+				p = append(p, sub...)
+				continue
+			}
+			raw, err := json.Marshal(toValue)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, PatchOperation{Op: "replace", Path: pointer, Value: raw})
+		}
+	}
+
+	return p, nil
+}
+
+func mergePatch(target any, patch any) any {
+	patchMap, ok := patch.(*jsonchamp.Map)
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(*jsonchamp.Map)
+	if !ok {
+		targetMap = jsonchamp.New()
+	}
+
+	for _, k := range patchMap.Keys() {
+		v, _ := patchMap.Get(k)
+		if v == nil {
+			targetMap, _ = deleteKey(targetMap, k)
+			continue
+		}
+		current, _ := targetMap.Get(k)
+		targetMap = targetMap.Set(k, mergePatch(current, v))
+	}
+	return targetMap
+}
+
+func applyOperation(doc any, op PatchOperation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := decodePatchValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, path, value, true)
+	case "remove":
+		return removePointer(doc, path)
+	case "replace":
+		value, err := decodePatchValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, path, value, false)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+			}
+			doc, err = removePointer(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return setPointer(doc, path, value, true)
+	case "test":
+		value, err := decodePatchValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		current, err := getPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonchamp.NewFromItems("v", current).Equals(jsonchamp.NewFromItems("v", value)) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// decodePatchValue decodes a JSON value into the types used by jsonchamp.
+func decodePatchValue(raw json.RawMessage) (any, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+	}
+	return decodeJSON(raw)
+}
+
+// decodeJSON decodes a JSON document into the types used by jsonchamp, keeping nulls,
+// which a merge patch uses to remove fields.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	v, err := fromJSON(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return v, nil
+}
+
+func fromJSON(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		m := jsonchamp.New()
+		for k, item := range v {
+			item, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			m = m.Set(k, item)
+		}
+		return m, nil
+	case []any:
+		for i, item := range v {
+			item, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+		return v, nil
+	case json.Number:
+		if strings.Contains(string(v), ".") {
+			return v.Float64()
+		}
+		return v.Int64()
+	}
+	return v, nil
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid pointer %q", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func formatPointer(path []string) string {
+	var b strings.Builder
+	for _, t := range path {
+		b.WriteString("/")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func getPointer(doc any, path []string) (any, error) {
+	for i, token := range path {
+		switch d := doc.(type) {
+		case *jsonchamp.Map:
+			v, ok := d.Get(token)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, formatPointer(path[:i+1]))
+			}
+			doc = v
+		case []any:
+			idx, err := arrayIndex(token, len(d))
+			if err != nil {
+				return nil, err
+			}
+			doc = d[idx]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, formatPointer(path[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// setPointer returns a copy of doc with value set at path. When insert is true the
+// value is added, otherwise an existing value is replaced.
+func setPointer(doc any, path []string, value any, insert bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch d := doc.(type) {
+	case *jsonchamp.Map:
+		child, ok := d.Get(token)
+		if !ok && (len(rest) > 0 || !insert) {
+			return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, token)
+		}
+		newChild, err := setPointer(child, rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		return d.Set(token, newChild), nil
+	case []any:
+		if len(rest) == 0 && insert {
+			idx := len(d)
+			if token != "-" {
+				var err error
+				idx, err = arrayIndex(token, len(d)+1)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return slices.Insert(slices.Clone(d), idx, value), nil
+		}
+		idx, err := arrayIndex(token, len(d))
+		if err != nil {
+			return nil, err
+		}
+		newChild, err := setPointer(d[idx], rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		res := slices.Clone(d)
+		res[idx] = newChild
+		return res, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, token)
+	}
+}
+
+// removePointer returns a copy of doc without the value at path.
+func removePointer(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the root", ErrInvalidPatch)
+	}
+	token, rest := path[0], path[1:]
+
+	switch d := doc.(type) {
+	case *jsonchamp.Map:
+		if len(rest) == 0 {
+			res, ok := deleteKey(d, token)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, token)
+			}
+			return res, nil
+		}
+		child, ok := d.Get(token)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, token)
+		}
+		newChild, err := removePointer(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		return d.Set(token, newChild), nil
+	case []any:
+		idx, err := arrayIndex(token, len(d))
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			return slices.Delete(slices.Clone(d), idx, idx+1), nil
+		}
+		newChild, err := removePointer(d[idx], rest)
+		if err != nil {
+			return nil, err
+		}
+		res := slices.Clone(d)
+		res[idx] = newChild
+		return res, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrPropertyNotFound, token)
+	}
+}
+
+func arrayIndex(token string, length int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, token)
+	}
+	return idx, nil
+}
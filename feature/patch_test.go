@@ -0,0 +1,204 @@
+package feature
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      *jsonchamp.Map
+		patch   string
+		want    *jsonchamp.Map
+		wantErr bool
+	}{
+		{
+			name:  "add field",
+			in:    jsonchamp.NewFromItems("a", 1),
+			patch: `[{"op": "add", "path": "/b", "value": "x"}]`,
+			want:  jsonchamp.NewFromItems("a", 1, "b", "x"),
+		},
+		{
+			name:  "replace nested field",
+			in:    jsonchamp.NewFromItems("a", jsonchamp.NewFromItems("b", 1)),
+			patch: `[{"op": "replace", "path": "/a/b", "value": 2}]`,
+			want:  jsonchamp.NewFromItems("a", jsonchamp.NewFromItems("b", 2)),
+		},
+		{
+			name:  "remove field",
+			in:    jsonchamp.NewFromItems("a", 1, "b", 2),
+			patch: `[{"op": "remove", "path": "/b"}]`,
+			want:  jsonchamp.NewFromItems("a", 1),
+		},
+		{
+			name:  "array insert and append",
+			in:    jsonchamp.NewFromItems("l", []any{1, 3}),
+			patch: `[{"op": "add", "path": "/l/1", "value": 2}, {"op": "add", "path": "/l/-", "value": 4}]`,
+			want:  jsonchamp.NewFromItems("l", []any{1, 2, 3, 4}),
+		},
+		{
+			name:  "move and copy",
+			in:    jsonchamp.NewFromItems("a", 1, "b", 2),
+			patch: `[{"op": "move", "from": "/a", "path": "/c"}, {"op": "copy", "from": "/b", "path": "/d"}]`,
+			want:  jsonchamp.NewFromItems("b", 2, "c", 1, "d", 2),
+		},
+		{
+			name:  "escaped pointer",
+			in:    jsonchamp.NewFromItems("a/b", 1),
+			patch: `[{"op": "replace", "path": "/a~1b", "value": 2}]`,
+			want:  jsonchamp.NewFromItems("a/b", 2),
+		},
+		{
+			name:    "failing test leaves feature unchanged",
+			in:      jsonchamp.NewFromItems("a", 1),
+			patch:   `[{"op": "replace", "path": "/a", "value": 2}, {"op": "test", "path": "/a", "value": 1}]`,
+			want:    jsonchamp.NewFromItems("a", 1),
+			wantErr: true,
+		},
+		{
+			name:    "replace missing field",
+			in:      jsonchamp.NewFromItems("a", 1),
+			patch:   `[{"op": "replace", "path": "/b", "value": 2}]`,
+			want:    jsonchamp.NewFromItems("a", 1),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Patch
+			if err := json.Unmarshal([]byte(tt.patch), &p); err != nil {
+				t.Fatal(err)
+			}
+			f := New(Schema{}, WithMap(tt.in))
+			err := f.ApplyPatch(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !f.Map().Equals(tt.want) {
+				t.Fatalf("ApplyPatch() = %v, want %v", f.Map(), tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchValidation(t *testing.T) {
+	sch := Schema{
+		Migrations: Migrations{
+			{Operations: []Operation{
+				AddField{Field: Field{Name: "count", Type: FieldTypeInteger, Required: true}},
+			}},
+		},
+	}
+	f := New(sch, WithMap(jsonchamp.NewFromItems("count", 1)))
+
+	p := Patch{{Op: "replace", Path: "/count", Value: json.RawMessage(`"many"`)}}
+	if err := f.ApplyPatch(p, WithPatchValidation()); err == nil {
+		t.Fatal("ApplyPatch() should fail validation")
+	}
+	if got, _ := f.GetInt("count"); got != 1 {
+		t.Fatalf("count = %d, want 1", got)
+	}
+
+	err := f.ApplyMergePatch([]byte(`{"count": null}`), WithPatchValidation())
+	if err == nil {
+		t.Fatal("ApplyMergePatch() should fail validation")
+	}
+	if !f.Map().Contains("count") {
+		t.Fatal("count should not be removed")
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	f := New(Schema{}, WithMap(jsonchamp.NewFromItems(
+		"a", 1,
+		"b", jsonchamp.NewFromItems("c", 1, "d", 2),
+	)))
+
+	err := f.ApplyMergePatch([]byte(`{"a": null, "b": {"c": 3}, "e": [1]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := jsonchamp.NewFromItems(
+		"b", jsonchamp.NewFromItems("c", 3, "d", 2),
+		"e", []any{1},
+	)
+	if !f.Map().Equals(want) {
+		t.Fatalf("ApplyMergePatch() = %v, want %v", f.Map(), want)
+	}
+
+	if err := f.ApplyMergePatch([]byte(`not json`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("ApplyMergePatch() error = %v, want %v", err, ErrInvalidPatch)
+	}
+}
+
+func TestRemoveMissingFieldKeepsOthers(t *testing.T) {
+	items := []any{}
+	for i := range 12 {
+		items = append(items, fmt.Sprintf("field%d", i), i)
+	}
+
+	// Map hashes are seeded per map, so try enough maps to hit shared hash slots.
+	for range 500 {
+		in := jsonchamp.NewFromItems(items...)
+
+		f := New(Schema{}, WithMap(in))
+		if err := f.ApplyMergePatch([]byte(`{"absent": null}`)); err != nil {
+			t.Fatal(err)
+		}
+		if !f.Map().Equals(in) {
+			t.Fatalf("merge patch removing a missing field changed %v to %v", in.Keys(), f.Map().Keys())
+		}
+
+		f = New(Schema{}, WithMap(in))
+		err := f.ApplyPatch(Patch{{Op: "remove", Path: "/absent"}})
+		if !errors.Is(err, ErrPropertyNotFound) {
+			t.Fatalf("ApplyPatch() error = %v, want %v", err, ErrPropertyNotFound)
+		}
+		if !f.Map().Equals(in) {
+			t.Fatalf("failed remove changed %v to %v", in.Keys(), f.Map().Keys())
+		}
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	from := New(Schema{}, WithMap(jsonchamp.NewFromItems(
+		"a", 1,
+		"b", jsonchamp.NewFromItems("c", 1, "d", 2),
+		"l", []any{1, 2},
+	)))
+	to := New(Schema{}, WithMap(jsonchamp.NewFromItems(
+		"b", jsonchamp.NewFromItems("c", 2, "d", 2),
+		"l", []any{1, 2, 3},
+		"n", "new",
+	)))
+
+	p, err := CreatePatch(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched := New(Schema{}, WithMap(from.Map()))
+	if err := patched.ApplyPatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if !patched.Map().Equals(to.Map()) {
+		t.Fatalf("CreatePatch() applied = %v, want %v", patched.Map(), to.Map())
+	}
+
+	mp, err := CreateMergePatch(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := New(Schema{}, WithMap(from.Map()))
+	if err := merged.ApplyMergePatch(mp); err != nil {
+		t.Fatal(err)
+	}
+	if !merged.Map().Equals(to.Map()) {
+		t.Fatalf("CreateMergePatch() applied = %v, want %v", merged.Map(), to.Map())
+	}
+}
@@ -41,6 +41,9 @@ func (s Schema) Migrate(m *jsonchamp.Map) (*jsonchamp.Map, error) {
 	return res, nil
 }
 
+// ToJSONSchema compiles the reduced schema into a Validator. Formats such as "email" or
+// "date-time" are asserted, so a value in the wrong format fails validation rather than
+// being treated as an annotation.
 func (s Schema) ToJSONSchema() (*Validator, error) {
 	schemaMap, err := s.Migrations.Reduce()
 	if err != nil {
@@ -56,6 +59,7 @@ func (s Schema) ToJSONSchema() (*Validator, error) {
 	}
 
 	c := jsonschema.NewCompiler()
+	c.AssertFormat()
 	err = c.AddResource("schema", sch)
 	if err != nil {
 		return nil, err
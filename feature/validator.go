@@ -4,12 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/santhosh-tekuri/jsonschema/v6/kind"
-
-	"github.com/mamaar/jsonchamp"
 )
 
 type Validator struct {
@@ -22,6 +21,9 @@ func NewValidator(s *jsonschema.Schema) *Validator {
 	}
 }
 
+// Validate checks the feature against the schema. When values are invalid, the error is
+// a *ValidationError listing each of them with its path, and it matches ErrValidation
+// with errors.Is.
 func (v *Validator) Validate(m *Feature) error {
 	js, err := json.Marshal(m.m)
 	if err != nil {
@@ -37,26 +39,84 @@ func (v *Validator) Validate(m *Feature) error {
 	}
 	var validationErr *jsonschema.ValidationError
 	if errors.As(err, &validationErr) {
-		validationErrors := jsonchamp.New()
-		for _, cause := range validationErr.Causes {
-			switch k := cause.ErrorKind.(type) {
-			case *kind.Type:
-				location := cause.InstanceLocation[0]
-				validationErrors = validationErrors.Set(location, jsonchamp.NewFromItems("got", k.Got, "expect", k.Want))
-			case *kind.Required:
-				for _, missing := range k.Missing {
-					validationErrors = validationErrors.Set(missing, jsonchamp.NewFromItems("required", true))
-				}
-			default:
-				panic("unexpected error kind")
-			}
+		res := &ValidationError{}
+		collectFieldErrors(validationErr, res)
+		if len(res.Errors) > 0 {
+			return res
 		}
-		if len(validationErrors.Keys()) > 0 {
-			return fmt.Errorf("validation errors")
+	}
+	return err
+}
+
+var (
+	ErrValidation = errors.New("validation errors")
+)
+
+// FieldError describes why a value failed validation.
+type FieldError struct {
+	// Path is the location of the value, starting with the field name.
+	Path    []string
+	Message string
+}
+
+// Field returns the name of the top-level field the error belongs to.
+func (e FieldError) Field() string {
+	if len(e.Path) == 0 {
+		return ""
+	}
+	return e.Path[0]
+}
+
+// ValidationError lists every value of a feature that failed validation.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fmt.Sprintf("%s: %s", strings.Join(fe.Path, "."), fe.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+func collectFieldErrors(verr *jsonschema.ValidationError, res *ValidationError) {
+	if len(verr.Causes) > 0 {
+		for _, cause := range verr.Causes {
+			collectFieldErrors(cause, res)
 		}
+		return
 	}
-	if err != nil {
-		return err
+
+	path := verr.InstanceLocation
+	switch k := verr.ErrorKind.(type) {
+	case *kind.Type:
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must be %s, got %s", strings.Join(k.Want, " or "), k.Got)})
+	case *kind.Required:
+		for _, missing := range k.Missing {
+			res.Errors = append(res.Errors, FieldError{Path: append(slices.Clone(path), missing), Message: "is required"})
+		}
+	case *kind.Enum:
+		want := make([]string, len(k.Want))
+		for i, v := range k.Want {
+			want[i] = fmt.Sprint(v)
+		}
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must be one of %s", strings.Join(want, ", "))})
+	case *kind.Minimum:
+		want, _ := k.Want.Float64()
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must be at least %v", want)})
+	case *kind.Maximum:
+		want, _ := k.Want.Float64()
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must be at most %v", want)})
+	case *kind.Pattern:
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must match %s", k.Want)})
+	case *kind.Format:
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: fmt.Sprintf("must be a valid %s", k.Want)})
+	default:
+		res.Errors = append(res.Errors, FieldError{Path: path, Message: "is invalid"})
 	}
-	return nil
 }
@@ -0,0 +1,72 @@
+package feature
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+var constrainedSchema = `{
+	"schema": "urn:features:booking",
+	"migrations": [
+		{
+			"description": "Initial schema",
+			"operations": [
+				{"type": "add_field", "field": {"name": "guest", "type": "string", "required": true, "pattern": "^[A-Z]"}},
+				{"type": "add_field", "field": {"name": "nights", "type": "integer", "required": true, "minimum": 1, "maximum": 14}},
+				{"type": "add_field", "field": {"name": "room", "type": "string", "required": false, "enum": ["single", "double"]}},
+				{"type": "add_field", "field": {"name": "arrival", "type": "string", "required": false, "format": "date"}},
+				{"type": "add_field", "field": {"name": "breakfast", "type": "boolean", "required": false}}
+			]
+		}
+	]
+}`
+
+func TestValidateConstraints(t *testing.T) {
+	var sch Schema
+	if err := json.Unmarshal([]byte(constrainedSchema), &sch); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := sch.ToJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := New(sch, WithMap(jsonchamp.NewFromItems("guest", "Ada", "nights", 3, "room", "double", "arrival", "2024-05-01", "breakfast", true)))
+	if err := validator.Validate(valid); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	invalid := New(sch, WithMap(jsonchamp.NewFromItems("guest", "ada", "nights", 30, "room", "suite", "arrival", "May 1st", "breakfast", "yes")))
+	err = validator.Validate(invalid)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("Validate() = %v, want %v", err, ErrValidation)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() = %T, want *ValidationError", err)
+	}
+	fields := make(map[string]string)
+	for _, fe := range verr.Errors {
+		fields[fe.Field()] = fe.Message
+	}
+	want := map[string]string{
+		"guest":     "must match ^[A-Z]",
+		"nights":    "must be at most 14",
+		"room":      "must be one of single, double",
+		"arrival":   "must be a valid date",
+		"breakfast": "must be boolean, got string",
+	}
+	for field, msg := range want {
+		if fields[field] != msg {
+			t.Errorf("error for %s = %q, want %q", field, fields[field], msg)
+		}
+	}
+
+	missing := New(sch, WithMap(jsonchamp.NewFromItems("guest", "Ada")))
+	if err := validator.Validate(missing); !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Field() != "nights" {
+		t.Fatalf("Validate() = %v, want nights to be required", err)
+	}
+}
@@ -0,0 +1,144 @@
+package form
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrUnknownField           = errors.New("unknown field")
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrInvalidValue           = errors.New("invalid value")
+	ErrRequired               = errors.New("required field can not be cleared")
+)
+
+// DefaultMaxMemory is the number of bytes of a multipart body kept in memory, as in
+// http.Request.ParseMultipartForm.
+const DefaultMaxMemory = 32 << 20
+
+// WithMaxMemory sets the number of bytes of a multipart body kept in memory; the rest
+// of the file parts is stored on disk.
+func WithMaxMemory(n int64) Option {
+	return func(f *Form) {
+		f.maxMemory = n
+	}
+}
+
+// BindRequest sets the form values from the body of an HTTP request. JSON bodies must be
+// an object of field values, which are coerced to the field types: a numeric string is
+// accepted for an integer field, and null removes the field, or clears it in Partial
+// mode. Objects and arrays are coerced element by element, and errors name the values in
+// bracket notation. URL encoded and multipart bodies are bound like SetFromUrlValues;
+// multipart file parts are ignored.
+func (f *Form) BindRequest(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	switch mediaType {
+	case "application/json":
+		return f.bindJSON(r)
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		return f.SetFromUrlValues(r.PostForm)
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(f.maxMemory); err != nil {
+			return err
+		}
+		return f.SetFromUrlValues(url.Values(r.MultipartForm.Value))
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, mediaType)
+	}
+}
+
+func (f *Form) bindJSON(r *http.Request) error {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%w: body must be a JSON object: %w", ErrInvalidValue, err)
+	}
+
+	schemaIntro := feature.NewSchemaIntrospector(f.feat.Schema())
+	var errs []error
+	for key, raw := range values {
+		field, err := schemaIntro.GetField(key)
+		if err != nil {
+			return err
+		}
+		if !field.Exists() || !f.includes(key) {
+			if f.strict {
+				errs = append(errs, f.reject(key, fmt.Sprint(raw), "is not a known field", ErrUnknownField))
+			}
+			continue
+		}
+
+		if raw == nil {
+			if !f.partial {
+				f.forget(key)
+				if _, ok := f.feat.Get(key); ok {
+					f.feat.Delete(key)
+				}
+				f.markChanged(key)
+			} else if err := f.clear(key, field); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		f.forget(key)
+		value, rejected := coerceValue(field, key, raw, f.strict)
+		if value != nil {
+			f.accept(key, value)
+		}
+		for _, r := range rejected {
+			errs = append(errs, f.reject(r.key, r.raw, r.message, r.err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// coerceJSON converts a decoded JSON value to the type of a field. Numbers and numeric
+// strings are interchangeable, as long as integer fields receive whole numbers.
+func coerceJSON(typ feature.FieldType, raw any) (any, error) {
+	switch v := raw.(type) {
+	case string:
+		value, err := parseString(typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a %s", ErrInvalidValue, v, typ)
+		}
+		return value, nil
+	case json.Number:
+		switch typ {
+		case feature.FieldTypeInteger:
+			if i, err := v.Int64(); err == nil {
+				return i, nil
+			}
+			// Accept floats without a fractional part, such as 3.0 or 1e3.
+			if fl, err := v.Float64(); err == nil && fl == math.Trunc(fl) && math.Abs(fl) <= 1<<53 {
+				return int64(fl), nil
+			}
+			return nil, fmt.Errorf("%w: %s is not an integer", ErrInvalidValue, v)
+		case feature.FieldTypeNumber:
+			return v.Float64()
+		case feature.FieldTypeString:
+			return v.String(), nil
+		}
+	case bool:
+		if typ == feature.FieldTypeBoolean {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s field can not hold %T", ErrInvalidValue, typ, raw)
+}
@@ -0,0 +1,180 @@
+package form
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+func newBindTestForm(opts ...Option) (*Form, *feature.Feature) {
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString},
+		feature.Field{Name: "score", Type: feature.FieldTypeNumber},
+		feature.Field{Name: "count", Type: feature.FieldTypeInteger},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("score", 1.5)))
+	return New(fe, opts...), fe
+}
+
+func TestBindRequest_JSON(t *testing.T) {
+	fo, fe := newBindTestForm()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "alice", "count": "42", "score": null, "extra": true}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if err := fo.BindRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := fe.GetString("name"); name != "alice" {
+		t.Fatalf("expected \"alice\", got %q", name)
+	}
+	if count, _ := fe.GetInt("count"); count != 42 {
+		t.Fatalf("expected numeric string to be coerced to 42, got %v", count)
+	}
+	if _, ok := fe.Get("score"); ok {
+		t.Fatal("null should remove the field")
+	}
+	if _, ok := fe.Get("extra"); ok {
+		t.Fatal("unknown field should not be stored")
+	}
+}
+
+func TestBindRequest_JSONNullForUnsetField(t *testing.T) {
+	fields := []feature.Field{{Name: "note", Type: feature.FieldTypeString}}
+	items := []any{}
+	for i := range 12 {
+		name := fmt.Sprintf("field%d", i)
+		fields = append(fields, feature.Field{Name: name, Type: feature.FieldTypeString})
+		items = append(items, name, "x")
+	}
+	sch := newTestSchema(fields...)
+
+	// Map hashes are seeded per map, so try enough maps to hit shared hash slots.
+	for range 300 {
+		fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems(items...)))
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"note": null}`))
+		req.Header.Set("Content-Type", "application/json")
+		if err := New(fe).BindRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := fe.Map().Keys(); len(got) != 12 {
+			t.Fatalf("null for an unset field changed the other fields: %v", got)
+		}
+	}
+}
+
+func TestBindRequest_JSONCoercion(t *testing.T) {
+	tests := []struct {
+		body    string
+		field   string
+		want    any
+		wantErr bool
+	}{
+		{body: `{"count": 7}`, field: "count", want: int64(7)},
+		{body: `{"count": 7.0}`, field: "count", want: int64(7)},
+		{body: `{"count": 7.5}`, field: "count", wantErr: true},
+		{body: `{"count": "seven"}`, field: "count", wantErr: true},
+		{body: `{"score": "2.5"}`, field: "score", want: 2.5},
+		{body: `{"score": 3}`, field: "score", want: 3.0},
+		{body: `{"name": 12}`, field: "name", want: "12"},
+		{body: `{"name": ["a"]}`, field: "name", wantErr: true},
+		{body: `[1, 2]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			fo, fe := newBindTestForm()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			err := fo.BindRequest(req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidValue) {
+					t.Fatalf("expected %v, got %v", ErrInvalidValue, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := fe.Get(tt.field)
+			if got != tt.want {
+				t.Fatalf("expected %v (%T), got %v (%T)", tt.want, tt.want, got, got)
+			}
+		})
+	}
+}
+
+func TestBindRequest_Strict(t *testing.T) {
+	fo, fe := newBindTestForm(Strict())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "alice", "extra": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := fo.BindRequest(req); !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("expected %v, got %v", ErrUnknownField, err)
+	}
+	if name, _ := fe.GetString("name"); name != "alice" {
+		t.Fatalf("known fields should still be bound, got %q", name)
+	}
+
+	if err := fo.SetFromUrlValues(url.Values{"extra": []string{"1"}}); !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("expected %v from SetFromUrlValues, got %v", ErrUnknownField, err)
+	}
+}
+
+func TestBindRequest_Multipart(t *testing.T) {
+	fo, fe := newBindTestForm()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("name", "bob")
+	_ = w.WriteField("count", "7")
+	file, _ := w.CreateFormFile("name", "name.txt")
+	_, _ = file.Write([]byte("ignored"))
+	_ = w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := fo.BindRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := fe.GetString("name"); name != "bob" {
+		t.Fatalf("expected \"bob\", got %q", name)
+	}
+	if count, _ := fe.GetInt("count"); count != 7 {
+		t.Fatalf("expected 7, got %v", count)
+	}
+}
+
+func TestBindRequest_URLEncoded(t *testing.T) {
+	fo, fe := newBindTestForm()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("score=9.5"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := fo.BindRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if score, _ := fe.GetFloat("score"); score != 9.5 {
+		t.Fatalf("expected 9.5, got %v", score)
+	}
+}
+
+func TestBindRequest_UnsupportedContentType(t *testing.T) {
+	fo, _ := newBindTestForm()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<xml/>"))
+	req.Header.Set("Content-Type", "application/xml")
+	if err := fo.BindRequest(req); !errors.Is(err, ErrUnsupportedContentType) {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedContentType, err)
+	}
+}
@@ -3,26 +3,97 @@ package form
 import (
 	"errors"
 	"fmt"
+	"html/template"
+	"maps"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mamaar/features/feature"
 )
 
 // New creates a new form with the given feature.
 
-func New(fe *feature.Feature) *Form {
+func New(fe *feature.Feature, opts ...Option) *Form {
 
-	return &Form{
-		feat: fe,
+	f := &Form{
+		feat:      fe,
+		maxMemory: DefaultMaxMemory,
+		location:  time.UTC,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 
 }
 
 // Form represents a form with a feature.
 
 type Form struct {
-	feat *feature.Feature
+	feat      *feature.Feature
+	strict    bool
+	maxMemory int64
+	action    string
+	templates *template.Template
+	// location is the time zone of date-time inputs, which have no zone of their own.
+	location *time.Location
+	// bindErrors holds the messages of values that could not be bound, by field, and
+	// raw holds the submitted values themselves so they can be shown again.
+	bindErrors map[string][]string
+	raw        map[string]string
+	// validationErrors holds the messages of the last failed validation, by field.
+	validationErrors map[string][]string
+	partial          bool
+	// fields restricts the form to some fields of the schema, or is nil for all.
+	fields map[string]bool
+	// changed holds the fields that were set or cleared since the form was created.
+	changed map[string]bool
+}
+
+type Option func(*Form)
+
+// Strict makes the form report values for fields that are not in the schema as
+// ErrUnknownField, instead of silently dropping them.
+func Strict() Option {
+	return func(f *Form) {
+		f.strict = true
+	}
+}
+
+// Partial makes the form update a feature in place, as for PATCH requests. Fields that
+// are not submitted are left unchanged, and an empty value clears an optional field by
+// removing it; clearing a required field is reported as ErrRequired. Validate then only
+// reports errors of the changed fields and of required fields that are missing.
+func Partial() Option {
+	return func(f *Form) {
+		f.partial = true
+	}
+}
+
+// WithFields restricts the form to some top-level fields of the schema. Other fields
+// are not bound, rendered or validated, as if they were not in the schema.
+func WithFields(names ...string) Option {
+	return func(f *Form) {
+		f.fields = make(map[string]bool)
+		for _, name := range names {
+			f.fields[name] = true
+		}
+	}
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// includes reports whether the form covers the top-level field.
+func (f *Form) includes(name string) bool {
+	return f.fields == nil || f.fields[name]
 }
 
 // Feature returns the underlying feature.
@@ -30,50 +101,266 @@ func (f *Form) Feature() *feature.Feature {
 	return f.feat
 }
 
-// SetFromUrlValues sets the form values from the given url.Values.
+// SetFromUrlValues sets the form values from the given url.Values. Object and array
+// fields are set from names in bracket notation, such as address[city], items[0][sku]
+// or tags[]; the submitted names replace the whole value of the field. Of a name that is
+// submitted more than once the last value is used, so that a checked checkbox overrides
+// the hidden "false" rendered before it. An empty value for an optional field that is not
+// a plain string, such as a number left blank or the empty option of a select, removes
+// the field.
 func (f *Form) SetFromUrlValues(values url.Values) error {
 	schemaIntro := feature.NewSchemaIntrospector(f.feat.Schema())
 
 	var errs []error
+	nested := make(map[string]*nestedValue)
+	var rejected []rejection
 	for key := range values {
-		field, err := schemaIntro.GetField(key)
+		name, path, ok := splitKey(key)
+		field, err := schemaIntro.GetField(name)
 		if err != nil {
 			continue
 		}
 
-		if !field.Exists() {
+		container := field.Type() == feature.FieldTypeObject || field.Type() == feature.FieldTypeArray
+		if !ok || !field.Exists() || !f.includes(name) || (len(path) > 0 && !container) {
+			if f.strict {
+				errs = append(errs, f.reject(key, values.Get(key), "is not a known field", ErrUnknownField))
+			}
 			continue
 		}
 
-		raw := values.Get(key)
-		switch field.Type() {
-		case feature.FieldTypeString:
-			f.feat.Set(key, raw)
-		case feature.FieldTypeNumber:
-			flt, err := strconv.ParseFloat(raw, 64)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("field %q: %w", key, err))
-			} else {
-				f.feat.Set(key, flt)
+		if len(path) > 0 {
+			raws := []string{lastValue(values[key])}
+			if path[len(path)-1] == "" {
+				raws = values[key]
+			}
+			n, ok := nested[name]
+			if !ok {
+				n = newNestedValue(field)
+				nested[name] = n
 			}
-		case feature.FieldTypeInteger:
-			parsed, err := strconv.ParseInt(raw, 10, 64)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("field %q: %w", key, err))
-			} else {
-				f.feat.SetInt(key, int(parsed))
+			rejected = append(rejected, n.insert(f, key, path, raws)...)
+			continue
+		}
+
+		raw := lastValue(values[key])
+		if raw == "" && (f.partial || emptyIsAbsent(field)) {
+			if err := f.clear(key, field); err != nil {
+				errs = append(errs, err)
 			}
+			continue
+		}
+		value, err := f.parseSubmitted(field, raw)
+		if err != nil {
+			errs = append(errs, f.reject(key, raw, typeMessage(field.Type()), err))
+			continue
 		}
+		f.accept(key, value)
+	}
+
+	for name, n := range nested {
+		if value, ok := n.build(); ok {
+			f.accept(name, value)
+		} else {
+			f.forget(name)
+		}
+	}
+	for _, r := range rejected {
+		errs = append(errs, f.reject(r.key, r.raw, r.message, r.err))
 	}
 
 	return errors.Join(errs...)
 }
 
+// emptyIsAbsent reports whether an empty submitted value means that an optional field has
+// no value. A rendered form submits its number, date and select inputs empty when they
+// are left blank, and only a plain string field can hold an empty string.
+func emptyIsAbsent(field feature.IntrospectedField) bool {
+	if field.Required() {
+		return false
+	}
+	return field.Type() != feature.FieldTypeString || field.Format() != "" || len(field.Enum()) > 0
+}
+
+// accept sets a bound value and forgets earlier errors for the field.
+func (f *Form) accept(key string, value any) {
+	f.forget(key)
+	f.feat.Set(key, value)
+	f.markChanged(key)
+}
+
+// clear removes the value of a field, unless the field is required.
+func (f *Form) clear(key string, field feature.IntrospectedField) error {
+	if field.Required() {
+		return f.reject(key, "", "is required", ErrRequired)
+	}
+	f.forget(key)
+	if _, ok := f.feat.Get(key); ok {
+		f.feat.Delete(key)
+	}
+	f.markChanged(key)
+	return nil
+}
+
+func (f *Form) markChanged(key string) {
+	if f.changed == nil {
+		f.changed = make(map[string]bool)
+	}
+	f.changed[key] = true
+}
+
+// Changed returns the names of the fields that were set or cleared, in sorted order.
+func (f *Form) Changed() []string {
+	return slices.Sorted(maps.Keys(f.changed))
+}
+
+// forget drops the errors and raw values of a field and of the values nested in it.
+func (f *Form) forget(key string) {
+	for k := range f.bindErrors {
+		if k == key || strings.HasPrefix(k, key+"[") {
+			delete(f.bindErrors, k)
+			delete(f.raw, k)
+		}
+	}
+}
+
+// reject records a value that could not be bound, with a readable message, and returns
+// the underlying error wrapped with the field name.
+func (f *Form) reject(key string, raw string, message string, err error) error {
+	if f.bindErrors == nil {
+		f.bindErrors = make(map[string][]string)
+		f.raw = make(map[string]string)
+	}
+	f.bindErrors[key] = []string{keyLabel(key) + " " + message}
+	f.raw[key] = raw
+	return fmt.Errorf("field %q: %w", key, err)
+}
+
+// typeMessage describes the values a field of the type accepts.
+func typeMessage(typ feature.FieldType) string {
+	switch typ {
+	case feature.FieldTypeNumber:
+		return "must be a number"
+	case feature.FieldTypeInteger:
+		return "must be a whole number"
+	case feature.FieldTypeBoolean:
+		return "must be true or false"
+	case feature.FieldTypeObject:
+		return "must be a group of fields"
+	case feature.FieldTypeArray:
+		return "must be a list"
+	default:
+		return "must be text"
+	}
+}
+
+// Errors returns readable error messages by field name, with nested values named in
+// bracket notation: first those of values that could not be bound to their field, then
+// those of the last call to Validate.
+func (f *Form) Errors() map[string][]string {
+	res := make(map[string][]string)
+	for key, msgs := range f.bindErrors {
+		res[key] = append(res[key], msgs...)
+	}
+	for key, msgs := range f.validationErrors {
+		res[key] = append(res[key], msgs...)
+	}
+	return res
+}
+
+// RawValue returns the value submitted for a field if it could not be bound, so it can
+// be shown to the user again.
+func (f *Form) RawValue(key string) (string, bool) {
+	raw, ok := f.raw[key]
+	return raw, ok
+}
+
+// parseSubmitted converts a submitted string to the value of a field. Date-time fields
+// are rendered as datetime-local inputs, which have no zone, so their values are read in
+// the location of the form and stored as RFC 3339. Values that are not local date-times
+// are kept as submitted, for validation to report.
+func (f *Form) parseSubmitted(field feature.IntrospectedField, raw string) (any, error) {
+	value, err := parseString(field.Type(), raw)
+	if err != nil || field.Type() != feature.FieldTypeString || field.Format() != "date-time" {
+		return value, err
+	}
+	for _, layout := range []string{localDateTimeLayout, "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, raw, f.location); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return raw, nil
+}
+
+// parseString converts a submitted string to the type of a field.
+func parseString(typ feature.FieldType, raw string) (any, error) {
+	switch typ {
+	case feature.FieldTypeNumber:
+		return strconv.ParseFloat(raw, 64)
+	case feature.FieldTypeInteger:
+		return strconv.ParseInt(raw, 10, 64)
+	case feature.FieldTypeBoolean:
+		// Checked checkboxes submit "on" unless they have a value.
+		if raw == "on" {
+			return true, nil
+		}
+		return strconv.ParseBool(raw)
+	case feature.FieldTypeObject, feature.FieldTypeArray:
+		return nil, fmt.Errorf("%w: %s field can not hold text", ErrInvalidValue, typ)
+	default:
+		return raw, nil
+	}
+}
+
+// Validate checks the feature against its schema. It fails if the schema is violated
+// or if any submitted value could not be bound; Errors lists both kinds by field.
 func (f *Form) Validate() error {
 	compiled, err := f.feat.Schema().ToJSONSchema()
 	if err != nil {
 		return err
 	}
 
-	return compiled.Validate(f.feat)
+	err = compiled.Validate(f.feat)
+	f.validationErrors = nil
+	var verr *feature.ValidationError
+	if errors.As(err, &verr) {
+		verr = f.relevantErrors(verr)
+		err = nil
+		if verr != nil {
+			err = verr
+		}
+	}
+	if verr != nil {
+		f.validationErrors = make(map[string][]string)
+		for _, fe := range verr.Errors {
+			key := joinKey(fe.Path)
+			f.validationErrors[key] = append(f.validationErrors[key], keyLabel(key)+" "+fe.Message)
+		}
+	}
+
+	var bindErrs []error
+	for _, key := range slices.Sorted(maps.Keys(f.bindErrors)) {
+		bindErrs = append(bindErrs, fmt.Errorf("field %q: %w: %s", key, ErrInvalidValue, strings.Join(f.bindErrors[key], "; ")))
+	}
+	return errors.Join(append(bindErrs, err)...)
+}
+
+// relevantErrors keeps the errors of the fields the form covers. In Partial mode, it
+// only keeps those of changed fields and of fields that are not set at all, which can
+// only be missing required fields. It returns nil if no error is left.
+func (f *Form) relevantErrors(verr *feature.ValidationError) *feature.ValidationError {
+	res := &feature.ValidationError{}
+	for _, fe := range verr.Errors {
+		if !f.includes(fe.Field()) {
+			continue
+		}
+		if _, set := f.feat.Get(fe.Field()); f.partial && !f.changed[fe.Field()] && set {
+			continue
+		}
+		res.Errors = append(res.Errors, fe)
+	}
+	if len(res.Errors) == 0 {
+		return nil
+	}
+	return res
 }
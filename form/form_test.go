@@ -1,6 +1,7 @@
 package form
 
 import (
+	"errors"
 	"net/url"
 	"testing"
 
@@ -178,3 +179,56 @@ func TestFeatureGetter(t *testing.T) {
 		t.Fatal("Feature() should return the underlying feature")
 	}
 }
+
+func TestErrors_CombinesBindingAndValidation(t *testing.T) {
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString, Required: true},
+		feature.Field{Name: "count", Type: feature.FieldTypeInteger},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.New()))
+	fo := New(fe)
+
+	if err := fo.SetFromUrlValues(url.Values{"count": []string{"twelve"}}); err == nil {
+		t.Fatal("expected error for invalid integer, got nil")
+	}
+	if err := fo.Validate(); !errors.Is(err, ErrInvalidValue) || !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected binding and validation errors, got %v", err)
+	}
+
+	errs := fo.Errors()
+	if got := errs["count"]; len(got) != 1 || got[0] != "Count must be a whole number" {
+		t.Fatalf("unexpected errors for count: %v", got)
+	}
+	if got := errs["name"]; len(got) != 1 || got[0] != "Name is required" {
+		t.Fatalf("unexpected errors for name: %v", got)
+	}
+	if raw, ok := fo.RawValue("count"); !ok || raw != "twelve" {
+		t.Fatalf("expected raw value \"twelve\", got %q", raw)
+	}
+
+	// Correcting the input clears its errors.
+	if err := fo.SetFromUrlValues(url.Values{"count": []string{"12"}, "name": []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if errs := fo.Errors(); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if _, ok := fo.RawValue("count"); ok {
+		t.Fatal("raw value should be forgotten once the field is bound")
+	}
+}
+
+func TestRender_KeepsRawValue(t *testing.T) {
+	sch := newTestSchema(feature.Field{Name: "count", Type: feature.FieldTypeInteger})
+	fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("count", 3)))
+	fo := New(fe)
+
+	_ = fo.SetFromUrlValues(url.Values{"count": []string{"3.5"}})
+	fields := fo.Fields()
+	if fields[0].Value != "3.5" || len(fields[0].Errors) != 1 {
+		t.Fatalf("expected submitted value and error, got %+v", fields[0])
+	}
+}
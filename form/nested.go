@@ -0,0 +1,300 @@
+package form
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+// Object and array fields are submitted with bracket notation: address[city] is a
+// property of an object, items[0][sku] a property of the first element of an array,
+// and tags[] appends one element per submitted value. Indices only order the elements;
+// gaps between them are closed.
+
+// splitKey splits a submitted name such as "items[0][sku]" into the field name and the
+// path below it. An empty segment stands for "[]".
+func splitKey(key string) (string, []string, bool) {
+	name, rest, nested := strings.Cut(key, "[")
+	if !nested {
+		return key, nil, !strings.Contains(key, "]")
+	}
+	rest = "[" + rest
+	var path []string
+	for rest != "" {
+		if rest[0] != '[' {
+			return "", nil, false
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return "", nil, false
+		}
+		seg := rest[1:end]
+		if strings.Contains(seg, "[") {
+			return "", nil, false
+		}
+		path = append(path, seg)
+		rest = rest[end+1:]
+	}
+	if name == "" || strings.Contains(name, "]") {
+		return "", nil, false
+	}
+	return name, path, true
+}
+
+// joinKey builds the submitted name of a value from its path, such as the path of a
+// feature.FieldError.
+func joinKey(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(path[0])
+	for _, seg := range path[1:] {
+		b.WriteString("[" + seg + "]")
+	}
+	return b.String()
+}
+
+// keyLabel returns the label of the innermost named field of a submitted name, so that
+// "items[0][unit_price]" reads as "Unit price".
+func keyLabel(key string) string {
+	name, path, ok := splitKey(key)
+	if !ok {
+		return fieldLabel(key)
+	}
+	for _, seg := range slices.Backward(path) {
+		if _, err := strconv.Atoi(seg); seg != "" && err != nil {
+			return fieldLabel(seg)
+		}
+	}
+	return fieldLabel(name)
+}
+
+// rejection is a nested value that could not be bound, recorded once the enclosing
+// field has been set.
+type rejection struct {
+	key     string
+	raw     string
+	message string
+	err     error
+}
+
+func unknownField(key string, raw string) rejection {
+	return rejection{key: key, raw: raw, message: "is not a known field", err: ErrUnknownField}
+}
+
+// nestedValue collects the submitted values below an object or array field.
+type nestedValue struct {
+	field    feature.IntrospectedField
+	value    any
+	set      bool
+	props    map[string]*nestedValue
+	indexed  map[int]*nestedValue
+	appended []*nestedValue
+}
+
+func newNestedValue(field feature.IntrospectedField) *nestedValue {
+	return &nestedValue{field: field}
+}
+
+// insert parses the values submitted for key, whose path below this value is given.
+// Names that do not match the schema are only reported when the form is strict.
+func (n *nestedValue) insert(f *Form, key string, path []string, raws []string) []rejection {
+	unknown := func() []rejection {
+		if !f.strict {
+			return nil
+		}
+		return []rejection{unknownField(key, strings.Join(raws, ", "))}
+	}
+
+	if len(path) == 0 {
+		value, err := f.parseSubmitted(n.field, raws[0])
+		if err != nil {
+			return []rejection{{key: key, raw: raws[0], message: typeMessage(n.field.Type()), err: err}}
+		}
+		n.value, n.set = value, true
+		return nil
+	}
+
+	seg, rest := path[0], path[1:]
+	switch n.field.Type() {
+	case feature.FieldTypeObject:
+		prop := n.field.Property(seg)
+		if !prop.Exists() {
+			return unknown()
+		}
+		if n.props == nil {
+			n.props = make(map[string]*nestedValue)
+		}
+		child, ok := n.props[seg]
+		if !ok {
+			child = newNestedValue(prop)
+			n.props[seg] = child
+		}
+		return child.insert(f, key, rest, raws)
+
+	case feature.FieldTypeArray:
+		items := n.field.Items()
+		if !items.Exists() {
+			return unknown()
+		}
+		if seg == "" {
+			if len(rest) > 0 {
+				return unknown()
+			}
+			var rejs []rejection
+			for _, raw := range raws {
+				child := newNestedValue(items)
+				if r := child.insert(f, key, nil, []string{raw}); len(r) > 0 {
+					rejs = append(rejs, r...)
+					continue
+				}
+				n.appended = append(n.appended, child)
+			}
+			return rejs
+		}
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 {
+			return unknown()
+		}
+		if n.indexed == nil {
+			n.indexed = make(map[int]*nestedValue)
+		}
+		child, ok := n.indexed[i]
+		if !ok {
+			child = newNestedValue(items)
+			n.indexed[i] = child
+		}
+		return child.insert(f, key, rest, raws)
+
+	default:
+		return unknown()
+	}
+}
+
+// build returns the collected value as a jsonchamp.Map, a slice or a scalar. It
+// reports false if no value below it could be bound.
+func (n *nestedValue) build() (any, bool) {
+	switch {
+	case n.set:
+		return n.value, true
+	case n.props != nil:
+		m := jsonchamp.New()
+		for name, child := range n.props {
+			if v, ok := child.build(); ok {
+				m = m.Set(name, v)
+			}
+		}
+		return m, len(m.Keys()) > 0
+	case n.indexed != nil || n.appended != nil:
+		var list []any
+		for _, i := range slices.Sorted(maps.Keys(n.indexed)) {
+			if v, ok := n.indexed[i].build(); ok {
+				list = append(list, v)
+			}
+		}
+		for _, child := range n.appended {
+			if v, ok := child.build(); ok {
+				list = append(list, v)
+			}
+		}
+		return list, len(list) > 0
+	default:
+		return nil, false
+	}
+}
+
+// coerceValue converts a decoded JSON value to the type of a field, descending into
+// objects and arrays. Values that can not be converted are reported by their bracketed
+// name and left out of objects. An array with an element that can not be converted is
+// left out as a whole, and null elements keep their slot, so that the indexes of the
+// array always match the request. The result is nil if the value itself could not be
+// converted.
+func coerceValue(field feature.IntrospectedField, key string, raw any, strict bool) (any, []rejection) {
+	switch field.Type() {
+	case feature.FieldTypeObject:
+		values, ok := raw.(map[string]any)
+		if !ok {
+			return nil, []rejection{{key: key, raw: fmt.Sprint(raw), message: typeMessage(field.Type()), err: fmt.Errorf("%w: object field can not hold %T", ErrInvalidValue, raw)}}
+		}
+		m := jsonchamp.New()
+		var rejs []rejection
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			childKey := key + "[" + name + "]"
+			prop := field.Property(name)
+			if !prop.Exists() {
+				if strict {
+					rejs = append(rejs, unknownField(childKey, fmt.Sprint(values[name])))
+				}
+				continue
+			}
+			if values[name] == nil {
+				continue
+			}
+			v, r := coerceValue(prop, childKey, values[name], strict)
+			rejs = append(rejs, r...)
+			if v != nil {
+				m = m.Set(name, v)
+			}
+		}
+		return m, rejs
+
+	case feature.FieldTypeArray:
+		elems, ok := raw.([]any)
+		if !ok {
+			return nil, []rejection{{key: key, raw: fmt.Sprint(raw), message: typeMessage(field.Type()), err: fmt.Errorf("%w: array field can not hold %T", ErrInvalidValue, raw)}}
+		}
+		items := field.Items()
+		list := []any{}
+		var rejs []rejection
+		failed := false
+		for i, elem := range elems {
+			childKey := key + "[" + strconv.Itoa(i) + "]"
+			if !items.Exists() {
+				if strict {
+					rejs = append(rejs, unknownField(childKey, fmt.Sprint(elem)))
+				}
+				continue
+			}
+			if elem == nil {
+				list = append(list, nil)
+				continue
+			}
+			v, r := coerceValue(items, childKey, elem, strict)
+			rejs = append(rejs, r...)
+			if v == nil {
+				failed = true
+			}
+			list = append(list, v)
+		}
+		if failed {
+			return nil, rejs
+		}
+		return list, rejs
+
+	default:
+		value, err := coerceJSON(field.Type(), raw)
+		if err != nil {
+			return nil, []rejection{{key: key, raw: jsonString(raw), message: typeMessage(field.Type()), err: err}}
+		}
+		return value, nil
+	}
+}
+
+func jsonString(raw any) string {
+	switch raw.(type) {
+	case map[string]any, []any:
+		b, err := json.Marshal(raw)
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(raw)
+}
@@ -0,0 +1,227 @@
+package form
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+func newNestedTestForm(opts ...Option) (*Form, *feature.Feature) {
+	sch := newTestSchema(
+		feature.Field{Name: "address", Type: feature.FieldTypeObject, Properties: []feature.Field{
+			{Name: "city", Type: feature.FieldTypeString, Required: true},
+			{Name: "zip", Type: feature.FieldTypeInteger},
+		}},
+		feature.Field{Name: "items", Type: feature.FieldTypeArray, Items: &feature.Field{
+			Type: feature.FieldTypeObject, Properties: []feature.Field{
+				{Name: "sku", Type: feature.FieldTypeString, Required: true},
+				{Name: "count", Type: feature.FieldTypeInteger},
+			},
+		}},
+		feature.Field{Name: "tags", Type: feature.FieldTypeArray, Items: &feature.Field{Type: feature.FieldTypeString}},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.New()))
+	return New(fe, opts...), fe
+}
+
+func TestSetFromUrlValues_Nested(t *testing.T) {
+	fo, fe := newNestedTestForm()
+
+	err := fo.SetFromUrlValues(url.Values{
+		"address[city]":   {"Oslo"},
+		"address[zip]":    {"150"},
+		"items[1][sku]":   {"B"},
+		"items[0][sku]":   {"A"},
+		"items[0][count]": {"2"},
+		"tags[]":          {"red", "blue"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, err := fe.Map().GetMap("address")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city, _ := address.GetString("city"); city != "Oslo" {
+		t.Fatalf("expected \"Oslo\", got %q", city)
+	}
+	if zip, _ := address.GetInt("zip"); zip != 150 {
+		t.Fatalf("expected zip to be coerced to 150, got %v", zip)
+	}
+
+	items, _ := fe.Get("items")
+	list, ok := items.([]any)
+	if !ok || len(list) != 2 {
+		t.Fatalf("expected two items, got %v", items)
+	}
+	first := list[0].(*jsonchamp.Map)
+	if sku, _ := first.GetString("sku"); sku != "A" {
+		t.Fatalf("expected items to be ordered by index, got %q first", sku)
+	}
+	if count, _ := first.GetInt("count"); count != 2 {
+		t.Fatalf("expected count 2, got %v", count)
+	}
+
+	tags, _ := fe.Get("tags")
+	if got, ok := tags.([]any); !ok || len(got) != 2 || got[0] != "red" || got[1] != "blue" {
+		t.Fatalf("expected [red blue], got %v", tags)
+	}
+}
+
+func TestSetFromUrlValues_NestedErrors(t *testing.T) {
+	fo, fe := newNestedTestForm()
+
+	err := fo.SetFromUrlValues(url.Values{
+		"items[0][sku]":   {"A"},
+		"items[0][count]": {"two"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `"items[0][count]"`) {
+		t.Fatalf("expected error for items[0][count], got %v", err)
+	}
+
+	errs := fo.Errors()
+	if got := errs["items[0][count]"]; len(got) != 1 || got[0] != "Count must be a whole number" {
+		t.Fatalf("unexpected errors for items[0][count]: %v", errs)
+	}
+	if raw, ok := fo.RawValue("items[0][count]"); !ok || raw != "two" {
+		t.Fatalf("expected raw value \"two\", got %q", raw)
+	}
+	items, _ := fe.Get("items")
+	if list, ok := items.([]any); !ok || len(list) != 1 {
+		t.Fatalf("expected the valid values to be bound, got %v", items)
+	}
+
+	// Binding the field again forgets the errors of its nested values.
+	if err := fo.SetFromUrlValues(url.Values{"items[0][sku]": {"A"}, "items[0][count]": {"2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if errs := fo.Errors(); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+}
+
+func TestSetFromUrlValues_NestedUnknown(t *testing.T) {
+	fo, fe := newNestedTestForm()
+	if err := fo.SetFromUrlValues(url.Values{"address[country]": {"NO"}, "address[city]": {"Oslo"}}); err != nil {
+		t.Fatalf("unknown nested fields should not cause errors, got: %v", err)
+	}
+	address, _ := fe.Map().GetMap("address")
+	if address.Contains("country") {
+		t.Fatal("unknown nested field should not be stored")
+	}
+
+	fo, _ = newNestedTestForm(Strict())
+	for _, key := range []string{"address[country]", "tags[0][x]", "items[x][sku]", "address[city", "tags[][]"} {
+		if err := fo.SetFromUrlValues(url.Values{key: {"x"}}); !errors.Is(err, ErrUnknownField) {
+			t.Fatalf("%s: expected %v, got %v", key, ErrUnknownField, err)
+		}
+	}
+}
+
+func TestBindRequest_NestedJSON(t *testing.T) {
+	fo, fe := newNestedTestForm()
+
+	body := `{"address": {"city": "Oslo", "zip": "150"}, "items": [{"sku": "A", "count": 1.5}, {"sku": "B", "count": 2}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	err := fo.BindRequest(req)
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected %v, got %v", ErrInvalidValue, err)
+	}
+	if got := fo.Errors()["items[0][count]"]; len(got) != 1 {
+		t.Fatalf("expected an error for items[0][count], got %v", fo.Errors())
+	}
+
+	address, _ := fe.Map().GetMap("address")
+	if zip, _ := address.GetInt("zip"); zip != 150 {
+		t.Fatalf("expected zip to be coerced to 150, got %v", zip)
+	}
+	items, _ := fe.Get("items")
+	list := items.([]any)
+	if len(list) != 2 || list[0].(*jsonchamp.Map).Contains("count") {
+		t.Fatalf("expected the invalid count to be left out, got %v", items)
+	}
+}
+
+func TestBindRequest_NestedJSONArrayIndexes(t *testing.T) {
+	fo, fe := newNestedTestForm()
+
+	body := `{"tags": ["a", null, "c"], "items": [{"sku": "A"}, 7, {"sku": "C"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if err := fo.BindRequest(req); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("expected %v, got %v", ErrInvalidValue, err)
+	}
+	if got := fo.Errors()["items[1]"]; len(got) != 1 {
+		t.Fatalf("expected an error for items[1], got %v", fo.Errors())
+	}
+	if _, ok := fe.Get("items"); ok {
+		t.Fatal("expected an array with an invalid element to be left out")
+	}
+
+	tags, _ := fe.Get("tags")
+	if list := tags.([]any); len(list) != 3 || list[2] != "c" {
+		t.Fatalf("expected every tag to keep its index, got %v", tags)
+	}
+	if err := fo.Validate(); !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected %v, got %v", feature.ErrValidation, err)
+	}
+	if got := fo.Errors()["tags[1]"]; len(got) != 1 {
+		t.Fatalf("expected the null tag to be reported at tags[1], got %v", fo.Errors())
+	}
+}
+
+func TestValidate_NestedErrors(t *testing.T) {
+	fo, _ := newNestedTestForm()
+	if err := fo.SetFromUrlValues(url.Values{"address[zip]": {"150"}, "items[0][count]": {"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fo.Validate(); !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected %v, got %v", feature.ErrValidation, err)
+	}
+
+	errs := fo.Errors()
+	if got := errs["address[city]"]; len(got) != 1 || got[0] != "City is required" {
+		t.Fatalf("unexpected errors for address[city]: %v", errs)
+	}
+	if got := errs["items[0][sku]"]; len(got) != 1 || got[0] != "Sku is required" {
+		t.Fatalf("unexpected errors for items[0][sku]: %v", errs)
+	}
+}
+
+func TestRender_Nested(t *testing.T) {
+	fo, _ := newNestedTestForm()
+	if err := fo.SetFromUrlValues(url.Values{"address[city]": {"Oslo"}, "items[0][sku]": {"A"}, "items[0][count]": {"x"}}); err == nil {
+		t.Fatal("expected error for items[0][count], got nil")
+	}
+
+	views := make(map[string]FieldView)
+	for _, v := range fo.Fields() {
+		views[v.Name] = v
+	}
+	if v := views["address[city]"]; v.Value != "Oslo" || v.Label != "Address city" || v.ID != "field-address-city" || v.Required {
+		t.Fatalf("unexpected view for address[city]: %+v", v)
+	}
+	if v := views["items[0][count]"]; v.Value != "x" || v.Label != "Items 1 count" || len(v.Errors) != 1 {
+		t.Fatalf("unexpected view for items[0][count]: %+v", v)
+	}
+	if v := views["items[0][sku]"]; !v.Required {
+		t.Fatalf("expected items[0][sku] to be required, got %+v", v)
+	}
+
+	var b strings.Builder
+	if err := fo.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `name="items[0][sku]" value="A"`) {
+		t.Fatalf("expected bracketed input name, got %s", b.String())
+	}
+}
@@ -0,0 +1,133 @@
+package form
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+func newPartialTestForm() (*Form, *feature.Feature) {
+	maximum := 10.0
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString, Required: true},
+		feature.Field{Name: "note", Type: feature.FieldTypeString},
+		feature.Field{Name: "count", Type: feature.FieldTypeInteger, Maximum: &maximum},
+	)
+	// The stored count violates a constraint added after it was written.
+	fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("name", "alice", "note", "hi", "count", 12)))
+	return New(fe, Partial()), fe
+}
+
+func TestPartial_AbsentAndCleared(t *testing.T) {
+	fo, fe := newPartialTestForm()
+
+	if err := fo.SetFromUrlValues(url.Values{"note": {""}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fe.Get("note"); ok {
+		t.Fatal("an empty value should clear the optional field")
+	}
+	if name, _ := fe.GetString("name"); name != "alice" {
+		t.Fatalf("absent fields should be left unchanged, got %q", name)
+	}
+	if changed := fo.Changed(); len(changed) != 1 || changed[0] != "note" {
+		t.Fatalf("expected only note to be changed, got %v", changed)
+	}
+
+	// Only the changed fields are validated, so the stored count is not reported.
+	if err := fo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPartial_RequiredCanNotBeCleared(t *testing.T) {
+	fo, fe := newPartialTestForm()
+
+	err := fo.SetFromUrlValues(url.Values{"name": {""}})
+	if !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected %v, got %v", ErrRequired, err)
+	}
+	if name, _ := fe.GetString("name"); name != "alice" {
+		t.Fatalf("required field should keep its value, got %q", name)
+	}
+	if got := fo.Errors()["name"]; len(got) != 1 || got[0] != "Name is required" {
+		t.Fatalf("unexpected errors for name: %v", got)
+	}
+}
+
+func TestPartial_ValidatesChangedFields(t *testing.T) {
+	fo, _ := newPartialTestForm()
+
+	if err := fo.SetFromUrlValues(url.Values{"count": {"11"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fo.Validate(); !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected %v, got %v", feature.ErrValidation, err)
+	}
+	if got := fo.Errors()["count"]; len(got) != 1 || got[0] != "Count must be at most 10" {
+		t.Fatalf("unexpected errors for count: %v", fo.Errors())
+	}
+}
+
+func TestPartial_MissingRequiredField(t *testing.T) {
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString, Required: true},
+		feature.Field{Name: "note", Type: feature.FieldTypeString},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.New()))
+	fo := New(fe, Partial())
+
+	if err := fo.SetFromUrlValues(url.Values{"note": {"hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fo.Validate(); !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected missing required field to be reported, got %v", err)
+	}
+}
+
+func TestPartial_JSONNull(t *testing.T) {
+	fo, fe := newPartialTestForm()
+
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"note": null, "name": null}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := fo.BindRequest(req); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected %v, got %v", ErrRequired, err)
+	}
+	if _, ok := fe.Get("note"); ok {
+		t.Fatal("null should clear the optional field")
+	}
+	if _, ok := fe.Get("name"); !ok {
+		t.Fatal("null should not clear the required field")
+	}
+}
+
+func TestPartial_ClearUnsetField(t *testing.T) {
+	fields := []feature.Field{{Name: "note", Type: feature.FieldTypeString}}
+	items := []any{}
+	for i := range 12 {
+		name := fmt.Sprintf("field%d", i)
+		fields = append(fields, feature.Field{Name: name, Type: feature.FieldTypeString})
+		items = append(items, name, "x")
+	}
+	sch := newTestSchema(fields...)
+
+	// Map hashes are seeded per map, so try enough maps to hit shared hash slots.
+	for range 300 {
+		fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems(items...)))
+		fo := New(fe, Partial())
+		if err := fo.SetFromUrlValues(url.Values{"note": {""}}); err != nil {
+			t.Fatal(err)
+		}
+		if got := fe.Map().Keys(); len(got) != 12 {
+			t.Fatalf("clearing an unset field changed the other fields: %v", got)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package form
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+//go:embed form.tmpl
+var defaultTemplates string
+
+// DefaultTemplates returns a fresh copy of the templates used by Render. Templates can
+// be overridden by redefining them in the copy, then passing it to WithTemplates:
+//
+//	"form"     the whole form, given a FormView
+//	"field"    one field, given a FieldView, which dispatches to the templates below
+//	"input"    a label and an input element
+//	"checkbox" a checkbox followed by its label
+//	"select"   a label and a select element for enum fields
+//	"errors"   the error messages of a field
+func DefaultTemplates() *template.Template {
+	return template.Must(template.New("form").Parse(defaultTemplates))
+}
+
+// WithTemplates sets the templates used by Render.
+func WithTemplates(t *template.Template) Option {
+	return func(f *Form) {
+		f.templates = t
+	}
+}
+
+// WithLocation sets the time zone date-time fields are shown and submitted in, as their
+// inputs have no zone of their own. It defaults to UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(f *Form) {
+		f.location = loc
+	}
+}
+
+// WithAction sets the URL the rendered form submits to.
+func WithAction(action string) Option {
+	return func(f *Form) {
+		f.action = action
+	}
+}
+
+// FormView is the data passed to the "form" template.
+type FormView struct {
+	Action string
+	Fields []FieldView
+}
+
+// FieldView is the data passed to the templates of a single field.
+type FieldView struct {
+	Name  string
+	Label string
+	ID    string
+	Type  feature.FieldType
+	// InputType is the type attribute of the input element, or "select" for enums.
+	InputType string
+	Value     string
+	Checked   bool
+	Required  bool
+	Min       string
+	Max       string
+	Step      string
+	Pattern   string
+	Options   []OptionView
+	Errors    []string
+	// ErrorID is the id of the element listing the errors, for aria-describedby.
+	ErrorID string
+}
+
+// OptionView is a choice of a select element.
+type OptionView struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// Render writes the form as HTML, with the current values of the feature and the errors
+// of the last call to Validate.
+func (f *Form) Render(w io.Writer) error {
+	t := f.templates
+	if t == nil {
+		t = DefaultTemplates()
+	}
+	return t.ExecuteTemplate(w, "form", FormView{Action: f.action, Fields: f.Fields()})
+}
+
+// Fields returns a view of every field of the schema, in the order they were added.
+// Object fields are flattened into a view per property, and array fields into a view
+// per existing element, named in bracket notation.
+func (f *Form) Fields() []FieldView {
+	intro := feature.NewSchemaIntrospector(f.feat.Schema())
+	fieldErrors := f.Errors()
+	var views []FieldView
+	for _, field := range intro.Fields() {
+		name := field.Name()
+		if !f.includes(name) {
+			continue
+		}
+		value, ok := f.feat.Get(name)
+		views = append(views, f.fieldViews(field, name, fieldLabel(name), value, ok, field.Required(), fieldErrors)...)
+	}
+	return views
+}
+
+var idReplacer = strings.NewReplacer("[", "-", "]", "")
+
+func (f *Form) fieldViews(field feature.IntrospectedField, name string, label string, value any, ok bool, required bool, fieldErrors map[string][]string) []FieldView {
+	switch field.Type() {
+	case feature.FieldTypeObject:
+		m, _ := value.(*jsonchamp.Map)
+		var views []FieldView
+		for _, prop := range field.Properties() {
+			var v any
+			var has bool
+			if m != nil {
+				v, has = m.Get(prop.Name())
+			}
+			propLabel := label + " " + strings.ReplaceAll(prop.Name(), "_", " ")
+			views = append(views, f.fieldViews(prop, name+"["+prop.Name()+"]", propLabel, v, has, required && prop.Required(), fieldErrors)...)
+		}
+		return views
+	case feature.FieldTypeArray:
+		list, _ := value.([]any)
+		var views []FieldView
+		for i, v := range list {
+			elemLabel := label + " " + strconv.Itoa(i+1)
+			views = append(views, f.fieldViews(field.Items(), name+"["+strconv.Itoa(i)+"]", elemLabel, v, true, true, fieldErrors)...)
+		}
+		return views
+	}
+
+	id := "field-" + idReplacer.Replace(name)
+	view := FieldView{
+		Name:      name,
+		Label:     label,
+		ID:        id,
+		Type:      field.Type(),
+		InputType: inputType(field),
+		Required:  required,
+		Pattern:   htmlPattern(field.Pattern()),
+		Errors:    fieldErrors[name],
+		ErrorID:   id + "-errors",
+	}
+	if field.Minimum() != nil {
+		view.Min = formatNumber(*field.Minimum())
+	}
+	if field.Maximum() != nil {
+		view.Max = formatNumber(*field.Maximum())
+	}
+	switch field.Type() {
+	case feature.FieldTypeInteger:
+		view.Step = "1"
+	case feature.FieldTypeNumber:
+		view.Step = "any"
+	}
+
+	if ok {
+		view.Value = formatValue(value, field.Format(), f.location)
+		view.Checked = value == true
+	}
+	if raw, rejected := f.RawValue(name); rejected {
+		view.Value, ok = raw, true
+	}
+	for _, option := range field.Enum() {
+		s := fmt.Sprint(option)
+		view.Options = append(view.Options, OptionView{Value: s, Label: s, Selected: ok && s == view.Value})
+	}
+	return []FieldView{view}
+}
+
+func inputType(field feature.IntrospectedField) string {
+	if len(field.Enum()) > 0 {
+		return "select"
+	}
+	switch field.Type() {
+	case feature.FieldTypeInteger, feature.FieldTypeNumber:
+		return "number"
+	case feature.FieldTypeBoolean:
+		return "checkbox"
+	}
+	switch field.Format() {
+	case "date":
+		return "date"
+	case "date-time":
+		return "datetime-local"
+	case "time":
+		return "time"
+	case "email":
+		return "email"
+	case "uri":
+		return "url"
+	default:
+		return "text"
+	}
+}
+
+// htmlPattern converts a JSON Schema pattern, which matches anywhere in the value, to
+// an HTML pattern attribute, which must match the whole value.
+func htmlPattern(pattern string) string {
+	if pattern == "" {
+		return ""
+	}
+	if strings.HasPrefix(pattern, "^") {
+		pattern = pattern[1:]
+	} else {
+		pattern = ".*" + pattern
+	}
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, `\$`) {
+		pattern = pattern[:len(pattern)-1]
+	} else {
+		pattern += ".*"
+	}
+	return pattern
+}
+
+// fieldLabel turns a field name such as "customer_id" into "Customer id".
+func fieldLabel(name string) string {
+	label := strings.ReplaceAll(name, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// localDateTimeLayout is the value of a datetime-local input, which has no zone.
+const localDateTimeLayout = "2006-01-02T15:04:05"
+
+func formatValue(v any, format string, loc *time.Location) string {
+	switch v := v.(type) {
+	case string:
+		if format == "date-time" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t.In(loc).Format(localDateTimeLayout)
+			}
+		}
+		return v
+	case float64:
+		return formatNumber(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,215 @@
+package form
+
+import (
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+func newRenderTestForm(opts ...Option) *Form {
+	one, fourteen := 1.0, 14.0
+	sch := newTestSchema(
+		feature.Field{Name: "guest_name", Type: feature.FieldTypeString, Required: true, Pattern: "^[A-Z]"},
+		feature.Field{Name: "nights", Type: feature.FieldTypeInteger, Required: true, Minimum: &one, Maximum: &fourteen},
+		feature.Field{Name: "room", Type: feature.FieldTypeString, Enum: []any{"single", "double"}},
+		feature.Field{Name: "arrival", Type: feature.FieldTypeString, Format: "date"},
+		feature.Field{Name: "breakfast", Type: feature.FieldTypeBoolean},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("guest_name", "<ada>", "room", "double", "breakfast", true)))
+	return New(fe, opts...)
+}
+
+func TestRender(t *testing.T) {
+	fo := newRenderTestForm(WithAction("/bookings"))
+
+	var b strings.Builder
+	if err := fo.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+
+	for _, want := range []string{
+		`<form method="post" action="/bookings">`,
+		`<label for="field-guest_name">Guest name</label>`,
+		`<input type="text" id="field-guest_name" name="guest_name" value="&lt;ada&gt;" required pattern="[A-Z].*">`,
+		`<input type="number" id="field-nights" name="nights" value="" required min="1" max="14" step="1">`,
+		`<option value="double" selected>double</option>`,
+		`<input type="date" id="field-arrival" name="arrival" value="">`,
+		"<input type=\"hidden\" name=\"breakfast\" value=\"false\">\n<input type=\"checkbox\" id=\"field-breakfast\" name=\"breakfast\" value=\"true\" checked>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected rendered form to contain %s, got:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "field-errors") {
+		t.Errorf("expected no errors before Validate, got:\n%s", html)
+	}
+}
+
+func TestRender_ValidationErrors(t *testing.T) {
+	fo := newRenderTestForm()
+	if err := fo.Validate(); err == nil {
+		t.Fatal("expected validation to fail")
+	}
+
+	var b strings.Builder
+	if err := fo.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+
+	for _, want := range []string{
+		`aria-invalid="true" aria-describedby="field-nights-errors"`,
+		`<ul id="field-nights-errors" class="field-errors" role="alert">`,
+		`<li>Nights is required</li>`,
+		`<li>Guest name must match ^[A-Z]</li>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected rendered form to contain %s, got:\n%s", want, html)
+		}
+	}
+}
+
+func TestRender_OverrideTemplate(t *testing.T) {
+	tmpl := DefaultTemplates()
+	template.Must(tmpl.New("errors").Parse(`{{range .Errors}}<span class="oops">{{.}}</span>{{end}}`))
+	fo := newRenderTestForm(WithTemplates(tmpl))
+	_ = fo.Validate()
+
+	var b strings.Builder
+	if err := fo.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<span class="oops">Nights is required</span>`) {
+		t.Errorf("expected overridden errors template, got:\n%s", b.String())
+	}
+}
+
+func TestRender_DateTimeRoundTrip(t *testing.T) {
+	sch := newTestSchema(feature.Field{Name: "at", Type: feature.FieldTypeString, Format: "date-time", Required: true})
+	stored := "2024-05-01T12:30:00+02:00"
+	want, _ := time.Parse(time.RFC3339, stored)
+
+	for name, loc := range map[string]*time.Location{"utc": time.UTC, "cest": time.FixedZone("CEST", 2*60*60)} {
+		t.Run(name, func(t *testing.T) {
+			fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("at", stored)))
+			fo := New(fe, WithLocation(loc))
+
+			var b strings.Builder
+			if err := fo.Render(&b); err != nil {
+				t.Fatal(err)
+			}
+			m := regexp.MustCompile(`name="at" value="([^"]*)"`).FindStringSubmatch(b.String())
+			if m == nil {
+				t.Fatalf("expected a value for at, got:\n%s", b.String())
+			}
+
+			if err := fo.SetFromUrlValues(url.Values{"at": {m[1]}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := fo.Validate(); err != nil {
+				t.Fatalf("expected the unchanged value to stay valid, got %v", err)
+			}
+			at, _ := fe.GetString("at")
+			if got, err := time.Parse(time.RFC3339, at); err != nil || !got.Equal(want) {
+				t.Fatalf("expected %s to be stored as the same time as %s, got %s", m[1], stored, at)
+			}
+		})
+	}
+}
+
+func TestRender_UncheckedCheckbox(t *testing.T) {
+	fo := newRenderTestForm()
+	fe := fo.Feature()
+
+	// A browser submits the hidden input, followed by the checkbox if it is checked.
+	if err := fo.SetFromUrlValues(url.Values{"breakfast": {"false"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fe.Get("breakfast"); got != false {
+		t.Fatalf("expected an unchecked box to set false, got %v", got)
+	}
+	if err := fo.SetFromUrlValues(url.Values{"breakfast": {"false", "true"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := fe.Get("breakfast"); got != true {
+		t.Fatalf("expected a checked box to set true, got %v", got)
+	}
+}
+
+var (
+	renderedInput  = regexp.MustCompile(`<input type="([^"]*)"(?: id="[^"]*")? name="([^"]*)" value="([^"]*)"([^>]*)>`)
+	renderedSelect = regexp.MustCompile(`(?s)<select id="[^"]*" name="([^"]*)"[^>]*>(.*?)</select>`)
+	selectedOption = regexp.MustCompile(`<option value="([^"]*)" selected>`)
+)
+
+// submitted returns the values a browser submits for a rendered form left untouched.
+func submitted(html string) url.Values {
+	values := url.Values{}
+	for _, m := range renderedInput.FindAllStringSubmatch(html, -1) {
+		if m[1] == "checkbox" && !strings.Contains(m[4], " checked") {
+			continue
+		}
+		values.Add(m[2], m[3])
+	}
+	for _, m := range renderedSelect.FindAllStringSubmatch(html, -1) {
+		value := ""
+		if o := selectedOption.FindStringSubmatch(m[2]); o != nil {
+			value = o[1]
+		}
+		values.Add(m[1], value)
+	}
+	return values
+}
+
+func TestRender_SubmitUntouched(t *testing.T) {
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString, Required: true},
+		feature.Field{Name: "qty", Type: feature.FieldTypeInteger},
+		feature.Field{Name: "color", Type: feature.FieldTypeString, Enum: []any{"red", "blue"}},
+		feature.Field{Name: "arrival", Type: feature.FieldTypeString, Format: "date"},
+		feature.Field{Name: "gift", Type: feature.FieldTypeBoolean},
+	)
+	fe := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("name", "Ada", "qty", 2)))
+	fo := New(fe)
+
+	var b strings.Builder
+	if err := fo.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	values := submitted(b.String())
+	if got := values["color"]; len(got) != 1 || got[0] != "" {
+		t.Fatalf("expected the blank option of color to be submitted, got %q", got)
+	}
+
+	if err := fo.SetFromUrlValues(values); err != nil {
+		t.Fatalf("expected an untouched form to bind, got %v", err)
+	}
+	if err := fo.Validate(); err != nil {
+		t.Fatalf("expected an untouched form to stay valid, got %v", err)
+	}
+	if qty, _ := fe.GetInt("qty"); qty != 2 {
+		t.Fatalf("expected qty to stay 2, got %d", qty)
+	}
+	for _, name := range []string{"color", "arrival"} {
+		if fe.Map().Contains(name) {
+			t.Fatalf("expected a blank %s to stay absent, got it set", name)
+		}
+	}
+
+	// Clearing an optional number removes it.
+	values.Set("qty", "")
+	if err := fo.SetFromUrlValues(values); err != nil {
+		t.Fatal(err)
+	}
+	if fe.Map().Contains("qty") {
+		t.Fatal("expected a cleared qty to be removed")
+	}
+}
@@ -0,0 +1,339 @@
+package form
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrInvalidState = errors.New("invalid wizard state")
+	ErrInvalidStep  = errors.New("invalid wizard step")
+)
+
+// Step is one page of a wizard, which asks for some of the fields of the schema.
+type Step struct {
+	Name   string
+	Fields []string
+}
+
+// WizardState is the progress through a wizard: the step to fill in next and the
+// values entered on earlier steps.
+type WizardState struct {
+	// Wizard identifies the wizard the state belongs to.
+	Wizard string
+	Step   int
+	Values *jsonchamp.Map
+}
+
+// StateStore keeps the state of a wizard between requests, and hands out a token the
+// client sends back with the next step.
+type StateStore interface {
+	// Save stores the state and returns its token. The token of the previous state is
+	// given, or empty for a new wizard, so sessions can keep a single token.
+	Save(ctx context.Context, previous string, state WizardState) (string, error)
+	// Load returns the state of a token, or an error wrapping ErrInvalidState.
+	Load(ctx context.Context, token string) (WizardState, error)
+}
+
+// Wizard spreads a form over several steps. Each step binds and validates only its own
+// fields, and the feature is only validated against the whole schema once the last step
+// has been submitted.
+type Wizard struct {
+	id    string
+	sch   feature.Schema
+	steps []Step
+	store StateStore
+	opts  []Option
+}
+
+// NewWizard creates a wizard with the steps, which must name fields of the schema. The
+// options are applied to the form of every step.
+func NewWizard(sch feature.Schema, steps []Step, store StateStore, opts ...Option) (*Wizard, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: a wizard needs at least one step", ErrInvalidStep)
+	}
+	intro := feature.NewSchemaIntrospector(sch)
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		if len(step.Fields) == 0 {
+			return nil, fmt.Errorf("%w: step %d has no fields", ErrInvalidStep, i)
+		}
+		for _, name := range step.Fields {
+			field, err := intro.GetField(name)
+			if err != nil {
+				return nil, err
+			}
+			if !field.Exists() {
+				return nil, fmt.Errorf("%w: step %d refers to unknown field '%s'", ErrInvalidStep, i, name)
+			}
+		}
+		names[i] = step.Name + "=" + strings.Join(step.Fields, ",")
+	}
+	return &Wizard{
+		id:    sch.Schema + " " + strings.Join(names, " "),
+		sch:   sch,
+		steps: steps,
+		store: store,
+		opts:  opts,
+	}, nil
+}
+
+// WizardStep is a step of a wizard in progress.
+type WizardStep struct {
+	// Index is the position of the step, starting at zero.
+	Index int
+	Step  Step
+	// Form holds the fields of the step, with the errors of the last submission.
+	Form *Form
+	// Token is sent back with the submission of the step.
+	Token string
+	// Done is set once every step has been submitted and the feature is valid; Form
+	// then holds the whole feature.
+	Done bool
+}
+
+// Start begins a wizard for a new feature.
+func (w *Wizard) Start(ctx context.Context) (*WizardStep, error) {
+	state := WizardState{Wizard: w.id, Values: jsonchamp.New()}
+	token, err := w.store.Save(ctx, "", state)
+	if err != nil {
+		return nil, err
+	}
+	return w.step(state, token), nil
+}
+
+// Resume returns the step a token is at, to show it again.
+func (w *Wizard) Resume(ctx context.Context, token string) (*WizardStep, error) {
+	state, err := w.load(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return w.step(state, token), nil
+}
+
+// Back returns to the previous step, keeping the values entered so far.
+func (w *Wizard) Back(ctx context.Context, token string) (*WizardStep, error) {
+	state, err := w.load(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if state.Step > 0 {
+		state.Step--
+	}
+	return w.save(ctx, token, state)
+}
+
+// Submit binds the request to the fields of the current step and validates them. If
+// they are valid, the wizard moves on to the next step, and after the last step the
+// feature is validated against the whole schema. If validation fails, the error is
+// returned together with the step to show again, whose form holds the error messages:
+// the current step, or after the last step the first step with an invalid field.
+func (w *Wizard) Submit(ctx context.Context, token string, r *http.Request) (*WizardStep, error) {
+	state, err := w.load(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	current := w.step(state, token)
+	// Values that could not be bound are reported by Validate, other errors here.
+	if err := current.Form.BindRequest(r); err != nil && len(current.Form.bindErrors) == 0 {
+		return current, err
+	}
+	if err := current.Form.Validate(); err != nil {
+		return current, err
+	}
+	state.Values = current.Form.Feature().Map()
+
+	if state.Step < len(w.steps)-1 {
+		state.Step++
+		return w.save(ctx, token, state)
+	}
+
+	final := New(feature.New(w.sch, feature.WithMap(state.Values)), w.opts...)
+	err = final.Validate()
+	if err == nil {
+		return &WizardStep{Index: state.Step, Step: w.steps[state.Step], Form: final, Token: token, Done: true}, nil
+	}
+
+	// Go back to the first step that can correct the errors.
+	errs := final.Errors()
+	for i, step := range w.steps {
+		if slices.ContainsFunc(step.Fields, func(name string) bool { return hasErrors(errs, name) }) {
+			state.Step = i
+			break
+		}
+	}
+	next, saveErr := w.save(ctx, token, state)
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	_ = next.Form.Validate()
+	return next, err
+}
+
+func hasErrors(errs map[string][]string, name string) bool {
+	for key := range errs {
+		if key == name || strings.HasPrefix(key, name+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Wizard) load(ctx context.Context, token string) (WizardState, error) {
+	state, err := w.store.Load(ctx, token)
+	if err != nil {
+		return WizardState{}, err
+	}
+	if state.Wizard != w.id {
+		return WizardState{}, fmt.Errorf("%w: belongs to another wizard", ErrInvalidState)
+	}
+	if state.Step < 0 || state.Step >= len(w.steps) {
+		return WizardState{}, fmt.Errorf("%w: no step %d", ErrInvalidState, state.Step)
+	}
+	if state.Values == nil {
+		state.Values = jsonchamp.New()
+	}
+	return state, nil
+}
+
+func (w *Wizard) save(ctx context.Context, previous string, state WizardState) (*WizardStep, error) {
+	token, err := w.store.Save(ctx, previous, state)
+	if err != nil {
+		return nil, err
+	}
+	return w.step(state, token), nil
+}
+
+func (w *Wizard) step(state WizardState, token string) *WizardStep {
+	step := w.steps[state.Step]
+	fe := feature.New(w.sch, feature.WithMap(state.Values))
+	return &WizardStep{
+		Index: state.Step,
+		Step:  step,
+		Form:  New(fe, append(slices.Clone(w.opts), WithFields(step.Fields...))...),
+		Token: token,
+	}
+}
+
+// SignedTokenStore keeps the whole state of a wizard in its token, signed with
+// HMAC-SHA256 so clients can not change it. It needs no storage, but the token grows
+// with the values entered, and a token stays valid for as long as the secret does.
+type SignedTokenStore struct {
+	secret []byte
+}
+
+// NewSignedTokenStore creates a store signing tokens with the secret.
+func NewSignedTokenStore(secret []byte) *SignedTokenStore {
+	return &SignedTokenStore{secret: secret}
+}
+
+type statePayload struct {
+	Wizard string         `json:"w"`
+	Step   int            `json:"s"`
+	Values *jsonchamp.Map `json:"v"`
+}
+
+// Save implements StateStore.
+func (s *SignedTokenStore) Save(_ context.Context, _ string, state WizardState) (string, error) {
+	data, err := json.Marshal(statePayload{Wizard: state.Wizard, Step: state.Step, Values: state.Values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(s.sign(data)), nil
+}
+
+// Load implements StateStore.
+func (s *SignedTokenStore) Load(_ context.Context, token string) (WizardState, error) {
+	encodedData, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return WizardState{}, fmt.Errorf("%w: malformed token", ErrInvalidState)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encodedData)
+	if err != nil {
+		return WizardState{}, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return WizardState{}, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	if !hmac.Equal(mac, s.sign(data)) {
+		return WizardState{}, fmt.Errorf("%w: bad signature", ErrInvalidState)
+	}
+	payload := statePayload{Values: jsonchamp.New()}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return WizardState{}, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	return WizardState{Wizard: payload.Wizard, Step: payload.Step, Values: payload.Values}, nil
+}
+
+func (s *SignedTokenStore) sign(data []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+var _ StateStore = (*SignedTokenStore)(nil)
+
+// MemoryStateStore keeps wizard states in memory under random session tokens. A
+// session keeps its token from step to step. It suits tests and single-process
+// servers; other session stores implement StateStore the same way.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]WizardState
+}
+
+// NewMemoryStateStore creates an empty store.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string]WizardState)}
+}
+
+// Save implements StateStore.
+func (s *MemoryStateStore) Save(_ context.Context, previous string, state WizardState) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := previous
+	if _, ok := s.states[token]; !ok {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+	}
+	s.states[token] = state
+	return token, nil
+}
+
+// Load implements StateStore.
+func (s *MemoryStateStore) Load(_ context.Context, token string) (WizardState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[token]
+	if !ok {
+		return WizardState{}, fmt.Errorf("%w: unknown token", ErrInvalidState)
+	}
+	return state, nil
+}
+
+// Delete forgets the state of a token, for instance once the wizard is done.
+func (s *MemoryStateStore) Delete(_ context.Context, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, token)
+}
+
+var _ StateStore = (*MemoryStateStore)(nil)
@@ -0,0 +1,152 @@
+package form
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/features/feature"
+)
+
+func newTestWizard(t *testing.T, store StateStore) *Wizard {
+	t.Helper()
+	sch := newTestSchema(
+		feature.Field{Name: "name", Type: feature.FieldTypeString, Required: true},
+		feature.Field{Name: "email", Type: feature.FieldTypeString, Format: "email", Required: true},
+		feature.Field{Name: "age", Type: feature.FieldTypeInteger},
+	)
+	sch.Schema = "urn:features:signup"
+	w, err := NewWizard(sch, []Step{
+		{Name: "profile", Fields: []string{"name", "age"}},
+		{Name: "contact", Fields: []string{"email"}},
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func postForm(values url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestWizard_Steps(t *testing.T) {
+	for name, store := range map[string]StateStore{
+		"signed token": NewSignedTokenStore([]byte("secret")),
+		"memory":       NewMemoryStateStore(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			w := newTestWizard(t, store)
+
+			step, err := w.Start(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if views := step.Form.Fields(); len(views) != 2 || views[0].Name != "name" || views[1].Name != "age" {
+				t.Fatalf("expected the fields of the first step, got %+v", views)
+			}
+
+			// Only the fields of the step are validated, so the missing email is not reported.
+			step, err = w.Submit(ctx, step.Token, postForm(url.Values{"name": {"alice"}, "email": {"ignored"}}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if step.Index != 1 || step.Done {
+				t.Fatalf("expected the second step, got %+v", step)
+			}
+			if _, ok := step.Form.Feature().Get("email"); ok {
+				t.Fatal("fields of other steps should not be bound")
+			}
+
+			resumed, err := w.Resume(ctx, step.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name, _ := resumed.Form.Feature().GetString("name"); resumed.Index != 1 || name != "alice" {
+				t.Fatalf("expected to resume at the second step with the name, got step %d and %q", resumed.Index, name)
+			}
+
+			step, err = w.Submit(ctx, step.Token, postForm(url.Values{"email": {"not an email"}}))
+			if !errors.Is(err, feature.ErrValidation) {
+				t.Fatalf("expected %v, got %v", feature.ErrValidation, err)
+			}
+			if step.Index != 1 || len(step.Form.Errors()["email"]) != 1 {
+				t.Fatalf("expected the second step again with an error for email, got %+v", step.Form.Errors())
+			}
+
+			step, err = w.Submit(ctx, step.Token, postForm(url.Values{"email": {"alice@example.com"}}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !step.Done {
+				t.Fatal("expected the wizard to be done")
+			}
+			if email, _ := step.Form.Feature().GetString("email"); email != "alice@example.com" {
+				t.Fatalf("expected the email to be kept, got %q", email)
+			}
+		})
+	}
+}
+
+func TestWizard_FinalValidation(t *testing.T) {
+	ctx := context.Background()
+	store := NewSignedTokenStore([]byte("secret"))
+	w := newTestWizard(t, store)
+
+	// A state whose first step was skipped, as a buggy client could produce.
+	token, err := store.Save(ctx, "", WizardState{Wizard: w.id, Step: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, err := w.Submit(ctx, token, postForm(url.Values{"email": {"alice@example.com"}}))
+	if !errors.Is(err, feature.ErrValidation) {
+		t.Fatalf("expected %v, got %v", feature.ErrValidation, err)
+	}
+	if step.Index != 0 || step.Done {
+		t.Fatalf("expected to go back to the first step, got %+v", step)
+	}
+	if got := step.Form.Errors()["name"]; len(got) != 1 || got[0] != "Name is required" {
+		t.Fatalf("unexpected errors for name: %v", step.Form.Errors())
+	}
+}
+
+func TestWizard_TamperedToken(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWizard(t, NewSignedTokenStore([]byte("secret")))
+	step, err := w.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := NewSignedTokenStore([]byte("other")).Save(ctx, "", WizardState{Wizard: w.id, Step: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{forged, step.Token + "x", "garbage"} {
+		if _, err := w.Resume(ctx, token); !errors.Is(err, ErrInvalidState) {
+			t.Fatalf("expected %v, got %v", ErrInvalidState, err)
+		}
+	}
+
+	other, err := NewWizard(w.sch, []Step{{Name: "all", Fields: []string{"name", "email"}}}, NewSignedTokenStore([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Resume(ctx, step.Token); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected a token of another wizard to be rejected, got %v", err)
+	}
+}
+
+func TestNewWizard_UnknownField(t *testing.T) {
+	sch := newTestSchema(feature.Field{Name: "name", Type: feature.FieldTypeString})
+	if _, err := NewWizard(sch, []Step{{Name: "one", Fields: []string{"missing"}}}, NewMemoryStateStore()); !errors.Is(err, ErrInvalidStep) {
+		t.Fatalf("expected %v, got %v", ErrInvalidStep, err)
+	}
+}
@@ -6,6 +6,7 @@ replace github.com/mamaar/jsonchamp => ../jsonchamp
 
 require (
 	github.com/mamaar/jsonchamp v0.0.0-20250328165231-46c22dd5d6ed
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/santhosh-tekuri/jsonschema/v6 v6.0.1
 )
 
@@ -0,0 +1,251 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths,omitempty"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components holds the schemas referenced from the rest of the document.
+type Components struct {
+	Schemas map[string]Schema `json:"schemas"`
+}
+
+// Schema is a JSON Schema, as used by OpenAPI 3.1.
+type Schema map[string]any
+
+// PathItem holds the operations of a path, by lower case HTTP method.
+type PathItem map[string]Operation
+
+// Operation is an operation of a path.
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter of an operation.
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation, by media type.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header.
+type Header struct {
+	Description string `json:"description,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// MediaType is the schema of a body of one media type.
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type generator struct {
+	collections map[string]string
+}
+
+type Option func(*generator)
+
+// WithCollection adds the paths of a rest.Handler serving the schema with the URN,
+// mounted at path.
+func WithCollection(urn string, path string) Option {
+	return func(g *generator) {
+		g.collections[urn] = strings.TrimSuffix(path, "/")
+	}
+}
+
+// Generate returns a document with components for the schemas. Every version of a
+// schema, that is every prefix of its migrations, becomes a component named after the
+// URN and the version, such as OrderV2 for the second version of urn:features:order,
+// holding the JSON Schema of Migrations.Reduce. OrderV2Feature wraps it in the feature
+// envelope, Order refers to the latest version and OrderFeature accepts the envelope of
+// any version. The components of all but the latest version are deprecated.
+func Generate(info Info, schemas []feature.Schema, opts ...Option) (*Document, error) {
+	g := &generator{collections: make(map[string]string)}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Components: Components{Schemas: make(map[string]Schema)},
+	}
+	names := make(map[string]string)
+	versions := make(map[string]int)
+	for _, sch := range schemas {
+		name, err := ComponentName(sch.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("%w: %q and %q are both named %s", ErrInvalidSchema, other, sch.Schema, name)
+		}
+		names[name] = sch.Schema
+		versions[sch.Schema] = len(sch.Migrations)
+		if err := addComponents(doc.Components.Schemas, name, sch); err != nil {
+			return nil, err
+		}
+	}
+
+	for urn, path := range g.collections {
+		name, ok := componentNameOf(names, urn)
+		if !ok {
+			return nil, fmt.Errorf("%w: collection %s refers to unknown schema %q", ErrInvalidSchema, path, urn)
+		}
+		if doc.Paths == nil {
+			doc.Paths = make(map[string]PathItem)
+			addErrorComponents(doc.Components.Schemas)
+		}
+		addCollectionPaths(doc.Paths, path, name, versions[urn])
+	}
+	return doc, nil
+}
+
+func componentNameOf(names map[string]string, urn string) (string, bool) {
+	for name, u := range names {
+		if u == urn {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ComponentName returns the component name of a schema URN: the last part of the URN
+// in upper camel case, so urn:features:order_line becomes OrderLine.
+func ComponentName(urn string) (string, error) {
+	i := strings.LastIndexByte(urn, ':')
+	var b strings.Builder
+	upper := true
+	for _, r := range urn[i+1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return "", fmt.Errorf("%w: no component name for URN %q", ErrInvalidSchema, urn)
+	}
+	return name, nil
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+func addComponents(schemas map[string]Schema, name string, sch feature.Schema) error {
+	latest := len(sch.Migrations)
+	if latest == 0 {
+		return fmt.Errorf("%w: %q has no migrations", ErrInvalidSchema, sch.Schema)
+	}
+
+	var envelopes []any
+	for version := 1; version <= latest; version++ {
+		payload, err := reduce(sch.Migrations[:version])
+		if err != nil {
+			return fmt.Errorf("%w: %q version %d: %w", ErrInvalidSchema, sch.Schema, version, err)
+		}
+		deprecated := version < latest
+
+		versionName := name + "V" + strconv.Itoa(version)
+		payload["title"] = fmt.Sprintf("%s version %d", name, version)
+		if description := sch.Migrations[version-1].Description; description != "" {
+			payload["description"] = description
+		}
+		if deprecated {
+			payload["deprecated"] = true
+		}
+		schemas[versionName] = payload
+
+		envelope := Schema{
+			"title":    fmt.Sprintf("%s version %d feature", name, version),
+			"type":     "object",
+			"required": []string{"schema_urn", "schema_version", "payload"},
+			"properties": map[string]any{
+				"schema_urn":     Schema{"type": "string", "const": sch.Schema},
+				"schema_version": Schema{"type": "integer", "const": version},
+				"payload":        ref(versionName),
+			},
+		}
+		if deprecated {
+			envelope["deprecated"] = true
+		}
+		schemas[versionName+"Feature"] = envelope
+		envelopes = append(envelopes, ref(versionName+"Feature"))
+	}
+
+	schemas[name] = ref(name + "V" + strconv.Itoa(latest))
+	schemas[name+"Feature"] = Schema{"oneOf": envelopes}
+	return nil
+}
+
+// reduce returns the JSON Schema of the migrations as a Schema.
+func reduce(migrations feature.Migrations) (Schema, error) {
+	reduced, err := migrations.Reduce()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(reduced)
+	if err != nil {
+		return nil, err
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	s["type"] = "object"
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mamaar/features/feature"
+)
+
+var orderSchema = feature.Schema{
+	Schema: "urn:features:order_line",
+	Migrations: feature.Migrations{
+		{Description: "Initial schema", Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+			feature.AddField{Field: feature.Field{Name: "note", Type: feature.FieldTypeString}},
+		}},
+		{Description: "Add count, drop note", Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true, Default: 1}},
+			feature.RemoveField{FieldName: "note"},
+		}},
+	},
+}
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate(Info{Title: "Orders", Version: "1.0"}, []feature.Schema{orderSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Paths != nil {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+
+	schemas := doc.Components.Schemas
+	v1, v2 := schemas["OrderLineV1"], schemas["OrderLineV2"]
+	if v1["deprecated"] != true || v2["deprecated"] != nil {
+		t.Fatalf("expected only the first version to be deprecated, got %v and %v", v1["deprecated"], v2["deprecated"])
+	}
+	if _, ok := v1["properties"].(map[string]any)["note"]; !ok {
+		t.Fatalf("expected note in the first version, got %v", v1["properties"])
+	}
+	if _, ok := v2["properties"].(map[string]any)["note"]; ok {
+		t.Fatalf("expected note to be removed from the second version, got %v", v2["properties"])
+	}
+	if v2["description"] != "Add count, drop note" || v2["type"] != "object" {
+		t.Fatalf("unexpected second version: %v", v2)
+	}
+
+	envelope := schemas["OrderLineV2Feature"]
+	props := envelope["properties"].(map[string]any)
+	if props["schema_version"].(Schema)["const"] != 2 || props["payload"].(Schema)["$ref"] != "#/components/schemas/OrderLineV2" {
+		t.Fatalf("unexpected envelope: %v", envelope)
+	}
+	if schemas["OrderLine"]["$ref"] != "#/components/schemas/OrderLineV2" {
+		t.Fatalf("expected OrderLine to refer to the latest version, got %v", schemas["OrderLine"])
+	}
+	if got := schemas["OrderLineFeature"]["oneOf"].([]any); len(got) != 2 {
+		t.Fatalf("expected the envelopes of both versions, got %v", got)
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerate_Collection(t *testing.T) {
+	doc, err := Generate(Info{Title: "Orders", Version: "1.0"}, []feature.Schema{orderSchema}, WithCollection("urn:features:order_line", "/lines/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := doc.Paths["/lines/{key}"]
+	if !ok {
+		t.Fatalf("expected the item path, got %v", doc.Paths)
+	}
+	put := item["put"]
+	if put.RequestBody.Content["application/json"].Schema["$ref"] != "#/components/schemas/OrderLineFeature" {
+		t.Fatalf("expected requests to accept any version, got %v", put.RequestBody)
+	}
+	if put.Responses["200"].Content["application/json"].Schema["$ref"] != "#/components/schemas/OrderLineV2Feature" {
+		t.Fatalf("expected responses to hold the latest version, got %v", put.Responses["200"])
+	}
+	if _, ok := put.Responses["409"]; !ok {
+		t.Fatal("expected a conflict response")
+	}
+	if _, ok := doc.Components.Schemas["Error"]; !ok {
+		t.Fatal("expected the error component")
+	}
+	if _, ok := doc.Paths["/lines/"]["post"]; !ok {
+		t.Fatal("expected the create operation")
+	}
+}
+
+func TestGenerate_InvalidSchemas(t *testing.T) {
+	clash := orderSchema
+	clash.Schema = "urn:other:order-line"
+	tests := map[string][]feature.Schema{
+		"duplicate name": {orderSchema, clash},
+		"no name":        {{Schema: "urn:features:", Migrations: orderSchema.Migrations}},
+		"no migrations":  {{Schema: "urn:features:empty"}},
+	}
+	for name, schemas := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Generate(Info{}, schemas); !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("expected %v, got %v", ErrInvalidSchema, err)
+			}
+		})
+	}
+	if _, err := Generate(Info{}, []feature.Schema{orderSchema}, WithCollection("urn:features:missing", "/x")); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected %v for an unknown collection schema, got %v", ErrInvalidSchema, err)
+	}
+}
@@ -0,0 +1,164 @@
+package openapi
+
+import "strconv"
+
+// addErrorComponents adds the error bodies of a rest.Handler.
+func addErrorComponents(schemas map[string]Schema) {
+	schemas["Error"] = Schema{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]any{
+			"error": Schema{"type": "string"},
+			"errors": Schema{
+				"description": "The invalid values of a feature that failed validation.",
+				"type":        "array",
+				"items": Schema{
+					"type":     "object",
+					"required": []string{"path", "message"},
+					"properties": map[string]any{
+						"path":    Schema{"type": "array", "items": Schema{"type": "string"}},
+						"message": Schema{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+	schemas["Conflict"] = Schema{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]any{
+			"error":    Schema{"type": "string"},
+			"revision": Schema{"description": "The current revision of the feature.", "type": "integer"},
+			"merged":   Schema{"description": "The submitted feature reconciled with the current one."},
+			"conflicts": Schema{
+				"type": "array",
+				"items": Schema{
+					"type":     "object",
+					"required": []string{"kind"},
+					"properties": map[string]any{
+						"kind":     Schema{"type": "string", "enum": []string{"value", "schema"}},
+						"path":     Schema{"type": "string"},
+						"base":     Schema{},
+						"incoming": Schema{},
+						"head":     Schema{},
+						"error":    Schema{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// addCollectionPaths adds the operations of a rest.Handler mounted at path, serving the
+// schema with the component name. Requests may use any version of the schema, while
+// responses hold the latest version.
+func addCollectionPaths(paths map[string]PathItem, path string, name string, latestVersion int) {
+	envelope := ref(name + "Feature")
+	latest := ref(name + "V" + strconv.Itoa(latestVersion) + "Feature")
+
+	jsonBody := func(s Schema) map[string]MediaType {
+		return map[string]MediaType{"application/json": {Schema: s}}
+	}
+	etag := map[string]Header{"ETag": {Description: "The revision of the feature.", Schema: Schema{"type": "string"}}}
+	errorResponse := func(description string) Response {
+		return Response{Description: description, Content: jsonBody(ref("Error"))}
+	}
+	conflict := Response{Description: "The feature has changed since the revision of If-Match.", Headers: etag, Content: jsonBody(ref("Conflict"))}
+	invalid := errorResponse("The feature does not match the schema.")
+	notFound := errorResponse("No feature has the key.")
+	featureResponse := func(description string) Response {
+		return Response{Description: description, Headers: etag, Content: jsonBody(latest)}
+	}
+	key := Parameter{Name: "key", In: "path", Required: true, Schema: Schema{"type": "string"}}
+	ifMatch := Parameter{Name: "If-Match", In: "header", Description: "Only write if the feature is at this revision.", Schema: Schema{"type": "string"}}
+
+	paths[path+"/"] = PathItem{
+		"get": {
+			OperationID: "list" + name,
+			Summary:     "List " + name + " features",
+			Parameters: []Parameter{
+				{Name: "filter", In: "query", Description: "A filter such as status = \"open\".", Schema: Schema{"type": "string"}},
+				{Name: "limit", In: "query", Schema: Schema{"type": "integer", "minimum": 1, "maximum": 1000}},
+				{Name: "cursor", In: "query", Description: "The cursor of the previous page.", Schema: Schema{"type": "string"}},
+			},
+			Responses: map[string]Response{
+				"200": {Description: "A page of features.", Content: jsonBody(Schema{
+					"type":     "object",
+					"required": []string{"items"},
+					"properties": map[string]any{
+						"items": Schema{"type": "array", "items": Schema{
+							"type":     "object",
+							"required": []string{"key", "revision", "feature"},
+							"properties": map[string]any{
+								"key":      Schema{"type": "string"},
+								"revision": Schema{"type": "integer"},
+								"feature":  envelope,
+							},
+						}},
+						"cursor": Schema{"type": "string"},
+					},
+				})},
+				"400": errorResponse("The parameters are invalid."),
+			},
+		},
+		"post": {
+			OperationID: "create" + name,
+			Summary:     "Create a " + name + " feature",
+			RequestBody: &RequestBody{Required: true, Content: jsonBody(envelope)},
+			Responses: map[string]Response{
+				"201": featureResponse("The created feature, migrated to the latest version."),
+				"409": conflict,
+				"422": invalid,
+			},
+		},
+	}
+	paths[path+"/{key}"] = PathItem{
+		"get": {
+			OperationID: "get" + name,
+			Summary:     "Get a " + name + " feature",
+			Parameters:  []Parameter{key},
+			Responses: map[string]Response{
+				"200": featureResponse("The feature."),
+				"304": {Description: "The feature is at the revision of If-None-Match."},
+				"404": notFound,
+			},
+		},
+		"put": {
+			OperationID: "replace" + name,
+			Summary:     "Replace a " + name + " feature",
+			Parameters:  []Parameter{key, ifMatch},
+			RequestBody: &RequestBody{Required: true, Content: jsonBody(envelope)},
+			Responses: map[string]Response{
+				"200": featureResponse("The replaced feature."),
+				"201": featureResponse("The created feature."),
+				"409": conflict,
+				"422": invalid,
+			},
+		},
+		"patch": {
+			OperationID: "patch" + name,
+			Summary:     "Patch a " + name + " feature",
+			Parameters:  []Parameter{key, ifMatch},
+			RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/merge-patch+json": {Schema: Schema{"type": "object"}},
+				"application/json-patch+json":  {Schema: Schema{"type": "array", "items": Schema{"type": "object"}}},
+			}},
+			Responses: map[string]Response{
+				"200": featureResponse("The patched feature."),
+				"404": notFound,
+				"409": conflict,
+				"422": invalid,
+			},
+		},
+		"delete": {
+			OperationID: "delete" + name,
+			Summary:     "Delete a " + name + " feature",
+			Parameters:  []Parameter{key, ifMatch},
+			Responses: map[string]Response{
+				"204": {Description: "The feature was deleted."},
+				"404": notFound,
+				"409": errorResponse("The feature has changed since the revision of If-Match."),
+			},
+		},
+	}
+}
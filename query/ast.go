@@ -0,0 +1,82 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mamaar/features/feature"
+)
+
+// Expr is a predicate over a feature.
+type Expr interface {
+	// Eval reports whether the feature matches. Comparisons with missing properties,
+	// or with properties of another type than the value, do not match.
+	Eval(f *feature.Feature) bool
+	String() string
+}
+
+// Op is a comparison operator.
+type Op string
+
+const (
+	OpEqual          Op = "="
+	OpNotEqual       Op = "!="
+	OpLess           Op = "<"
+	OpLessOrEqual    Op = "<="
+	OpGreater        Op = ">"
+	OpGreaterOrEqual Op = ">="
+)
+
+// Compare compares a property with a literal value: a string, int64, float64 or bool.
+type Compare struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+// And matches if both operands match.
+type And struct {
+	Left, Right Expr
+}
+
+// Or matches if either operand matches.
+type Or struct {
+	Left, Right Expr
+}
+
+// Not matches if its operand does not match.
+type Not struct {
+	Expr Expr
+}
+
+func (c Compare) String() string {
+	return fmt.Sprintf("%s %s %s", c.Field, c.Op, formatValue(c.Value))
+}
+
+func (a And) String() string {
+	return fmt.Sprintf("(%s AND %s)", a.Left, a.Right)
+}
+
+func (o Or) String() string {
+	return fmt.Sprintf("(%s OR %s)", o.Left, o.Right)
+}
+
+func (n Not) String() string {
+	return fmt.Sprintf("NOT %s", n.Expr)
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			// Keep the literal a float when parsed back.
+			s += ".0"
+		}
+		return s
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,72 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrUnknownField = errors.New("unknown field")
+	ErrTypeMismatch = errors.New("type mismatch")
+)
+
+// Check verifies that every property in the expression is a field of the schema, and
+// that it is compared with a value of its type. Integer fields only accept integers,
+// while number fields accept both integers and floats.
+func Check(e Expr, sch feature.Schema) error {
+	return check(e, feature.NewSchemaIntrospector(sch))
+}
+
+// Compile parses a filter and checks it against the schema.
+func Compile(sch feature.Schema, s string) (Expr, error) {
+	e, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := Check(e, sch); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func check(e Expr, intro *feature.SchemaIntrospector) error {
+	switch e := e.(type) {
+	case And:
+		return errors.Join(check(e.Left, intro), check(e.Right, intro))
+	case Or:
+		return errors.Join(check(e.Left, intro), check(e.Right, intro))
+	case Not:
+		return check(e.Expr, intro)
+	case Compare:
+		field, err := intro.GetField(e.Field)
+		if err != nil {
+			return err
+		}
+		if !field.Exists() {
+			return fmt.Errorf("%w: %s", ErrUnknownField, e.Field)
+		}
+		if !accepts(field.Type(), e.Value) {
+			return fmt.Errorf("%w: %s is %s, compared with %s", ErrTypeMismatch, e.Field, field.Type(), formatValue(e.Value))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+func accepts(typ feature.FieldType, v any) bool {
+	switch v.(type) {
+	case string:
+		return typ == feature.FieldTypeString
+	case int64:
+		return typ == feature.FieldTypeInteger || typ == feature.FieldTypeNumber
+	case float64:
+		return typ == feature.FieldTypeNumber
+	case bool:
+		return typ == feature.FieldTypeBoolean
+	default:
+		return false
+	}
+}
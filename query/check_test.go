@@ -0,0 +1,47 @@
+package query
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamaar/features/feature"
+)
+
+var orderSchema = feature.Schema{
+	Schema: "urn:features:order",
+	Migrations: feature.Migrations{
+		{
+			Description: "Initial schema",
+			Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "total", Type: feature.FieldTypeNumber}},
+				feature.AddField{Field: feature.Field{Name: "items", Type: feature.FieldTypeInteger}},
+			},
+		},
+	},
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr error
+	}{
+		{in: `status = "open" AND total > 100`},
+		{in: `total > 99.5 OR items <= 3`},
+		{in: `missing = 1`, wantErr: ErrUnknownField},
+		{in: `status = 1`, wantErr: ErrTypeMismatch},
+		{in: `items = 1.5`, wantErr: ErrTypeMismatch},
+		{in: `total = "a lot"`, wantErr: ErrTypeMismatch},
+		{in: `status = true`, wantErr: ErrTypeMismatch},
+		{in: `NOT (status = "open" AND missing = 1)`, wantErr: ErrUnknownField},
+		{in: `status =`, wantErr: ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := Compile(orderSchema, tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Compile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package query
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/mamaar/features/feature"
+)
+
+// Eval implements Expr.
+func (c Compare) Eval(f *feature.Feature) bool {
+	v, ok := f.Get(c.Field)
+	if !ok {
+		return false
+	}
+	order, ok := compareValues(v, c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpEqual:
+		return order == 0
+	case OpNotEqual:
+		return order != 0
+	case OpLess:
+		return order < 0
+	case OpLessOrEqual:
+		return order <= 0
+	case OpGreater:
+		return order > 0
+	case OpGreaterOrEqual:
+		return order >= 0
+	default:
+		return false
+	}
+}
+
+// Eval implements Expr.
+func (a And) Eval(f *feature.Feature) bool {
+	return a.Left.Eval(f) && a.Right.Eval(f)
+}
+
+// Eval implements Expr.
+func (o Or) Eval(f *feature.Feature) bool {
+	return o.Left.Eval(f) || o.Right.Eval(f)
+}
+
+// Eval implements Expr.
+func (n Not) Eval(f *feature.Feature) bool {
+	return !n.Expr.Eval(f)
+}
+
+// Filter returns the features matching the expression.
+func Filter(e Expr, features []*feature.Feature) []*feature.Feature {
+	var res []*feature.Feature
+	for _, f := range features {
+		if e.Eval(f) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// compareValues orders two values of the same kind. Integers and floats compare
+// numerically, and false orders before true.
+func compareValues(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b), true
+		}
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	case bool:
+		b, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a == b:
+			return 0, true
+		case b:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,49 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+func TestEval(t *testing.T) {
+	open := feature.New(orderSchema, feature.WithMap(jsonchamp.NewFromItems("status", "open", "total", 150.0, "items", 3)))
+	closed := feature.New(orderSchema, feature.WithMap(jsonchamp.NewFromItems("status", "closed", "total", 50.0)))
+
+	tests := []struct {
+		in   string
+		want []bool
+	}{
+		{in: `status = "open" AND total > 100`, want: []bool{true, false}},
+		{in: `status != "open"`, want: []bool{false, true}},
+		{in: `total >= 50 AND total < 150.5`, want: []bool{true, true}},
+		{in: `items = 3`, want: []bool{true, false}},
+		{in: `NOT items = 3`, want: []bool{false, true}},
+		{in: `items != 3`, want: []bool{false, false}},
+		{in: `status > "clos" OR items > 10`, want: []bool{true, true}},
+		{in: `status = 1`, want: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			e, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, f := range []*feature.Feature{open, closed} {
+				if got := e.Eval(f); got != tt.want[i] {
+					t.Errorf("Eval(feature %d) = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	e, err := Parse(`status = "closed"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Filter(e, []*feature.Feature{open, closed}); len(got) != 1 || got[0] != closed {
+		t.Fatalf("Filter() = %v, want the closed order", got)
+	}
+}
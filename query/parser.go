@@ -0,0 +1,255 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrSyntax = errors.New("syntax error")
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Parse parses a filter such as
+//
+//	status = "open" AND (total > 100 OR NOT priority < 3)
+//
+// Comparisons are =, !=, <, <=, > and >=, with a property on the left and a string,
+// number, true or false on the right. NOT binds tighter than AND, which binds tighter
+// than OR. Keywords are case-insensitive.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: s, tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return e, nil
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			op := s[i:j]
+			if op == "!" {
+				return nil, fmt.Errorf("%w: %q: unexpected '!' at position %d", ErrSyntax, s, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("%w: %q: unterminated string at position %d", ErrSyntax, s, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i : j+1], pos: i})
+			i = j + 1
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || strings.ContainsRune(".eE+-", rune(s[j]))) {
+				if (s[j] == '+' || s[j] == '-') && s[j-1] != 'e' && s[j-1] != 'E' {
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[i:j], pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j], pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: %q: unexpected %q at position %d", ErrSyntax, s, c, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("%w: %q at position %d: %s", ErrSyntax, p.src, tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) keyword(kw string) bool {
+	if tok := p.peek(); tok.kind == tokenIdent && strings.EqualFold(tok.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.keyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorf(closing, "expected ')'")
+		}
+		return e, nil
+	case tokenIdent:
+		if isKeyword(tok.text) {
+			return nil, p.errorf(tok, "expected property, got %q", tok.text)
+		}
+		op := p.next()
+		switch Op(op.text) {
+		case OpEqual, OpNotEqual, OpLess, OpLessOrEqual, OpGreater, OpGreaterOrEqual:
+		default:
+			return nil, p.errorf(op, "expected comparison after %q", tok.text)
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return Compare{Field: tok.text, Op: Op(op.text), Value: value}, nil
+	default:
+		return nil, p.errorf(tok, "expected property or '('")
+	}
+}
+
+func (p *parser) parseValue() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		s, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid string %s", tok.text)
+		}
+		return s, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %s", tok.text)
+		}
+		return f, nil
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, p.errorf(tok, "expected value, got %q", tok.text)
+}
+
+func isKeyword(s string) bool {
+	switch strings.ToUpper(s) {
+	case "AND", "OR", "NOT", "TRUE", "FALSE":
+		return true
+	}
+	return false
+}
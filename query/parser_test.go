@@ -0,0 +1,63 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: `status = "open"`, want: `status = "open"`},
+		{in: `status = "open" AND total > 100`, want: `(status = "open" AND total > 100)`},
+		{in: `a = 1 OR b = 2 AND c = 3`, want: `(a = 1 OR (b = 2 AND c = 3))`},
+		{in: `(a = 1 OR b = 2) and not c != 3`, want: `((a = 1 OR b = 2) AND NOT c != 3)`},
+		{in: `total >= -1.5e2`, want: `total >= -150.0`},
+		{in: `ratio < 0.25`, want: `ratio < 0.25`},
+		{in: `done = TRUE`, want: `done = true`},
+		{in: `name <= "say \"hi\""`, want: `name <= "say \"hi\""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			e, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.String(); got != tt.want {
+				t.Fatalf("Parse() = %s, want %s", got, tt.want)
+			}
+			// The string form parses back to the same expression.
+			again, err := Parse(e.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.String() != e.String() {
+				t.Fatalf("Parse(String()) = %s, want %s", again, e)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		``,
+		`status`,
+		`status = `,
+		`status == "open"`,
+		`status ! "open"`,
+		`status = "open`,
+		`(status = "open"`,
+		`status = "open")`,
+		`status = "open" AND`,
+		`AND = 1`,
+		`status = open`,
+		`1 = status`,
+		`status = "open" # comment`,
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) error = %v, want %v", in, err, ErrSyntax)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type sqlBuilder struct {
+	placeholder func(n int) string
+	column      func(field string) string
+	args        []any
+}
+
+type SQLOption func(*sqlBuilder)
+
+// QuestionPlaceholder returns "?" for every argument, as used by SQLite and MySQL.
+func QuestionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder returns "$n", as used by PostgreSQL.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// WithPlaceholder sets the function that renders the placeholder of the nth argument,
+// counting from 1. It defaults to QuestionPlaceholder.
+func WithPlaceholder(placeholder func(n int) string) SQLOption {
+	return func(b *sqlBuilder) {
+		b.placeholder = placeholder
+	}
+}
+
+// WithColumn sets the function that renders the SQL expression for a property, for
+// example to read it from a JSON column. By default properties are quoted column names.
+func WithColumn(column func(field string) string) SQLOption {
+	return func(b *sqlBuilder) {
+		b.column = column
+	}
+}
+
+// JSONExtract returns a column function for WithColumn that reads properties out of a
+// JSON column with json_extract, as in SQLite and MySQL. Unlike the ->> operator, it
+// returns numbers as numbers, so they compare by value.
+func JSONExtract(column string) func(field string) string {
+	return func(field string) string {
+		path := `$."` + strings.ReplaceAll(field, `"`, `\"`) + `"`
+		return fmt.Sprintf("json_extract(%s, '%s')", QuoteIdentifier(column), strings.ReplaceAll(path, "'", "''"))
+	}
+}
+
+// QuoteIdentifier quotes a column name for SQL.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ToSQL renders the expression as a SQL condition for a WHERE clause, with the values
+// as arguments. Missing properties are NULL in SQL; NOT treats unknown results as false
+// so that the condition matches the same features as Eval.
+func ToSQL(e Expr, opts ...SQLOption) (string, []any, error) {
+	b := &sqlBuilder{
+		placeholder: QuestionPlaceholder,
+		column:      QuoteIdentifier,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	s, err := b.build(e)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, b.args, nil
+}
+
+func (b *sqlBuilder) build(e Expr) (string, error) {
+	switch e := e.(type) {
+	case And:
+		return b.binary(e.Left, "AND", e.Right)
+	case Or:
+		return b.binary(e.Left, "OR", e.Right)
+	case Not:
+		inner, err := b.build(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("NOT COALESCE(%s, FALSE)", inner), nil
+	case Compare:
+		op := string(e.Op)
+		if e.Op == OpNotEqual {
+			op = "<>"
+		}
+		b.args = append(b.args, e.Value)
+		return fmt.Sprintf("%s %s %s", b.column(e.Field), op, b.placeholder(len(b.args))), nil
+	default:
+		return "", fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+func (b *sqlBuilder) binary(left Expr, op string, right Expr) (string, error) {
+	l, err := b.build(left)
+	if err != nil {
+		return "", err
+	}
+	r, err := b.build(right)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s %s %s)", l, op, r), nil
+}
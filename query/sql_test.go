@@ -0,0 +1,63 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestToSQL(t *testing.T) {
+	e, err := Parse(`status = "open" AND (total > 100 OR NOT items != 3)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []SQLOption
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "default",
+			wantSQL:  `("status" = ? AND ("total" > ? OR NOT COALESCE("items" <> ?, FALSE)))`,
+			wantArgs: []any{"open", int64(100), int64(3)},
+		},
+		{
+			name: "json_extract column",
+			opts: []SQLOption{
+				WithColumn(JSONExtract("payload")),
+			},
+			wantSQL:  `(json_extract("payload", '$."status"') = ? AND (json_extract("payload", '$."total"') > ? OR NOT COALESCE(json_extract("payload", '$."items"') <> ?, FALSE)))`,
+			wantArgs: []any{"open", int64(100), int64(3)},
+		},
+		{
+			name: "postgres json column",
+			opts: []SQLOption{
+				WithPlaceholder(DollarPlaceholder),
+				WithColumn(func(field string) string { return fmt.Sprintf("payload->>'%s'", field) }),
+			},
+			wantSQL:  `(payload->>'status' = $1 AND (payload->>'total' > $2 OR NOT COALESCE(payload->>'items' <> $3, FALSE)))`,
+			wantArgs: []any{"open", int64(100), int64(3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := ToSQL(e, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("ToSQL() sql = %s, want %s", sql, tt.wantSQL)
+			}
+			if fmt.Sprint(args) != fmt.Sprint(tt.wantArgs) {
+				t.Errorf("ToSQL() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if got := QuoteIdentifier(`we"ird`); got != `"we""ird"` {
+		t.Fatalf("QuoteIdentifier() = %s", got)
+	}
+}
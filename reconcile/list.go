@@ -0,0 +1,260 @@
+package reconcile
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+// mergeLists merges lists that were changed on both sides. Lists of objects are merged
+// by identity when an identity key is configured, otherwise elements are aligned by
+// position using the longest common subsequence with the base.
+func (r *reconciler) mergeLists(path []string, incoming []any, base []any, head []any) []any {
+	if r.identityKey != "" && r.hasIdentity(incoming) && r.hasIdentity(base) && r.hasIdentity(head) {
+		return r.mergeRecords(path, incoming, base, head)
+	}
+	return r.mergePositional(path, incoming, base, head)
+}
+
+// mergePositional is a diff3 style merge. Elements that are unchanged on both sides
+// act as anchors, and each region between two anchors is resolved on its own.
+func (r *reconciler) mergePositional(path []string, incoming []any, base []any, head []any) []any {
+	toIncoming := matches(base, incoming, equal)
+	toHead := matches(base, head, equal)
+
+	merged := []any{}
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base element that is kept by both sides.
+		anchor := i
+		for anchor < len(base) {
+			_, inIncoming := toIncoming[anchor]
+			_, inHead := toHead[anchor]
+			if inIncoming && inHead {
+				break
+			}
+			anchor++
+		}
+
+		bEnd, iEnd, kEnd := len(base), len(incoming), len(head)
+		if anchor < len(base) {
+			bEnd, iEnd, kEnd = anchor, toIncoming[anchor], toHead[anchor]
+		}
+
+		merged = append(merged, r.mergeRegion(path, len(merged), incoming[j:iEnd], base[i:bEnd], head[k:kEnd])...)
+
+		if anchor == len(base) {
+			return merged
+		}
+		merged = append(merged, head[kEnd])
+		i, j, k = bEnd+1, iEnd+1, kEnd+1
+	}
+}
+
+// mergeRegion resolves a region of a list that lies between two anchors.
+func (r *reconciler) mergeRegion(path []string, offset int, incoming []any, base []any, head []any) []any {
+	switch {
+	case equalLists(incoming, base):
+		return head
+	case equalLists(head, base):
+		return incoming
+	case equalLists(incoming, head):
+		return head
+	case len(base) == 0:
+		// Both sides inserted at the same position without touching existing elements.
+		return append(slices.Clone(head), incoming...)
+	case len(incoming) == len(base) && len(head) == len(base):
+		// Both sides edited the same elements in place, so merge them one by one.
+		merged := make([]any, len(base))
+		for n := range base {
+			elemPath := append(slices.Clone(path), fmt.Sprint(offset+n))
+			merged[n], _ = r.mergeValue(elemPath, incoming[n], true, base[n], true, head[n], true)
+		}
+		return merged
+	}
+
+	r.conflict(append(slices.Clone(path), fmt.Sprint(offset)), base, incoming, head)
+	return head
+}
+
+// mergeRecords merges lists of objects by their identity key. Elements are merged
+// individually, and the order is taken from head with the elements that incoming
+// inserted or moved placed after the same predecessor as in incoming. If an identity is
+// not unique within a list, the elements can not be told apart and the whole list is
+// reported as a conflict.
+func (r *reconciler) mergeRecords(path []string, incoming []any, base []any, head []any) []any {
+	labels := make(map[string]string)
+	incomingIDs, incomingByID, incomingUnique := r.index(incoming, labels)
+	baseIDs, baseByID, baseUnique := r.index(base, labels)
+	headIDs, headByID, headUnique := r.index(head, labels)
+	if !incomingUnique || !baseUnique || !headUnique {
+		r.conflict(path, base, incoming, head)
+		return head
+	}
+
+	merged := make(map[string]any)
+	for _, id := range unionIDs(incomingIDs, baseIDs, headIDs) {
+		iv, iok := incomingByID[id]
+		bv, bok := baseByID[id]
+		hv, hok := headByID[id]
+
+		v, ok := r.mergeValue(append(slices.Clone(path), labels[id]), iv, iok, bv, bok, hv, hok)
+		if ok {
+			merged[id] = v
+		}
+	}
+
+	incomingMoved := moved(baseIDs, incomingIDs)
+	headMoved := moved(baseIDs, headIDs)
+
+	order := make([]string, 0, len(merged))
+	for _, id := range headIDs {
+		if _, ok := merged[id]; ok {
+			order = append(order, id)
+		}
+	}
+
+	for n, id := range incomingIDs {
+		if _, ok := merged[id]; !ok || !incomingMoved[id] {
+			continue
+		}
+		predecessor := -1
+		for p := n - 1; p >= 0; p-- {
+			if idx := slices.Index(order, incomingIDs[p]); idx >= 0 {
+				predecessor = idx
+				break
+			}
+		}
+		current := slices.Index(order, id)
+		if current >= 0 && headMoved[id] {
+			if current != predecessor+1 {
+				// Both sides moved the element; report the positions it was moved to.
+				r.conflict(append(slices.Clone(path), labels[id]), slices.Index(baseIDs, id), n, slices.Index(headIDs, id))
+			}
+			continue
+		}
+		if current >= 0 {
+			order = slices.Delete(order, current, current+1)
+			if current <= predecessor {
+				predecessor--
+			}
+		}
+		order = slices.Insert(order, predecessor+1, id)
+	}
+
+	result := make([]any, 0, len(order))
+	for _, id := range order {
+		result = append(result, merged[id])
+	}
+	return result
+}
+
+func (r *reconciler) hasIdentity(list []any) bool {
+	for _, v := range list {
+		m, ok := v.(*jsonchamp.Map)
+		if !ok || !m.Contains(r.identityKey) {
+			return false
+		}
+	}
+	return true
+}
+
+// index returns the identities of the elements of a list and the elements by identity,
+// and reports whether the identities are unique. Identities include the type of the
+// value, so that the number 1 and the string "1" are different elements; labels maps
+// them to the value as it appears in conflict paths.
+func (r *reconciler) index(list []any, labels map[string]string) ([]string, map[string]any, bool) {
+	ids := make([]string, 0, len(list))
+	byID := make(map[string]any, len(list))
+	for _, v := range list {
+		id, _ := v.(*jsonchamp.Map).Get(r.identityKey)
+		key := identity(id)
+		if _, exists := byID[key]; exists {
+			return nil, nil, false
+		}
+		ids = append(ids, key)
+		byID[key] = v
+		labels[key] = fmt.Sprint(id)
+	}
+	return ids, byID, true
+}
+
+func identity(id any) string {
+	switch id.(type) {
+	case int, int64, float64:
+		// Decoded JSON numbers may be integers or floats; equal numbers are one identity.
+		return "number:" + fmt.Sprint(id)
+	default:
+		return fmt.Sprintf("%T:%v", id, id)
+	}
+}
+
+// moved returns the identifiers in changed that were inserted or moved relative to base.
+func moved(base []string, changed []string) map[string]bool {
+	kept := make(map[string]bool)
+	for _, j := range matches(base, changed, func(a, b string) bool { return a == b }) {
+		kept[changed[j]] = true
+	}
+	res := make(map[string]bool)
+	for _, id := range changed {
+		if !kept[id] {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+// matches returns the index pairs of a longest common subsequence of a and b,
+// mapping indices in a to indices in b.
+func matches[T any](a []T, b []T, eq func(T, T) bool) map[int]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if eq(a[i], b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	res := make(map[int]int)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case eq(a[i], b[j]):
+			res[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return res
+}
+
+func equal(a any, b any) bool {
+	return same(a, true, b, true)
+}
+
+func equalLists(a []any, b []any) bool {
+	return slices.EqualFunc(a, b, equal)
+}
+
+func unionIDs(lists ...[]string) []string {
+	var ids []string
+	for _, list := range lists {
+		for _, id := range list {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,135 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+)
+
+func rec(id string, kvs ...any) *jsonchamp.Map {
+	return jsonchamp.NewFromItems(append([]any{"id", id}, kvs...)...)
+}
+
+func TestReconcileLists(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		incoming      []any
+		base          []any
+		head          []any
+		want          []any
+		wantConflicts int
+	}{
+		{
+			name:     "insertions on both sides",
+			incoming: []any{0, 1, 2, 3},
+			base:     []any{1, 2, 3},
+			head:     []any{1, 2, 3, 4},
+			want:     []any{0, 1, 2, 3, 4},
+		},
+		{
+			name:     "deletion and insertion",
+			incoming: []any{1, 3},
+			base:     []any{1, 2, 3},
+			head:     []any{1, 2, 3, 4},
+			want:     []any{1, 3, 4},
+		},
+		{
+			name:     "appends on both sides",
+			incoming: []any{1, 2, 3},
+			base:     []any{1},
+			head:     []any{1, 4},
+			want:     []any{1, 4, 2, 3},
+		},
+		{
+			name:          "same element replaced differently",
+			incoming:      []any{1, 5, 3},
+			base:          []any{1, 2, 3},
+			head:          []any{1, 6, 3},
+			want:          []any{1, 6, 3},
+			wantConflicts: 1,
+		},
+		{
+			name:     "positional objects merged in place",
+			incoming: []any{jsonchamp.NewFromItems("a", 2, "b", 1)},
+			base:     []any{jsonchamp.NewFromItems("a", 1, "b", 1)},
+			head:     []any{jsonchamp.NewFromItems("a", 1, "b", 2)},
+			want:     []any{jsonchamp.NewFromItems("a", 2, "b", 2)},
+		},
+		{
+			name:     "records modified on both sides",
+			opts:     []Option{WithIdentityKey("id")},
+			incoming: []any{rec("a", "qty", 2), rec("b", "qty", 1)},
+			base:     []any{rec("a", "qty", 1), rec("b", "qty", 1)},
+			head:     []any{rec("a", "qty", 1), rec("b", "qty", 3)},
+			want:     []any{rec("a", "qty", 2), rec("b", "qty", 3)},
+		},
+		{
+			name:     "record moved and record appended",
+			opts:     []Option{WithIdentityKey("id")},
+			incoming: []any{rec("b"), rec("c"), rec("a")},
+			base:     []any{rec("a"), rec("b"), rec("c")},
+			head:     []any{rec("a"), rec("b"), rec("c"), rec("d")},
+			want:     []any{rec("b"), rec("c"), rec("a"), rec("d")},
+		},
+		{
+			name:     "record deleted and other record modified",
+			opts:     []Option{WithIdentityKey("id")},
+			incoming: []any{rec("b", "qty", 2)},
+			base:     []any{rec("a"), rec("b", "qty", 1)},
+			head:     []any{rec("a"), rec("b", "qty", 1), rec("c")},
+			want:     []any{rec("b", "qty", 2), rec("c")},
+		},
+		{
+			name:          "record deleted and modified",
+			opts:          []Option{WithIdentityKey("id")},
+			incoming:      []any{},
+			base:          []any{rec("a", "qty", 1)},
+			head:          []any{rec("a", "qty", 2)},
+			want:          []any{rec("a", "qty", 2)},
+			wantConflicts: 1,
+		},
+		{
+			name:          "record moved differently on both sides",
+			opts:          []Option{WithIdentityKey("id")},
+			incoming:      []any{rec("b"), rec("c"), rec("a")},
+			base:          []any{rec("a"), rec("b"), rec("c")},
+			head:          []any{rec("b"), rec("a"), rec("c")},
+			want:          []any{rec("b"), rec("a"), rec("c")},
+			wantConflicts: 1,
+		},
+		{
+			name:          "duplicate identities",
+			opts:          []Option{WithIdentityKey("id")},
+			incoming:      []any{rec("a", "qty", 2), rec("a", "qty", 3)},
+			base:          []any{rec("a", "qty", 1)},
+			head:          []any{rec("a", "qty", 1), rec("b")},
+			want:          []any{rec("a", "qty", 1), rec("b")},
+			wantConflicts: 1,
+		},
+		{
+			name:     "identities of different types",
+			opts:     []Option{WithIdentityKey("id")},
+			incoming: []any{jsonchamp.NewFromItems("id", 1, "qty", 2), jsonchamp.NewFromItems("id", "1", "qty", 1)},
+			base:     []any{jsonchamp.NewFromItems("id", 1, "qty", 1), jsonchamp.NewFromItems("id", "1", "qty", 1)},
+			head:     []any{jsonchamp.NewFromItems("id", 1, "qty", 1), jsonchamp.NewFromItems("id", "1", "qty", 3)},
+			want:     []any{jsonchamp.NewFromItems("id", 1, "qty", 2), jsonchamp.NewFromItems("id", "1", "qty", 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Reconcile(feat("l", tt.incoming), feat("l", tt.base), feat("l", tt.head), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Conflicts) != tt.wantConflicts {
+				t.Fatalf("Reconcile() conflicts = %v, want %d", res.Conflicts, tt.wantConflicts)
+			}
+			want := jsonchamp.NewFromItems("l", tt.want)
+			if !res.Feature.Map().Equals(want) {
+				t.Fatalf("Reconcile() = %v, want %v", res.Feature.Map(), want)
+			}
+		})
+	}
+}
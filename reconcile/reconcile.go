@@ -2,17 +2,217 @@ package reconcile
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/mamaar/features/feature"
 	"github.com/mamaar/jsonchamp"
 )
 
-func Reconcile(incoming *feature.Feature, base *feature.Feature, head *feature.Feature) {
+// ConflictKind describes why a reconciliation could not be completed automatically.
+type ConflictKind string
 
-	incomingBase := incoming.Map().Diff(base.Map())
-	headBase := head.Map().Diff(base.Map())
+const (
+	// ConflictValue is reported when incoming and head changed the same path differently.
+	ConflictValue ConflictKind = "value"
+	// ConflictSchema is reported when the merged feature does not validate against its schema.
+	ConflictSchema ConflictKind = "schema"
+)
+
+// Conflict is a change that could not be merged.
+type Conflict struct {
+	Kind ConflictKind
+	// Path is the dot separated path to the conflicting value. It is empty for schema conflicts.
+	Path     string
+	Base     any
+	Incoming any
+	Head     any
+	// Err holds the validation error for schema conflicts.
+	Err error
+}
+
+func (c Conflict) String() string {
+	if c.Kind == ConflictSchema {
+		return fmt.Sprintf("schema: %v", c.Err)
+	}
+	return fmt.Sprintf("%s: incoming %v, head %v", c.Path, c.Incoming, c.Head)
+}
+
+// Result is the outcome of a reconciliation.
+type Result struct {
+	// Feature is the merged feature. Conflicting paths keep the value from head.
+	Feature   *feature.Feature
+	Conflicts []Conflict
+}
+
+// HasConflicts returns true if the reconciliation produced any conflicts.
+func (r Result) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// Reconcile performs a three-way merge of incoming and head against their common base.
+// All three features are first migrated to the newest schema version among them, so that
+// fields introduced by migrations are not reported as changes. The merged feature is
+// validated against that schema, and a violation is reported as a conflict.
+// Lists are merged element by element, see WithIdentityKey.
+func Reconcile(incoming *feature.Feature, base *feature.Feature, head *feature.Feature, opts ...Option) (Result, error) {
+	r := &reconciler{}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	sch := latestSchema(incoming, base, head)
+
+	migrated := make([]*feature.Feature, 0, 3)
+	for _, f := range []*feature.Feature{incoming, base, head} {
+		m, err := migrate(f, sch)
+		if err != nil {
+			return Result{}, err
+		}
+		migrated = append(migrated, m)
+	}
+	incoming, base, head = migrated[0], migrated[1], migrated[2]
+
+	merged := r.mergeMaps(nil, incoming.Map(), base.Map(), head.Map())
+
+	res := Result{
+		Feature:   feature.New(sch, feature.WithMap(merged), feature.WithSchemaVersion(len(sch.Migrations))),
+		Conflicts: r.conflicts,
+	}
+
+	validator, err := sch.ToJSONSchema()
+	if err != nil {
+		return Result{}, err
+	}
+	if err := validator.Validate(res.Feature); err != nil {
+		res.Conflicts = append(res.Conflicts, Conflict{Kind: ConflictSchema, Err: err})
+	}
+
+	return res, nil
+}
+
+// latestSchema returns the schema with the most migrations.
+func latestSchema(features ...*feature.Feature) feature.Schema {
+	sch := features[0].Schema()
+	for _, f := range features[1:] {
+		if len(f.Schema().Migrations) > len(sch.Migrations) {
+			sch = f.Schema()
+		}
+	}
+	return sch
+}
+
+// migrate applies the migrations of sch that have not yet been applied to f.
+func migrate(f *feature.Feature, sch feature.Schema) (*feature.Feature, error) {
+	version := f.SchemaVersion()
+	if version > len(sch.Migrations) {
+		return nil, fmt.Errorf("%w: %d", feature.ErrSchemaVersionNotFound, version)
+	}
+
+	pending := feature.Schema{Schema: sch.Schema, Migrations: sch.Migrations[version:]}
+	m, err := pending.Migrate(f.Map())
+	if err != nil {
+		return nil, err
+	}
+	return feature.New(sch, feature.WithMap(m), feature.WithSchemaVersion(len(sch.Migrations))), nil
+}
+
+// Option configures a reconciliation.
+type Option func(*reconciler)
+
+// WithIdentityKey merges lists of objects by matching elements on the given key,
+// for example "id", instead of by position. Lists where any element lacks the key
+// are merged positionally, and lists where two elements share an identity are reported
+// as a conflict.
+func WithIdentityKey(key string) Option {
+	return func(r *reconciler) {
+		r.identityKey = key
+	}
+}
+
+type reconciler struct {
+	identityKey string
+	conflicts   []Conflict
+}
+
+func (r *reconciler) conflict(path []string, base any, incoming any, head any) {
+	r.conflicts = append(r.conflicts, Conflict{
+		Kind:     ConflictValue,
+		Path:     strings.Join(path, "."),
+		Base:     base,
+		Incoming: incoming,
+		Head:     head,
+	})
+}
+
+func (r *reconciler) mergeMaps(path []string, incoming *jsonchamp.Map, base *jsonchamp.Map, head *jsonchamp.Map) *jsonchamp.Map {
+	merged := jsonchamp.New()
+
+	for _, k := range unionKeys(incoming, base, head) {
+		iv, iok := incoming.Get(k)
+		bv, bok := base.Get(k)
+		hv, hok := head.Get(k)
+
+		v, ok := r.mergeValue(append(slices.Clone(path), k), iv, iok, bv, bok, hv, hok)
+		if ok {
+			merged = merged.Set(k, v)
+		}
+	}
+
+	return merged
+}
+
+// mergeValue merges a single value and returns the merged value and whether it exists.
+// When incoming and head made conflicting changes the head value is kept.
+func (r *reconciler) mergeValue(path []string, iv any, iok bool, bv any, bok bool, hv any, hok bool) (any, bool) {
+	switch {
+	case same(iv, iok, bv, bok):
+		return hv, hok
+	case same(hv, hok, bv, bok):
+		return iv, iok
+	case same(iv, iok, hv, hok):
+		return hv, hok
+	}
+
+	switch i := iv.(type) {
+	case *jsonchamp.Map:
+		if h, ok := hv.(*jsonchamp.Map); ok {
+			b, ok := bv.(*jsonchamp.Map)
+			if !ok {
+				b = jsonchamp.New()
+			}
+			return r.mergeMaps(path, i, b, h), true
+		}
+	case []any:
+		if h, ok := hv.([]any); ok {
+			b, _ := bv.([]any)
+			return r.mergeLists(path, i, b, h), true
+		}
+	}
+
+	r.conflict(path, bv, iv, hv)
+	return hv, hok
+}
+
+func same(a any, aok bool, b any, bok bool) bool {
+	if aok != bok {
+		return false
+	}
+	if !aok {
+		return true
+	}
+	return jsonchamp.NewFromItems("v", a).Equals(jsonchamp.NewFromItems("v", b))
+}
 
-	fmt.Println(jsonchamp.InformationPaths(incomingBase))
-	fmt.Println(jsonchamp.InformationPaths(headBase))
-	fmt.Println("======")
+func unionKeys(maps ...*jsonchamp.Map) []string {
+	var keys []string
+	for _, m := range maps {
+		for _, k := range m.Keys() {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
 }
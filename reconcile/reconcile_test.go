@@ -13,64 +13,138 @@ func feat(kvs ...interface{}) *feature.Feature {
 
 func TestReconcile(t *testing.T) {
 	tests := []struct {
-		name     string
-		incoming *feature.Feature
-		base     *feature.Feature
-		head     *feature.Feature
+		name          string
+		incoming      *feature.Feature
+		base          *feature.Feature
+		head          *feature.Feature
+		want          *jsonchamp.Map
+		wantConflicts int
 	}{
 		{
 			name:     "nothing changed",
 			incoming: feat("a", 1),
 			base:     feat("a", 1),
 			head:     feat("a", 1),
+			want:     jsonchamp.NewFromItems("a", 1),
 		},
 		{
 			name:     "add new field",
 			incoming: feat("a", 1, "b", 2),
 			base:     feat("a", 1),
 			head:     feat("a", 1),
+			want:     jsonchamp.NewFromItems("a", 1, "b", 2),
 		},
 		{
 			name:     "modify existing field",
 			incoming: feat("a", 2),
 			base:     feat("a", 1),
 			head:     feat("a", 1),
+			want:     jsonchamp.NewFromItems("a", 2),
 		},
 		{
 			name:     "delete field",
 			incoming: feat(),
 			base:     feat("a", 1),
 			head:     feat("a", 1),
+			want:     jsonchamp.New(),
 		},
 		{
 			name:     "incoming matches head but not base",
 			incoming: feat("a", 2),
 			base:     feat("a", 1),
 			head:     feat("a", 2),
+			want:     jsonchamp.NewFromItems("a", 2),
 		},
 		{
-			name:     "incoming and head have different changes",
-			incoming: feat("a", 2),
-			base:     feat("a", 1),
-			head:     feat("a", 3),
+			name:          "incoming and head have different changes",
+			incoming:      feat("a", 2),
+			base:          feat("a", 1),
+			head:          feat("a", 3),
+			want:          jsonchamp.NewFromItems("a", 3),
+			wantConflicts: 1,
 		},
 		{
 			name:     "nested object changes",
 			incoming: feat("a", jsonchamp.NewFromItems("b", 2)),
 			base:     feat("a", jsonchamp.NewFromItems("b", 1)),
 			head:     feat("a", jsonchamp.NewFromItems("b", 1)),
+			want:     jsonchamp.NewFromItems("a", jsonchamp.NewFromItems("b", 2)),
 		},
 		{
 			name:     "array changes",
 			incoming: feat("a", []interface{}{1, 2, 3}),
 			base:     feat("a", []interface{}{1, 2}),
 			head:     feat("a", []interface{}{1, 2}),
+			want:     jsonchamp.NewFromItems("a", []interface{}{1, 2, 3}),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			Reconcile(tt.incoming, tt.base, tt.head)
+			res, err := Reconcile(tt.incoming, tt.base, tt.head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Conflicts) != tt.wantConflicts {
+				t.Fatalf("Reconcile() conflicts = %v, want %d", res.Conflicts, tt.wantConflicts)
+			}
+			if !res.Feature.Map().Equals(tt.want) {
+				t.Fatalf("Reconcile() = %v, want %v", res.Feature.Map(), tt.want)
+			}
 		})
 	}
 }
+
+func TestReconcileAcrossSchemaVersions(t *testing.T) {
+	sch := feature.Schema{
+		Migrations: feature.Migrations{
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "a", Type: feature.FieldTypeInteger, Required: true}},
+			}},
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString, Required: true, Default: "open"}},
+			}},
+		},
+	}
+	v1 := feature.Schema{Migrations: sch.Migrations[:1]}
+
+	base := feature.New(v1, feature.WithMap(jsonchamp.NewFromItems("a", 1)), feature.WithSchemaVersion(1))
+	incoming := feature.New(v1, feature.WithMap(jsonchamp.NewFromItems("a", 2)), feature.WithSchemaVersion(1))
+	head := feature.New(sch, feature.WithMap(jsonchamp.NewFromItems("a", 1, "status", "open")), feature.WithSchemaVersion(2))
+
+	res, err := Reconcile(incoming, base, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.HasConflicts() {
+		t.Fatalf("Reconcile() conflicts = %v, want none", res.Conflicts)
+	}
+	want := jsonchamp.NewFromItems("a", 2, "status", "open")
+	if !res.Feature.Map().Equals(want) {
+		t.Fatalf("Reconcile() = %v, want %v", res.Feature.Map(), want)
+	}
+	if res.Feature.SchemaVersion() != 2 {
+		t.Fatalf("SchemaVersion() = %d, want 2", res.Feature.SchemaVersion())
+	}
+}
+
+func TestReconcileSchemaViolation(t *testing.T) {
+	sch := feature.Schema{
+		Migrations: feature.Migrations{
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "a", Type: feature.FieldTypeInteger, Required: true}},
+			}},
+		},
+	}
+	f := func(kvs ...any) *feature.Feature {
+		return feature.New(sch, feature.WithMap(jsonchamp.NewFromItems(kvs...)), feature.WithSchemaVersion(1))
+	}
+
+	res, err := Reconcile(f("a", "two"), f("a", 1), f("a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 1 || res.Conflicts[0].Kind != ConflictSchema {
+		t.Fatalf("Reconcile() conflicts = %v, want one schema conflict", res.Conflicts)
+	}
+}
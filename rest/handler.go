@@ -0,0 +1,643 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+	"github.com/mamaar/features/reconcile"
+	"github.com/mamaar/features/store"
+)
+
+const (
+	// DefaultLimit is the number of features in a page when the request sets no limit.
+	DefaultLimit = 50
+	// MaxLimit is the largest page a request can ask for.
+	MaxLimit = 1000
+	// DefaultMaxBodySize is the largest request body read, in bytes.
+	DefaultMaxBodySize = 1 << 20
+	// DefaultMaxScan is the number of stored features a list request reads at most while
+	// looking for features that match its filter.
+	DefaultMaxScan = 10 * MaxLimit
+)
+
+// Handler serves the features of one schema over HTTP:
+//
+//	GET    /         list features, with optional filter, limit and cursor parameters
+//	POST   /         create a feature
+//	GET    /{key}    read a feature
+//	PUT    /{key}    replace a feature
+//	PATCH  /{key}    patch a feature with a JSON Merge Patch or a JSON Patch
+//	DELETE /{key}    delete a feature
+//
+// Features are exchanged in the JSON envelope of feature.Feature, and their key is built
+// from the payload. Features written by clients are migrated to the latest schema
+// version and validated against it; violations are answered with 422 and a list of the
+// invalid values. Every feature has the revision it was stored at as its ETag, and
+// writes with an If-Match header only succeed if the feature is still at that revision.
+// Otherwise the answer is 409 with the result of reconciling the submitted feature with
+// the stored one, so the client can resolve the conflicts and try again.
+//
+// Mount the handler below a prefix with http.StripPrefix.
+type Handler struct {
+	sch           feature.Schema
+	key           feature.KeyFunc
+	store         Store
+	validator     *feature.Validator
+	reconcileOpts []reconcile.Option
+	maxBodySize   int64
+	maxScan       int
+	cursorSecret  []byte
+	cursors       store.CursorCodec
+	mux           *http.ServeMux
+}
+
+type Option func(*Handler)
+
+// WithReconcileOptions sets the options used to reconcile conflicting writes.
+func WithReconcileOptions(opts ...reconcile.Option) Option {
+	return func(h *Handler) {
+		h.reconcileOpts = opts
+	}
+}
+
+// WithMaxBodySize sets the largest request body read, in bytes.
+func WithMaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithMaxScan sets the number of stored features a list request reads at most. A filter
+// that few features match returns a short or empty page with a cursor once the bound is
+// reached, rather than reading the whole store in one request.
+func WithMaxScan(n int) Option {
+	return func(h *Handler) {
+		h.maxScan = n
+	}
+}
+
+// WithCursorSecret sets the secret used to sign list cursors. Without it, a random secret
+// is used and cursors are only valid for the lifetime of the handler, and only with the
+// handler that issued them.
+func WithCursorSecret(secret []byte) Option {
+	return func(h *Handler) {
+		h.cursorSecret = secret
+	}
+}
+
+// NewHandler creates a handler for features of the schema, stored under the key built
+// by key.
+func NewHandler(sch feature.Schema, key feature.KeyFunc, s Store, opts ...Option) (*Handler, error) {
+	validator, err := sch.ToJSONSchema()
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		sch:         sch,
+		key:         key,
+		store:       s,
+		validator:   validator,
+		maxBodySize: DefaultMaxBodySize,
+		maxScan:     DefaultMaxScan,
+		mux:         http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.maxScan < 1 {
+		return nil, fmt.Errorf("max scan must be positive, got %d", h.maxScan)
+	}
+	h.cursors = store.NewCursorCodec(h.cursorSecret)
+
+	h.mux.HandleFunc("GET /{$}", h.list)
+	h.mux.HandleFunc("POST /{$}", h.create)
+	h.mux.HandleFunc("GET /{key...}", h.get)
+	h.mux.HandleFunc("PUT /{key...}", h.replace)
+	h.mux.HandleFunc("PATCH /{key...}", h.patch)
+	h.mux.HandleFunc("DELETE /{key...}", h.delete)
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	f, rev, err := h.store.Get(r.Context(), r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if tags := r.Header.Get("If-None-Match"); tags == "*" || tags == etag(rev) {
+		w.Header().Set("ETag", etag(rev))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeFeature(w, http.StatusOK, f, rev)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	f, err := h.decode(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	key, err := h.check(f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.write(w, r, key, f, NoRevision)
+}
+
+func (h *Handler) replace(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	f, err := h.decode(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.checkKey(f, key); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var revision int
+	switch pre, err := ifMatch(r); {
+	case err != nil:
+		writeError(w, err)
+		return
+	case pre != nil && *pre >= 0:
+		revision = *pre
+	case r.Header.Get("If-None-Match") == "*":
+		revision = NoRevision
+	default:
+		// Without a revision, replace whatever is stored; If-Match: * requires that
+		// something is.
+		_, revision, err = h.store.Get(r.Context(), key)
+		if errors.Is(err, store.ErrNotFound) && pre == nil {
+			revision, err = NoRevision, nil
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	h.write(w, r, key, f, revision)
+}
+
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := r.PathValue("key")
+	current, revision, err := h.store.Get(ctx, key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pre, err := ifMatch(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// A patch made against an older revision is applied to that revision, and the
+	// result reconciled with the current feature.
+	base := current
+	if pre != nil && *pre >= 0 && *pre != revision {
+		base, err = h.store.GetRevision(ctx, key, *pre)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, errorResponse{Error: fmt.Sprintf("revision %d of %s can not be read: %v", *pre, key, err)})
+			return
+		}
+		revision = *pre
+	}
+
+	f := feature.New(base.Schema(), feature.WithMap(base.Map()), feature.WithSchemaVersion(base.SchemaVersion()))
+	if err := h.migrate(f); err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := h.readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := applyPatch(f, r.Header.Get("Content-Type"), body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.checkKey(f, key); err != nil {
+		writeError(w, err)
+		return
+	}
+	h.write(w, r, key, f, revision)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := r.PathValue("key")
+	pre, err := ifMatch(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var revision int
+	if pre != nil && *pre >= 0 {
+		revision = *pre
+	} else if _, revision, err = h.store.Get(ctx, key); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = h.store.Delete(ctx, key, revision)
+	if errors.Is(err, store.ErrConflict) {
+		resp := errorResponse{Error: fmt.Sprintf("%s has changed since revision %d", key, revision)}
+		if _, current, err := h.store.Get(ctx, key); err == nil {
+			w.Header().Set("ETag", etag(current))
+		}
+		writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listItem is a feature in a page of a list response.
+type listItem struct {
+	Key      string           `json:"key"`
+	Revision int              `json:"revision"`
+	Feature  *feature.Feature `json:"feature"`
+}
+
+type listResponse struct {
+	Items []listItem `json:"items"`
+	// Cursor continues the list after the last item. It is empty on the last page. A
+	// page can hold fewer items than the limit, or none, and still have a cursor, when
+	// the request has read as many stored features as it may.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var filter query.Expr
+	if s := params.Get("filter"); s != "" {
+		var err error
+		if filter, err = query.Compile(h.sch, s); err != nil {
+			writeError(w, badRequest("filter: %v", err))
+			return
+		}
+	}
+	limit := DefaultLimit
+	if s := params.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxLimit {
+			writeError(w, badRequest("limit must be a number from 1 to %d", MaxLimit))
+			return
+		}
+		limit = n
+	}
+	fingerprint := "list"
+	if filter != nil {
+		fingerprint += " filter " + filter.String()
+	}
+	var after string
+	if s := params.Get("cursor"); s != "" {
+		position, err := h.cursors.Decode(fingerprint, s)
+		if err != nil {
+			writeError(w, badRequest("%v", err))
+			return
+		}
+		after = position["key"]
+	}
+
+	// Fetch pages of the store until the filter has let enough features through, or the
+	// request has read as many features as it may.
+	resp := listResponse{Items: []listItem{}}
+	scanned, more := 0, true
+	for more && len(resp.Items) < limit && scanned < h.maxScan {
+		batch := min(limit, h.maxScan-scanned)
+		entries, err := h.store.List(r.Context(), after, batch)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		more = len(entries) == batch
+		for i, e := range entries {
+			after = e.Key
+			scanned++
+			if filter == nil || filter.Eval(e.Feature) {
+				resp.Items = append(resp.Items, listItem{Key: e.Key, Revision: e.Revision, Feature: e.Feature})
+			}
+			if len(resp.Items) == limit {
+				more = more || i < len(entries)-1
+				break
+			}
+		}
+	}
+	if more {
+		cursor, err := h.cursors.Encode(fingerprint, map[string]string{"key": after})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resp.Cursor = cursor
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// write stores the feature if the key is at the revision, and reconciles it with the
+// stored feature otherwise.
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, key string, f *feature.Feature, revision int) {
+	rev, err := h.store.Put(r.Context(), key, f, revision)
+	if errors.Is(err, store.ErrConflict) {
+		h.conflict(r.Context(), w, key, f, revision)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if revision == NoRevision {
+		status = http.StatusCreated
+		w.Header().Set("Location", url.PathEscape(key))
+	}
+	writeFeature(w, status, f, rev)
+}
+
+// conflictResponse answers a write made against a revision that is no longer current.
+type conflictResponse struct {
+	Error string `json:"error"`
+	// Revision is the current revision of the feature, to write the merged feature at.
+	Revision int `json:"revision"`
+	// Merged is the submitted feature reconciled with the current one, keeping the
+	// current values where they conflict.
+	Merged    *feature.Feature `json:"merged"`
+	Conflicts []conflict       `json:"conflicts"`
+}
+
+type conflict struct {
+	Kind     reconcile.ConflictKind `json:"kind"`
+	Path     string                 `json:"path,omitempty"`
+	Base     any                    `json:"base,omitempty"`
+	Incoming any                    `json:"incoming,omitempty"`
+	Head     any                    `json:"head,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+func (h *Handler) conflict(ctx context.Context, w http.ResponseWriter, key string, incoming *feature.Feature, revision int) {
+	head, headRevision, err := h.store.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: fmt.Sprintf("%s has been deleted", key)})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// A feature created concurrently is merged as if both were created from nothing.
+	base := feature.New(h.sch, feature.WithSchemaVersion(len(h.sch.Migrations)))
+	if revision != NoRevision {
+		if base, err = h.store.GetRevision(ctx, key, revision); err != nil {
+			w.Header().Set("ETag", etag(headRevision))
+			writeJSON(w, http.StatusConflict, errorResponse{Error: fmt.Sprintf("revision %d of %s can not be read: %v", revision, key, err)})
+			return
+		}
+	}
+	res, err := reconcile.Reconcile(incoming, base, head, h.reconcileOpts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := conflictResponse{
+		Error:     fmt.Sprintf("%s has changed since revision %d", key, revision),
+		Revision:  headRevision,
+		Merged:    res.Feature,
+		Conflicts: []conflict{},
+	}
+	if revision == NoRevision {
+		resp.Error = fmt.Sprintf("%s already exists", key)
+	}
+	for _, c := range res.Conflicts {
+		out := conflict{Kind: c.Kind, Path: c.Path, Base: c.Base, Incoming: c.Incoming, Head: c.Head}
+		if c.Err != nil {
+			out.Error = c.Err.Error()
+		}
+		resp.Conflicts = append(resp.Conflicts, out)
+	}
+	w.Header().Set("ETag", etag(headRevision))
+	writeJSON(w, http.StatusConflict, resp)
+}
+
+// decode reads a feature envelope from the request body. The schema version defaults to
+// the latest, and older versions are migrated.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request) (*feature.Feature, error) {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return nil, &httpError{status: http.StatusUnsupportedMediaType, msg: "body must be application/json"}
+	}
+	body, err := h.readBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		SchemaURN     string `json:"schema_urn"`
+		SchemaVersion *int   `json:"schema_version"`
+	}
+	var decoded feature.Feature
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, badRequest("body must be a feature: %v", err)
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, badRequest("body must be a feature: %v", err)
+	}
+	if urn := envelope.SchemaURN; urn != "" && urn != h.sch.Schema && !strings.HasPrefix(urn, h.sch.Schema+"/") {
+		return nil, badRequest("feature has schema %q, want %q", urn, h.sch.Schema)
+	}
+	version := len(h.sch.Migrations)
+	if envelope.SchemaVersion != nil {
+		version = *envelope.SchemaVersion
+	}
+	if version < 0 || version > len(h.sch.Migrations) {
+		return nil, badRequest("schema version %d does not exist", version)
+	}
+
+	f := feature.New(h.sch, feature.WithMap(decoded.Map()), feature.WithSchemaVersion(version))
+	if err := h.migrate(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, &httpError{status: http.StatusRequestEntityTooLarge, msg: fmt.Sprintf("body is larger than %d bytes", tooLarge.Limit)}
+	}
+	return body, err
+}
+
+// migrate brings a feature to the latest version of the handler's schema.
+func (h *Handler) migrate(f *feature.Feature) error {
+	if f.SchemaVersion() >= len(h.sch.Migrations) {
+		return nil
+	}
+	migrated := feature.New(h.sch, feature.WithMap(f.Map()), feature.WithSchemaVersion(f.SchemaVersion()))
+	if err := migrated.Migrate(h.sch); err != nil {
+		return &httpError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf("feature can not be migrated: %v", err)}
+	}
+	*f = *feature.New(h.sch, feature.WithMap(migrated.Map()), feature.WithSchemaVersion(migrated.SchemaVersion()))
+	return nil
+}
+
+// check validates a feature and returns its key.
+func (h *Handler) check(f *feature.Feature) (string, error) {
+	err := h.validator.Validate(f)
+	var verr *feature.ValidationError
+	if errors.As(err, &verr) {
+		resp := &httpError{status: http.StatusUnprocessableEntity, msg: "feature is invalid"}
+		for _, fe := range verr.Errors {
+			resp.fields = append(resp.fields, fieldError{Path: fe.Path, Message: fe.Message})
+		}
+		return "", resp
+	}
+	if err != nil {
+		return "", &httpError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	}
+	key, err := h.key(f)
+	if err != nil {
+		return "", &httpError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf("key can not be built: %v", err)}
+	}
+	return string(key), nil
+}
+
+// checkKey validates a feature written to a key, which must be the key of the feature.
+func (h *Handler) checkKey(f *feature.Feature, key string) error {
+	got, err := h.check(f)
+	if err != nil {
+		return err
+	}
+	if got != key {
+		return &httpError{status: http.StatusUnprocessableEntity, msg: fmt.Sprintf("feature has key %q, not %q", got, key)}
+	}
+	return nil
+}
+
+// applyPatch applies a JSON Patch or a JSON Merge Patch, depending on the content type.
+func applyPatch(f *feature.Feature, contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &httpError{status: http.StatusUnsupportedMediaType, msg: "patch must be application/merge-patch+json or application/json-patch+json"}
+	}
+	switch mediaType {
+	case "application/merge-patch+json", "application/json":
+		err = f.ApplyMergePatch(body)
+	case "application/json-patch+json":
+		var p feature.Patch
+		if err := json.Unmarshal(body, &p); err != nil {
+			return badRequest("malformed patch: %v", err)
+		}
+		err = f.ApplyPatch(p)
+	default:
+		return &httpError{status: http.StatusUnsupportedMediaType, msg: "patch must be application/merge-patch+json or application/json-patch+json"}
+	}
+	if errors.Is(err, feature.ErrInvalidPatch) {
+		return badRequest("%v", err)
+	}
+	if err != nil {
+		return &httpError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	}
+	return nil
+}
+
+func etag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// ifMatch returns the revision required by the If-Match header, -1 for "*", or nil if
+// the header is absent.
+func ifMatch(r *http.Request) (*int, error) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" {
+		return nil, nil
+	}
+	revision := -1
+	if tag != "*" {
+		unquoted, err := strconv.Unquote(tag)
+		if err == nil {
+			revision, err = strconv.Atoi(unquoted)
+		}
+		if err != nil || revision < 1 {
+			return nil, badRequest("If-Match must be a single entity tag of this resource")
+		}
+	}
+	return &revision, nil
+}
+
+// httpError is an error answered with its status and message.
+type httpError struct {
+	status int
+	msg    string
+	fields []fieldError
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...any) error {
+	return &httpError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// Errors lists the invalid values of a feature that failed validation.
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+type fieldError struct {
+	// Path is the location of the value, starting with the field name.
+	Path    []string `json:"path"`
+	Message string   `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var herr *httpError
+	switch {
+	case errors.As(err, &herr):
+		writeJSON(w, herr.status, errorResponse{Error: herr.msg, Errors: herr.fields})
+	case errors.Is(err, store.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	}
+}
+
+func writeFeature(w http.ResponseWriter, status int, f *feature.Feature, revision int) {
+	w.Header().Set("ETag", etag(revision))
+	writeJSON(w, status, f)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,313 @@
+package rest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/store"
+)
+
+var orderSchema = feature.Schema{
+	Schema: "urn:features:order",
+	Migrations: feature.Migrations{
+		{Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "order_id", Type: feature.FieldTypeString, Required: true}},
+			feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString, Enum: []any{"open", "shipped"}}},
+		}},
+		{Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "total", Type: feature.FieldTypeNumber, Required: true, Default: 0.0}},
+		}},
+	},
+}
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	key, err := feature.CompileKeyTemplate(orderSchema, "ORDER-{order_id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(orderSchema, key, NewHistoryStore(store.NewHistory()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func do(t *testing.T, h http.Handler, method, target, contentType, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	return v
+}
+
+type envelope struct {
+	SchemaURN     string         `json:"schema_urn"`
+	SchemaVersion int            `json:"schema_version"`
+	Payload       map[string]any `json:"payload"`
+}
+
+func TestHandler_CRUD(t *testing.T) {
+	h := newTestHandler(t)
+
+	// Features of an older schema version are migrated.
+	rec := do(t, h, http.MethodPost, "/", "application/json", `{"schema_version": 1, "payload": {"order_id": "A1", "status": "open"}}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("ETag") != `"1"` || rec.Header().Get("Location") != "ORDER-A1" {
+		t.Fatalf("create: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+	created := decodeBody[envelope](t, rec)
+	if created.SchemaVersion != 2 || created.Payload["total"] != 0.0 || created.SchemaURN != "urn:features:order" {
+		t.Fatalf("create: expected a migrated feature, got %+v", created)
+	}
+
+	rec = do(t, h, http.MethodGet, "/ORDER-A1", "", "")
+	if rec.Code != http.StatusOK || decodeBody[envelope](t, rec).Payload["status"] != "open" {
+		t.Fatalf("get: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, http.MethodGet, "/ORDER-A1", "", "", "If-None-Match", `"1"`); rec.Code != http.StatusNotModified {
+		t.Fatalf("get: expected 304 for the current ETag, got %d", rec.Code)
+	}
+
+	rec = do(t, h, http.MethodPut, "/ORDER-A1", "application/json", `{"payload": {"order_id": "A1", "status": "shipped", "total": 12.5}}`, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("replace: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(t, h, http.MethodPatch, "/ORDER-A1", "application/merge-patch+json", `{"total": 20}`)
+	if rec.Code != http.StatusOK || decodeBody[envelope](t, rec).Payload["total"] != 20.0 {
+		t.Fatalf("patch: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(t, h, http.MethodPatch, "/ORDER-A1", "application/json-patch+json", `[{"op": "remove", "path": "/status"}]`, "If-Match", `"3"`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"4"` {
+		t.Fatalf("json patch: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(t, h, http.MethodDelete, "/ORDER-A1", "", "", "If-Match", `"3"`); rec.Code != http.StatusConflict {
+		t.Fatalf("delete: expected 409 for a stale ETag, got %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodDelete, "/ORDER-A1", "", "", "If-Match", `"4"`); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, http.MethodGet, "/ORDER-A1", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get: expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ValidationErrors(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := do(t, h, http.MethodPost, "/", "application/json", `{"payload": {"status": "lost", "total": 1}}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d %s", rec.Code, rec.Body)
+	}
+	resp := decodeBody[errorResponse](t, rec)
+	paths := make(map[string]string)
+	for _, fe := range resp.Errors {
+		paths[strings.Join(fe.Path, ".")] = fe.Message
+	}
+	if paths["order_id"] != "is required" || paths["status"] != "must be one of open, shipped" {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+
+	do(t, h, http.MethodPost, "/", "application/json", `{"payload": {"order_id": "A1", "total": 1}}`)
+	if rec := do(t, h, http.MethodPut, "/ORDER-A1", "application/json", `{"payload": {"order_id": "B2", "total": 1}}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 when the key does not match, got %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodPatch, "/ORDER-A1", "application/merge-patch+json", `{"total": "many"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an invalid patch result, got %d", rec.Code)
+	}
+	if rec := do(t, h, http.MethodPost, "/", "text/plain", `hello`); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rec.Code)
+	}
+}
+
+type conflictBody struct {
+	Revision  int        `json:"revision"`
+	Merged    envelope   `json:"merged"`
+	Conflicts []conflict `json:"conflicts"`
+}
+
+func TestHandler_Conflicts(t *testing.T) {
+	h := newTestHandler(t)
+	do(t, h, http.MethodPost, "/", "application/json", `{"payload": {"order_id": "A1", "status": "open", "total": 1}}`)
+	do(t, h, http.MethodPatch, "/ORDER-A1", "application/merge-patch+json", `{"status": "shipped"}`, "If-Match", `"1"`)
+
+	// A write based on revision 1 is reconciled with revision 2.
+	rec := do(t, h, http.MethodPatch, "/ORDER-A1", "application/merge-patch+json", `{"total": 5}`, "If-Match", `"1"`)
+	if rec.Code != http.StatusConflict || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 409 at revision 2, got %d %s", rec.Code, rec.Body)
+	}
+	resp := decodeBody[conflictBody](t, rec)
+	if resp.Revision != 2 || len(resp.Conflicts) != 0 || resp.Merged.Payload["status"] != "shipped" || resp.Merged.Payload["total"] != 5.0 {
+		t.Fatalf("expected a clean merge of both changes, got %+v", resp)
+	}
+
+	do(t, h, http.MethodPatch, "/ORDER-A1", "application/merge-patch+json", `{"total": 7}`, "If-Match", `"2"`)
+	rec = do(t, h, http.MethodPut, "/ORDER-A1", "application/json", `{"payload": {"order_id": "A1", "status": "open", "total": 9}}`, "If-Match", `"1"`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+	resp = decodeBody[conflictBody](t, rec)
+	// The total was changed on both sides; the status only on the server.
+	if len(resp.Conflicts) != 1 || resp.Conflicts[0].Path != "total" || resp.Conflicts[0].Head != 7.0 || resp.Conflicts[0].Incoming != 9.0 {
+		t.Fatalf("expected a conflict on total, got %+v", resp.Conflicts)
+	}
+	if resp.Revision != 3 || resp.Merged.Payload["status"] != "shipped" {
+		t.Fatalf("expected the merge to keep the shipped status at revision 3, got %+v", resp)
+	}
+
+	if rec := do(t, h, http.MethodPost, "/", "application/json", `{"payload": {"order_id": "A1", "total": 1}}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when creating an existing feature, got %d", rec.Code)
+	}
+}
+
+func TestHandler_List(t *testing.T) {
+	h := newTestHandler(t)
+	for _, body := range []string{
+		`{"payload": {"order_id": "A1", "status": "open", "total": 10}}`,
+		`{"payload": {"order_id": "A2", "status": "shipped", "total": 20}}`,
+		`{"payload": {"order_id": "A3", "status": "open", "total": 30}}`,
+		`{"payload": {"order_id": "A4", "status": "open", "total": 40}}`,
+	} {
+		if rec := do(t, h, http.MethodPost, "/", "application/json", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	type page struct {
+		Items []struct {
+			Key      string   `json:"key"`
+			Revision int      `json:"revision"`
+			Feature  envelope `json:"feature"`
+		} `json:"items"`
+		Cursor string `json:"cursor"`
+	}
+	var keys []string
+	target := "/?limit=2&filter=" + strings.ReplaceAll(`status = "open"`, " ", "+")
+	for target != "" {
+		rec := do(t, h, http.MethodGet, target, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", rec.Code, rec.Body)
+		}
+		p := decodeBody[page](t, rec)
+		for _, item := range p.Items {
+			keys = append(keys, item.Key)
+		}
+		target = ""
+		if p.Cursor != "" {
+			target = "/?limit=2&cursor=" + p.Cursor + "&filter=" + strings.ReplaceAll(`status = "open"`, " ", "+")
+		}
+	}
+	if strings.Join(keys, ",") != "ORDER-A1,ORDER-A3,ORDER-A4" {
+		t.Fatalf("expected open orders, got %v", keys)
+	}
+
+	if rec := do(t, h, http.MethodGet, "/?filter=missing+%3D+1", "", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a filter on an unknown field, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ListBoundedScan(t *testing.T) {
+	key, err := feature.CompileKeyTemplate(orderSchema, "ORDER-{order_id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(orderSchema, key, NewHistoryStore(store.NewHistory()), WithMaxScan(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 7; i++ {
+		status := "shipped"
+		if i == 7 {
+			status = "open"
+		}
+		body := fmt.Sprintf(`{"payload": {"order_id": "A%d", "status": %q, "total": 10}}`, i, status)
+		if rec := do(t, h, http.MethodPost, "/", "application/json", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	type page struct {
+		Items []struct {
+			Key string `json:"key"`
+		} `json:"items"`
+		Cursor string `json:"cursor"`
+	}
+	filter := "&filter=" + url.QueryEscape(`status = "open"`)
+	var sizes []int
+	var keys []string
+	target := "/?limit=2" + filter
+	for target != "" {
+		rec := do(t, h, http.MethodGet, target, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list: %d %s", rec.Code, rec.Body)
+		}
+		p := decodeBody[page](t, rec)
+		sizes = append(sizes, len(p.Items))
+		for _, item := range p.Items {
+			keys = append(keys, item.Key)
+		}
+		target = ""
+		if p.Cursor != "" {
+			target = "/?limit=2&cursor=" + p.Cursor + filter
+		}
+	}
+	// Every request reads at most three orders, so the only open one is on the third page.
+	if fmt.Sprint(sizes) != "[0 0 1]" || strings.Join(keys, ",") != "ORDER-A7" {
+		t.Fatalf("expected pages of sizes [0 0 1] with ORDER-A7, got %v with %v", sizes, keys)
+	}
+}
+
+func TestHandler_ListInvalidCursor(t *testing.T) {
+	h := newTestHandler(t)
+	for _, id := range []string{"A1", "A2", "A3"} {
+		body := `{"payload": {"order_id": "` + id + `", "status": "open", "total": 10}}`
+		if rec := do(t, h, http.MethodPost, "/", "application/json", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", rec.Code, rec.Body)
+		}
+	}
+	rec := do(t, h, http.MethodGet, "/?limit=1", "", "")
+	cursor := decodeBody[struct {
+		Cursor string `json:"cursor"`
+	}](t, rec).Cursor
+	if cursor == "" {
+		t.Fatal("expected a cursor on the first page")
+	}
+
+	for name, target := range map[string]string{
+		"raw key":       "/?cursor=" + base64.RawURLEncoding.EncodeToString([]byte("ORDER-A1")),
+		"other filter":  "/?cursor=" + cursor + "&filter=" + url.QueryEscape(`status = "open"`),
+		"other handler": "/?cursor=" + cursor,
+	} {
+		h := h
+		if name == "other handler" {
+			h = newTestHandler(t)
+		}
+		if rec := do(t, h, http.MethodGet, target, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %s", name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/store"
+)
+
+// NoRevision is the revision of a key that holds no feature, used to create features.
+const NoRevision = 0
+
+// Entry is a stored feature with its key and revision.
+type Entry struct {
+	Key      string
+	Revision int
+	Feature  *feature.Feature
+}
+
+// Store is the storage served by a Handler. Every write of a key gets a new revision
+// number, which the handler uses as the entity tag of the feature. Missing features are
+// reported with store.ErrNotFound, and failed conditions with store.ErrConflict.
+//
+// HistoryStore is the only implementation in this module. store.Memory and store.SQL keep
+// no revisions, so writes could not be made conditional on them. store.Table versions its
+// items, but it addresses them by partition and sort key, keeps no earlier versions to
+// reconcile against, and can only list the items of one partition, not every key in
+// order.
+type Store interface {
+	// Get returns the feature of a key and its revision.
+	Get(ctx context.Context, key string) (*feature.Feature, int, error)
+	// GetRevision returns the feature as it was written in a revision.
+	GetRevision(ctx context.Context, key string, revision int) (*feature.Feature, error)
+	// Put writes the feature if the key is at the revision, and returns the new revision.
+	Put(ctx context.Context, key string, f *feature.Feature, revision int) (int, error)
+	// Delete removes the feature if the key is at the revision.
+	Delete(ctx context.Context, key string, revision int) error
+	// List returns up to limit features with keys sorted after the given key.
+	List(ctx context.Context, after string, limit int) ([]Entry, error)
+}
+
+// HistoryStore serves the features of a store.History, whose revision numbers become
+// entity tags. Writes are recorded without an author.
+type HistoryStore struct {
+	h *store.History
+}
+
+// NewHistoryStore creates a Store backed by the history.
+func NewHistoryStore(h *store.History) *HistoryStore {
+	return &HistoryStore{h: h}
+}
+
+var _ Store = (*HistoryStore)(nil)
+
+// Get implements Store.
+func (s *HistoryStore) Get(_ context.Context, key string) (*feature.Feature, int, error) {
+	revs, err := s.h.Revisions(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	latest := revs[len(revs)-1]
+	if latest.Deleted {
+		return nil, 0, fmt.Errorf("%w: %s", store.ErrNotFound, key)
+	}
+	f, err := s.h.GetRevision(key, latest.Number)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, latest.Number, nil
+}
+
+// GetRevision implements Store.
+func (s *HistoryStore) GetRevision(_ context.Context, key string, revision int) (*feature.Feature, error) {
+	return s.h.GetRevision(key, revision)
+}
+
+// Put implements Store.
+func (s *HistoryStore) Put(_ context.Context, key string, f *feature.Feature, revision int) (int, error) {
+	rev, err := s.h.PutIfRevision(key, f, "", revision)
+	if err != nil {
+		return 0, err
+	}
+	return rev.Number, nil
+}
+
+// Delete implements Store.
+func (s *HistoryStore) Delete(_ context.Context, key string, revision int) error {
+	_, err := s.h.DeleteIfRevision(key, "", revision)
+	return err
+}
+
+// List implements Store.
+func (s *HistoryStore) List(ctx context.Context, after string, limit int) ([]Entry, error) {
+	var entries []Entry
+	for len(entries) < limit {
+		keys := s.h.KeysAfter(after, limit-len(entries))
+		if len(keys) == 0 {
+			break
+		}
+		for _, key := range keys {
+			after = key
+			f, rev, err := s.Get(ctx, key)
+			if errors.Is(err, store.ErrNotFound) {
+				// Deleted since the keys were listed.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, Entry{Key: key, Revision: rev, Feature: f})
+		}
+	}
+	return entries, nil
+}
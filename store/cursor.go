@@ -0,0 +1,153 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// IndexQuery selects features whose key in a secondary index starts with a prefix,
+// ordered by that key.
+type IndexQuery struct {
+	Index  string
+	Prefix feature.Key
+	// Limit is the maximum number of features in a page, or zero for no limit.
+	Limit int
+	// Cursor continues the query after the last feature of a previous page.
+	Cursor string
+}
+
+func (q IndexQuery) fingerprint() string {
+	return fmt.Sprintf("index %s prefix %s", q.Index, q.Prefix)
+}
+
+// Order sorts by a named component of an index key.
+type Order struct {
+	Component  string
+	Descending bool
+}
+
+// ListQuery selects features matching a filter, ordered by components of an index key.
+type ListQuery struct {
+	// Index is the index whose key components OrderBy refers to. Without an index,
+	// features are ordered by their key in the store.
+	Index   string
+	OrderBy []Order
+	// Filter selects the features to list. All features are listed if it is nil.
+	Filter query.Expr
+	// Limit is the maximum number of features in a page, or zero for no limit.
+	Limit int
+	// Cursor continues the query after the last feature of a previous page.
+	Cursor string
+}
+
+func (q ListQuery) validate(tmpl *feature.KeyTemplate) error {
+	names := make(map[string]bool)
+	if tmpl != nil {
+		for _, part := range tmpl.Parts() {
+			names[part.Name()] = part.Name() != ""
+		}
+	}
+	for _, o := range q.OrderBy {
+		if !names[o.Component] {
+			return fmt.Errorf("%w: index '%s' has no key component '%s'", feature.ErrInvalidIndex, q.Index, o.Component)
+		}
+	}
+	return nil
+}
+
+func (q ListQuery) fingerprint() string {
+	filter := ""
+	if q.Filter != nil {
+		filter = q.Filter.String()
+	}
+	return fmt.Sprintf("index %s order %v filter %s", q.Index, q.OrderBy, filter)
+}
+
+// Page is one page of query results.
+type Page struct {
+	Features []*feature.Feature
+	// Cursor continues the query after the last feature of the page. It is empty when
+	// there are no more features.
+	Cursor string
+}
+
+// CursorCodec turns the position of the last feature of a page into an opaque token.
+// Tokens are signed with HMAC-SHA256 so clients can not forge positions, and they
+// record the query they belong to so they can not be replayed against another query.
+// A position is a key, not an offset, so a cursor stays valid while features are
+// written: the next page starts after the position, wherever it now falls.
+type CursorCodec struct {
+	secret []byte
+}
+
+// NewCursorCodec creates a codec with the secret, or with a random secret if it is nil,
+// in which case cursors are only valid for the lifetime of the codec.
+func NewCursorCodec(secret []byte) CursorCodec {
+	if secret == nil {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return CursorCodec{secret: secret}
+}
+
+// Encode returns the cursor for a position in the results of a query, which is any string
+// identifying the query, such as its filter.
+func (c CursorCodec) Encode(query string, position map[string]string) (string, error) {
+	data, err := json.Marshal(cursorPayload{Query: query, Position: position})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(c.sign(data)), nil
+}
+
+// Decode returns the position of a cursor, or ErrInvalidCursor if the cursor was not
+// encoded by a codec with the same secret for the same query.
+func (c CursorCodec) Decode(query string, cursor string) (map[string]string, error) {
+	encodedData, encodedMAC, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encodedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if !hmac.Equal(mac, c.sign(data)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidCursor)
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if payload.Query != query {
+		return nil, fmt.Errorf("%w: belongs to another query", ErrInvalidCursor)
+	}
+	return payload.Position, nil
+}
+
+func (c CursorCodec) sign(data []byte) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+type cursorPayload struct {
+	Query    string            `json:"q"`
+	Position map[string]string `json:"p"`
+}
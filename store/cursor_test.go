@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+	position := map[string]string{"key": "CUST:42", "id": "A1"}
+
+	cursor, err := codec.Encode("q1", position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.Decode("q1", cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "CUST:42" || got["id"] != "A1" {
+		t.Fatalf("Decode() = %v, want %v", got, position)
+	}
+
+	data, mac, _ := strings.Cut(cursor, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(data)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), "A1", "Z9", 1))) + "." + mac
+
+	tests := []struct {
+		name   string
+		codec  CursorCodec
+		query  string
+		cursor string
+	}{
+		{name: "forged position", codec: codec, query: "q1", cursor: forged},
+		{name: "other query", codec: codec, query: "q2", cursor: cursor},
+		{name: "other secret", codec: NewCursorCodec([]byte("other")), query: "q1", cursor: cursor},
+		{name: "random secret", codec: NewCursorCodec(nil), query: "q1", cursor: cursor},
+		{name: "malformed", codec: codec, query: "q1", cursor: "garbage"},
+		{name: "bad encoding", codec: codec, query: "q1", cursor: "!!.!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.Decode(tt.query, tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("Decode() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
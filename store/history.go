@@ -0,0 +1,274 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrNotFound         = errors.New("feature not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// Revision describes a single stored version of a feature.
+type Revision struct {
+	// Number is the revision number, starting at 1 for the first write of a key.
+	Number        int
+	Time          time.Time
+	Author        string
+	SchemaVersion int
+	// Deleted is true if the revision records the deletion of the feature.
+	Deleted bool
+
+	schema feature.Schema
+	m      *jsonchamp.Map
+}
+
+// History is a feature store that keeps every revision of every feature.
+// Because features are backed by persistent maps, keeping old revisions only costs the
+// parts of the map that changed between them.
+type History struct {
+	mu        sync.RWMutex
+	now       func() time.Time
+	revisions map[string][]Revision
+	// keys holds the keys that hold a feature, sorted.
+	keys []string
+}
+
+type HistoryOption func(*History)
+
+// WithNow sets the function used to timestamp revisions.
+func WithNow(now func() time.Time) HistoryOption {
+	return func(h *History) {
+		h.now = now
+	}
+}
+
+// NewHistory creates an empty history store.
+func NewHistory(opts ...HistoryOption) *History {
+	h := &History{
+		now:       time.Now,
+		revisions: make(map[string][]Revision),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+var _ feature.Store = (*History)(nil)
+
+// Get returns the latest revision of a feature.
+func (h *History) Get(key string) (*feature.Feature, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revs := h.revisions[key]
+	if len(revs) == 0 || revs[len(revs)-1].Deleted {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return revs[len(revs)-1].feature(), nil
+}
+
+// Put stores f as a new revision of key and marks f as clean.
+func (h *History) Put(key string, f *feature.Feature, author string) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rev := h.append(key, Revision{
+		Author:        author,
+		SchemaVersion: f.SchemaVersion(),
+		schema:        f.Schema(),
+		m:             f.Map(),
+	})
+	f.MarkClean()
+	return rev, nil
+}
+
+// PutIfRevision stores f like Put, but only if the latest revision of key has the given
+// number, and fails with ErrConflict otherwise. Zero means that key must not hold a
+// feature: it was never written, or it was deleted.
+func (h *History) PutIfRevision(key string, f *feature.Feature, author string, revision int) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.checkRevision(key, revision); err != nil {
+		return Revision{}, err
+	}
+	rev := h.append(key, Revision{
+		Author:        author,
+		SchemaVersion: f.SchemaVersion(),
+		schema:        f.Schema(),
+		m:             f.Map(),
+	})
+	f.MarkClean()
+	return rev, nil
+}
+
+// Delete records the deletion of key as a new revision. Earlier revisions are kept.
+func (h *History) Delete(key string, author string) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.delete(key, author)
+}
+
+// DeleteIfRevision deletes key like Delete, but only if its latest revision has the
+// given number, and fails with ErrConflict otherwise.
+func (h *History) DeleteIfRevision(key string, author string, revision int) (Revision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.checkRevision(key, revision); err != nil {
+		return Revision{}, err
+	}
+	return h.delete(key, author)
+}
+
+func (h *History) checkRevision(key string, revision int) error {
+	revs := h.revisions[key]
+	latest := 0
+	if len(revs) > 0 && !revs[len(revs)-1].Deleted {
+		latest = revs[len(revs)-1].Number
+	}
+	if latest != revision {
+		return fmt.Errorf("%w: %s is at revision %d, not %d", ErrConflict, key, latest, revision)
+	}
+	return nil
+}
+
+func (h *History) delete(key string, author string) (Revision, error) {
+	revs := h.revisions[key]
+	if len(revs) == 0 || revs[len(revs)-1].Deleted {
+		return Revision{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	last := revs[len(revs)-1]
+	return h.append(key, Revision{
+		Author:        author,
+		SchemaVersion: last.SchemaVersion,
+		Deleted:       true,
+		schema:        last.schema,
+		m:             jsonchamp.New(),
+	}), nil
+}
+
+func (h *History) append(key string, rev Revision) Revision {
+	revs := h.revisions[key]
+	rev.Number = len(revs) + 1
+	rev.Time = h.now()
+	h.revisions[key] = append(revs, rev)
+
+	i, found := slices.BinarySearch(h.keys, key)
+	switch {
+	case rev.Deleted && found:
+		h.keys = slices.Delete(h.keys, i, i+1)
+	case !rev.Deleted && !found:
+		h.keys = slices.Insert(h.keys, i, key)
+	}
+	return rev
+}
+
+// Keys returns the keys that hold a feature, in sorted order.
+func (h *History) Keys() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return slices.Clone(h.keys)
+}
+
+// KeysAfter returns up to limit keys that hold a feature and sort after the given key,
+// in sorted order, or all of them if limit is zero.
+func (h *History) KeysAfter(after string, limit int) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	i, found := slices.BinarySearch(h.keys, after)
+	if found {
+		i++
+	}
+	keys := h.keys[i:]
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return slices.Clone(keys)
+}
+
+// Revisions returns every revision of key, oldest first.
+func (h *History) Revisions(key string) ([]Revision, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revs := h.revisions[key]
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return append([]Revision(nil), revs...), nil
+}
+
+// GetRevision returns the feature as it was stored in the given revision.
+func (h *History) GetRevision(key string, number int) (*feature.Feature, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rev, err := h.revision(key, number)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Deleted {
+		return nil, fmt.Errorf("%w: %s was deleted in revision %d", ErrNotFound, key, number)
+	}
+	return rev.feature(), nil
+}
+
+// GetAt returns the feature as it was at time t.
+func (h *History) GetAt(key string, t time.Time) (*feature.Feature, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revs := h.revisions[key]
+	// Find the first revision written after t; the one before it was current at t.
+	i := sort.Search(len(revs), func(i int) bool { return revs[i].Time.After(t) })
+	if i == 0 || revs[i-1].Deleted {
+		return nil, fmt.Errorf("%w: %s at %s", ErrNotFound, key, t.Format(time.RFC3339))
+	}
+	return revs[i-1].feature(), nil
+}
+
+// Diff returns the changes made to key between two revisions.
+func (h *History) Diff(key string, from int, to int) ([]feature.Change, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	fromRev, err := h.revision(key, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := h.revision(key, to)
+	if err != nil {
+		return nil, err
+	}
+	return feature.CompareMaps(fromRev.m, toRev.m), nil
+}
+
+func (h *History) revision(key string, number int) (Revision, error) {
+	revs := h.revisions[key]
+	if len(revs) == 0 {
+		return Revision{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if number < 1 || number > len(revs) {
+		return Revision{}, fmt.Errorf("%w: %s revision %d", ErrRevisionNotFound, key, number)
+	}
+	return revs[number-1], nil
+}
+
+func (r Revision) feature() *feature.Feature {
+	return feature.New(r.schema, feature.WithMap(r.m), feature.WithSchemaVersion(r.SchemaVersion))
+}
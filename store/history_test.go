@@ -0,0 +1,172 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.t = c.t.Add(time.Hour)
+	return c.t
+}
+
+func TestHistory(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := NewHistory(WithNow(clock.now))
+
+	f := feature.New(feature.Schema{}, feature.WithMap(jsonchamp.NewFromItems("status", "open", "total", 10)))
+	first, err := h.Put("order:1", f, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Set("status", "shipped")
+	f.Delete("total")
+	second, err := h.Put("order:1", f, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.IsDirty() {
+		t.Fatal("Put() should mark the feature clean")
+	}
+
+	latest, err := h.Get("order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := latest.GetString("status"); status != "shipped" {
+		t.Fatalf("Get() status = %q, want %q", status, "shipped")
+	}
+
+	old, err := h.GetRevision("order:1", first.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := old.GetString("status"); status != "open" {
+		t.Fatalf("GetRevision(1) status = %q, want %q", status, "open")
+	}
+
+	between := first.Time.Add(30 * time.Minute)
+	atTime, err := h.GetAt("order:1", between)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := atTime.GetString("status"); status != "open" {
+		t.Fatalf("GetAt() status = %q, want %q", status, "open")
+	}
+	if _, err := h.GetAt("order:1", first.Time.Add(-time.Minute)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetAt() before first revision error = %v, want %v", err, ErrNotFound)
+	}
+
+	changes, err := h.Diff("order:1", first.Number, second.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Field != "status" || changes[1].Kind != feature.ChangeRemoved {
+		t.Fatalf("Diff() = %v, want status modified and total removed", changes)
+	}
+
+	revs, err := h.Revisions("order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Author != "alice" || revs[1].Author != "bob" {
+		t.Fatalf("Revisions() = %v, want revisions by alice and bob", revs)
+	}
+}
+
+func TestHistoryDelete(t *testing.T) {
+	h := NewHistory()
+
+	f := feature.New(feature.Schema{}, feature.WithMap(jsonchamp.NewFromItems("a", 1)))
+	if _, err := h.Put("k", f, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Delete("k", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Get("k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := h.GetRevision("k", 1); err != nil {
+		t.Fatalf("GetRevision(1) error = %v, want nil", err)
+	}
+	if _, err := h.GetRevision("k", 3); !errors.Is(err, ErrRevisionNotFound) {
+		t.Fatalf("GetRevision(3) error = %v, want %v", err, ErrRevisionNotFound)
+	}
+}
+
+func TestHistoryKeysAfter(t *testing.T) {
+	h := NewHistory()
+	for _, key := range []string{"d", "b", "a", "c", "e"} {
+		f := feature.New(feature.Schema{}, feature.WithMap(jsonchamp.NewFromItems("a", 1)))
+		if _, err := h.Put(key, f, "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Delete("c", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Put("b", feature.New(feature.Schema{}), "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := h.Keys(); !equalStrings(got, []string{"a", "b", "d", "e"}) {
+		t.Fatalf("Keys() = %v, want [a b d e]", got)
+	}
+	if got := h.KeysAfter("b", 2); !equalStrings(got, []string{"d", "e"}) {
+		t.Fatalf("KeysAfter(b, 2) = %v, want [d e]", got)
+	}
+	if got := h.KeysAfter("c", 1); !equalStrings(got, []string{"d"}) {
+		t.Fatalf("KeysAfter(c, 1) = %v, want [d]", got)
+	}
+	if got := h.KeysAfter("", 0); !equalStrings(got, []string{"a", "b", "d", "e"}) {
+		t.Fatalf("KeysAfter(\"\", 0) = %v, want [a b d e]", got)
+	}
+}
+
+func TestHistoryIfRevision(t *testing.T) {
+	h := NewHistory()
+	f := feature.New(feature.Schema{}, feature.WithMap(jsonchamp.NewFromItems("status", "open")))
+
+	first, err := h.PutIfRevision("order:1", f, "alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.PutIfRevision("order:1", f, "bob", 0); !errors.Is(err, ErrConflict) {
+		t.Fatalf("PutIfRevision() error = %v, want %v", err, ErrConflict)
+	}
+	if _, err := h.PutIfRevision("order:1", f, "bob", first.Number); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.DeleteIfRevision("order:1", "bob", first.Number); !errors.Is(err, ErrConflict) {
+		t.Fatalf("DeleteIfRevision() error = %v, want %v", err, ErrConflict)
+	}
+	if _, err := h.Put("order:2", f, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := h.Keys(); len(keys) != 2 || keys[0] != "order:1" || keys[1] != "order:2" {
+		t.Fatalf("Keys() = %v, want both orders", keys)
+	}
+
+	if _, err := h.DeleteIfRevision("order:1", "bob", 2); err != nil {
+		t.Fatal(err)
+	}
+	if keys := h.Keys(); len(keys) != 1 {
+		t.Fatalf("Keys() = %v, want only order:2", keys)
+	}
+	// A deleted key can be written again as if it was new.
+	if _, err := h.PutIfRevision("order:1", f, "carol", 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+type memoryEntry struct {
+	m             *jsonchamp.Map
+	schemaVersion int
+	// indexKeys are the keys of the feature in every index, by index name.
+	indexKeys map[string]feature.Key
+}
+
+type indexEntry struct {
+	key feature.Key
+	id  string
+}
+
+// Memory is an in-memory feature store for one schema. It maintains every index declared
+// by the schema, so features can be queried by index key prefix.
+type Memory struct {
+	mu        sync.RWMutex
+	schema    feature.Schema
+	templates map[string]*feature.KeyTemplate
+	indexMap  map[string]feature.KeyFunc
+	cursors   CursorCodec
+	features  map[string]memoryEntry
+	// indexes holds the entries of every index, sorted by key and then by feature key.
+	indexes map[string][]indexEntry
+}
+
+type MemoryOption func(*Memory)
+
+// WithMemoryCursorSecret sets the secret used to sign cursors. Without it, a random
+// secret is used and cursors are only valid for the lifetime of the store.
+func WithMemoryCursorSecret(secret []byte) MemoryOption {
+	return func(s *Memory) {
+		s.cursors = NewCursorCodec(secret)
+	}
+}
+
+// NewMemory creates an empty store for features of sch.
+func NewMemory(sch feature.Schema, opts ...MemoryOption) (*Memory, error) {
+	templates, err := sch.KeyTemplates()
+	if err != nil {
+		return nil, err
+	}
+	indexMap := make(map[string]feature.KeyFunc, len(templates))
+	for name, tmpl := range templates {
+		indexMap[name] = tmpl.KeyFunc()
+	}
+	s := &Memory{
+		schema:    sch,
+		templates: templates,
+		indexMap:  indexMap,
+		features:  make(map[string]memoryEntry),
+		indexes:   make(map[string][]indexEntry),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.cursors.secret == nil {
+		s.cursors = NewCursorCodec(nil)
+	}
+	return s, nil
+}
+
+// Get returns the feature stored under key.
+func (s *Memory) Get(_ context.Context, key string) (*feature.Feature, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.features[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return s.feature(e), nil
+}
+
+// Put stores f under key, replaces its index entries and marks f as clean.
+func (s *Memory) Put(_ context.Context, key string, f *feature.Feature) error {
+	indexKeys := make(map[string]feature.Key, len(s.indexMap))
+	for name, keyFunc := range s.indexMap {
+		k, err := keyFunc(f)
+		if err != nil {
+			return fmt.Errorf("index '%s': %w", name, err)
+		}
+		indexKeys[name] = k
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.features[key]; ok {
+		s.unindex(key, old)
+	}
+	e := memoryEntry{m: f.Map(), schemaVersion: f.SchemaVersion(), indexKeys: indexKeys}
+	s.features[key] = e
+	for name, k := range indexKeys {
+		entries := s.indexes[name]
+		i, _ := slices.BinarySearchFunc(entries, indexEntry{key: k, id: key}, compareIndexEntries)
+		s.indexes[name] = slices.Insert(entries, i, indexEntry{key: k, id: key})
+	}
+	f.MarkClean()
+	return nil
+}
+
+// Delete removes the feature stored under key and its index entries.
+func (s *Memory) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.features[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	s.unindex(key, e)
+	delete(s.features, key)
+	return nil
+}
+
+func (s *Memory) unindex(key string, e memoryEntry) {
+	for name, k := range e.indexKeys {
+		entries := s.indexes[name]
+		if i, found := slices.BinarySearchFunc(entries, indexEntry{key: k, id: key}, compareIndexEntries); found {
+			s.indexes[name] = slices.Delete(entries, i, i+1)
+		}
+	}
+}
+
+// QueryIndex returns a page of the features whose key in q.Index starts with q.Prefix,
+// ordered by index key and then by feature key.
+func (s *Memory) QueryIndex(_ context.Context, q IndexQuery) (Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.indexMap[q.Index]; !ok {
+		return Page{}, fmt.Errorf("%w: schema %s has no index '%s'", feature.ErrInvalidIndex, s.schema.Schema, q.Index)
+	}
+	entries := s.indexes[q.Index]
+
+	i, _ := slices.BinarySearchFunc(entries, indexEntry{key: q.Prefix}, compareIndexEntries)
+	if q.Cursor != "" {
+		position, err := s.cursors.Decode(q.fingerprint(), q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		after := indexEntry{key: feature.Key(position["key"]), id: position["id"]}
+		j, found := slices.BinarySearchFunc(entries, after, compareIndexEntries)
+		if found {
+			j++
+		}
+		i = max(i, j)
+	}
+
+	var page Page
+	for ; i < len(entries) && strings.HasPrefix(string(entries[i].key), string(q.Prefix)); i++ {
+		if q.Limit > 0 && len(page.Features) == q.Limit {
+			last := entries[i-1]
+			cursor, err := s.cursors.Encode(q.fingerprint(), map[string]string{"key": string(last.key), "id": last.id})
+			if err != nil {
+				return Page{}, err
+			}
+			page.Cursor = cursor
+			break
+		}
+		page.Features = append(page.Features, s.feature(s.features[entries[i].id]))
+	}
+	return page, nil
+}
+
+// List returns a page of the features matching q, ordered by the components of an index
+// key and then by feature key.
+func (s *Memory) List(_ context.Context, q ListQuery) (Page, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tmpl *feature.KeyTemplate
+	if q.Index != "" {
+		var ok bool
+		if tmpl, ok = s.templates[q.Index]; !ok {
+			return Page{}, fmt.Errorf("%w: schema %s has no index '%s'", feature.ErrInvalidIndex, s.schema.Schema, q.Index)
+		}
+	}
+	if err := q.validate(tmpl); err != nil {
+		return Page{}, err
+	}
+
+	type listItem struct {
+		id         string
+		key        feature.Key
+		components map[string]any
+	}
+	parse := func(id string, key feature.Key) (listItem, error) {
+		item := listItem{id: id, key: key}
+		if tmpl == nil {
+			return item, nil
+		}
+		var err error
+		item.components, err = tmpl.Parse(key)
+		return item, err
+	}
+	compare := func(a, b listItem) int {
+		for _, o := range q.OrderBy {
+			c := compareComponents(a.components[o.Component], b.components[o.Component])
+			if o.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.id, b.id)
+	}
+
+	var items []listItem
+	for id, e := range s.features {
+		if q.Filter != nil && !q.Filter.Eval(s.feature(e)) {
+			continue
+		}
+		item, err := parse(id, e.indexKeys[q.Index])
+		if err != nil {
+			return Page{}, err
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, compare)
+
+	if q.Cursor != "" {
+		position, err := s.cursors.Decode(q.fingerprint(), q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		after, err := parse(position["id"], feature.Key(position["key"]))
+		if err != nil {
+			return Page{}, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+		// The feature at the position may have changed or been deleted since; the page
+		// starts after where it would be.
+		i, found := slices.BinarySearchFunc(items, after, compare)
+		if found {
+			i++
+		}
+		items = items[i:]
+	}
+
+	var page Page
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+		last := items[len(items)-1]
+		cursor, err := s.cursors.Encode(q.fingerprint(), map[string]string{"key": string(last.key), "id": last.id})
+		if err != nil {
+			return Page{}, err
+		}
+		page.Cursor = cursor
+	}
+	for _, item := range items {
+		page.Features = append(page.Features, s.feature(s.features[item.id]))
+	}
+	return page, nil
+}
+
+func (s *Memory) feature(e memoryEntry) *feature.Feature {
+	return feature.New(s.schema, feature.WithMap(e.m), feature.WithSchemaVersion(e.schemaVersion))
+}
+
+// compareComponents orders parsed key components, which are strings, int64 or float64.
+func compareComponents(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmp.Compare(a, b)
+		}
+	}
+	return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareIndexEntries(a, b indexEntry) int {
+	if c := cmp.Compare(a.key, b.key); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
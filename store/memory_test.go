@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+)
+
+var customerOrderSchema = feature.Schema{
+	Schema: "urn:features:customer_order",
+	Migrations: feature.Migrations{
+		{
+			Description: "Initial schema",
+			Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "order_id", Type: feature.FieldTypeString, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "customer_id", Type: feature.FieldTypeInteger, Required: true}},
+				feature.AddIndex{Index: feature.Index{Name: "pk", Parts: []feature.IndexPart{{Literal: "ORDER"}, {Property: "order_id"}}}},
+				feature.AddIndex{Index: feature.Index{Name: "sk", Parts: []feature.IndexPart{{Literal: "META"}}}},
+				feature.AddIndex{Index: feature.Index{Name: "gsi1", Parts: []feature.IndexPart{{Literal: "CUST"}, {Property: "customer_id"}, {Property: "order_id"}}}},
+			},
+		},
+	},
+}
+
+func customerOrder(orderID string, customerID int) *feature.Feature {
+	return feature.New(customerOrderSchema, feature.WithMap(jsonchamp.NewFromItems("order_id", orderID, "customer_id", customerID)))
+}
+
+func customerPrefix(customerID int64) feature.Key {
+	return feature.Key("CUST:" + feature.EncodeIntKey(customerID) + ":")
+}
+
+// orderIDs returns the order IDs of features on every page of a query, and the number of pages.
+func orderIDs(t *testing.T, query func(cursor string) (Page, error)) ([]string, int) {
+	t.Helper()
+	var ids []string
+	pages := 0
+	cursor := ""
+	for {
+		page, err := query(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, f := range page.Features {
+			id, _ := f.GetString("order_id")
+			ids = append(ids, id)
+		}
+		if page.Cursor == "" {
+			return ids, pages
+		}
+		cursor = page.Cursor
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryIndexes(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewMemory(customerOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*feature.Feature{
+		customerOrder("A3", 42),
+		customerOrder("A1", 42),
+		customerOrder("A2", 42),
+		customerOrder("B1", 7),
+		customerOrder("C1", 420),
+	} {
+		id, _ := f.GetString("order_id")
+		if err := s.Put(ctx, id, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(prefix feature.Key, limit int) func(string) (Page, error) {
+		return func(cursor string) (Page, error) {
+			return s.QueryIndex(ctx, IndexQuery{Index: "gsi1", Prefix: prefix, Limit: limit, Cursor: cursor})
+		}
+	}
+
+	ids, pages := orderIDs(t, query(customerPrefix(42), 2))
+	if want := []string{"A1", "A2", "A3"}; !equalStrings(ids, want) || pages != 2 {
+		t.Fatalf("QueryIndex() = %v in %d pages, want %v in 2 pages", ids, pages, want)
+	}
+
+	// Moving an order to another customer removes its stale index entry.
+	moved, err := s.Get(ctx, "A2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved.Set("customer_id", 7)
+	if err := s.Put(ctx, "A2", moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "A3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, _ := orderIDs(t, query(customerPrefix(42), 0)); !equalStrings(ids, []string{"A1"}) {
+		t.Fatalf("QueryIndex(42) = %v, want [A1]", ids)
+	}
+	if ids, _ := orderIDs(t, query(customerPrefix(7), 0)); !equalStrings(ids, []string{"A2", "B1"}) {
+		t.Fatalf("QueryIndex(7) = %v, want [A2 B1]", ids)
+	}
+	if ids, _ := orderIDs(t, query("", 0)); len(ids) != 4 {
+		t.Fatalf("QueryIndex() without prefix = %v, want 4 orders", ids)
+	}
+
+	if _, err := s.QueryIndex(ctx, IndexQuery{Index: "gsi9"}); !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("QueryIndex() on unknown index error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+	if _, err := s.QueryIndex(ctx, IndexQuery{Index: "gsi1", Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("QueryIndex() with bad cursor error = %v, want %v", err, ErrInvalidCursor)
+	}
+	if err := s.Delete(ctx, "A3"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() of missing feature error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestMemoryList(t *testing.T) {
+	s, err := NewMemory(customerOrderSchema, WithMemoryCursorSecret([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testList(t, s)
+}
+
+// testList checks List of a store, which must be empty.
+func testList(t *testing.T, s Indexed) {
+	t.Helper()
+	ctx := context.Background()
+	for _, f := range []*feature.Feature{
+		customerOrder("A1", 42),
+		customerOrder("A2", 7),
+		customerOrder("A3", 42),
+		customerOrder("A4", 100),
+		customerOrder("A5", 7),
+	} {
+		id, _ := f.GetString("order_id")
+		if err := s.Put(ctx, id, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := ListQuery{
+		Index:   "gsi1",
+		OrderBy: []Order{{Component: "customer_id", Descending: true}, {Component: "order_id"}},
+		Limit:   2,
+	}
+	page, err := s.List(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, f := range page.Features {
+		id, _ := f.GetString("order_id")
+		ids = append(ids, id)
+	}
+	if !equalStrings(ids, []string{"A4", "A1"}) {
+		t.Fatalf("List() first page = %v, want [A4 A1]", ids)
+	}
+
+	// Writes between pages neither repeat nor skip features that did not move.
+	if err := s.Delete(ctx, "A1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "A0", customerOrder("A0", 42)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "A6", customerOrder("A6", 42)); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Cursor = page.Cursor
+	rest, _ := orderIDs(t, func(cursor string) (Page, error) {
+		if cursor != "" {
+			q.Cursor = cursor
+		}
+		return s.List(ctx, q)
+	})
+	if want := []string{"A3", "A6", "A2", "A5"}; !equalStrings(rest, want) {
+		t.Fatalf("List() remaining pages = %v, want %v", rest, want)
+	}
+
+	filter, err := query.Compile(customerOrderSchema, "customer_id = 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := s.List(ctx, ListQuery{Filter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Features) != 2 || filtered.Cursor != "" {
+		t.Fatalf("List() with filter = %d features, want 2 on one page", len(filtered.Features))
+	}
+
+	if _, err := s.List(ctx, ListQuery{Index: "gsi1", OrderBy: []Order{{Component: "total"}}}); !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("List() by unknown component error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+	if _, err := s.List(ctx, ListQuery{Index: "gsi1", Cursor: page.Cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("List() with cursor of another query error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
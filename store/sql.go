@@ -0,0 +1,459 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+)
+
+// SQL stores features of one schema in a relational database. Payloads are kept as JSON
+// in one table, and the keys of every index declared by the schema in a second table,
+// named after the first with an "_index" suffix, so features can be queried by index key
+// prefix. Index keys are compared as text and must compare byte by byte, which is the
+// default in SQLite and the "C" collation in PostgreSQL.
+type SQL struct {
+	db          *sql.DB
+	table       string
+	schema      feature.Schema
+	templates   map[string]*feature.KeyTemplate
+	indexMap    map[string]feature.KeyFunc
+	placeholder func(n int) string
+	field       func(name string) string
+	cursors     CursorCodec
+}
+
+type SQLOption func(*SQL)
+
+// WithSQLCursorSecret sets the secret used to sign cursors. Without it, a random secret
+// is used and cursors are only valid for the lifetime of the store.
+func WithSQLCursorSecret(secret []byte) SQLOption {
+	return func(s *SQL) {
+		s.cursors = NewCursorCodec(secret)
+	}
+}
+
+// WithSQLPlaceholder sets the function that renders the placeholder of the nth argument,
+// such as query.DollarPlaceholder for PostgreSQL. It defaults to query.QuestionPlaceholder.
+func WithSQLPlaceholder(placeholder func(n int) string) SQLOption {
+	return func(s *SQL) {
+		s.placeholder = placeholder
+	}
+}
+
+// WithSQLField sets the function that renders the SQL expression reading a property from
+// the "payload" column, which List uses to order and filter features. It defaults to
+// query.JSONExtract("payload"), which suits SQLite and MySQL.
+func WithSQLField(field func(name string) string) SQLOption {
+	return func(s *SQL) {
+		s.field = field
+	}
+}
+
+// NewSQL creates a store for features of sch in the named table of db.
+func NewSQL(db *sql.DB, table string, sch feature.Schema, opts ...SQLOption) (*SQL, error) {
+	templates, err := sch.KeyTemplates()
+	if err != nil {
+		return nil, err
+	}
+	indexMap := make(map[string]feature.KeyFunc, len(templates))
+	for name, tmpl := range templates {
+		indexMap[name] = tmpl.KeyFunc()
+	}
+	s := &SQL{
+		db:          db,
+		table:       table,
+		schema:      sch,
+		templates:   templates,
+		indexMap:    indexMap,
+		placeholder: query.QuestionPlaceholder,
+		field:       query.JSONExtract("payload"),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.cursors.secret == nil {
+		s.cursors = NewCursorCodec(nil)
+	}
+	return s, nil
+}
+
+// Definition returns the statements creating the tables the store expects, unless they
+// exist.
+func (s *SQL) Definition() []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  "key" TEXT NOT NULL PRIMARY KEY,
+  "schema_version" INTEGER NOT NULL,
+  "payload" TEXT NOT NULL
+)`, s.features()),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  "index_name" TEXT NOT NULL,
+  "index_key" TEXT NOT NULL,
+  "feature_key" TEXT NOT NULL,
+  PRIMARY KEY ("index_name", "index_key", "feature_key")
+)`, s.indexes()),
+	}
+}
+
+// Get returns the feature stored under key.
+func (s *SQL) Get(ctx context.Context, key string) (*feature.Feature, error) {
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT "schema_version", "payload" FROM %s WHERE "key" = %s`, s.features(), s.placeholder(1)), key)
+	var schemaVersion int
+	var payload string
+	if err := row.Scan(&schemaVersion, &payload); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, err
+	}
+	return s.decode(schemaVersion, payload)
+}
+
+// Put stores f under key, replaces its index entries and marks f as clean. The feature
+// and its index entries are written in one transaction.
+func (s *SQL) Put(ctx context.Context, key string, f *feature.Feature) error {
+	indexKeys := make(map[string]feature.Key, len(s.indexMap))
+	for name, keyFunc := range s.indexMap {
+		k, err := keyFunc(f)
+		if err != nil {
+			return fmt.Errorf("index '%s': %w", name, err)
+		}
+		indexKeys[name] = k
+	}
+	payload, err := json.Marshal(f.Map())
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "feature_key" = %s`, s.indexes(), s.placeholder(1)), key); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "key" = %s`, s.features(), s.placeholder(1)), key); err != nil {
+			return err
+		}
+		insert := fmt.Sprintf(`INSERT INTO %s ("key", "schema_version", "payload") VALUES (%s, %s, %s)`, s.features(), s.placeholder(1), s.placeholder(2), s.placeholder(3))
+		if _, err := tx.ExecContext(ctx, insert, key, f.SchemaVersion(), string(payload)); err != nil {
+			return err
+		}
+		insert = fmt.Sprintf(`INSERT INTO %s ("index_name", "index_key", "feature_key") VALUES (%s, %s, %s)`, s.indexes(), s.placeholder(1), s.placeholder(2), s.placeholder(3))
+		for _, name := range slices.Sorted(maps.Keys(indexKeys)) {
+			if _, err := tx.ExecContext(ctx, insert, name, string(indexKeys[name]), key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.MarkClean()
+	return nil
+}
+
+// Delete removes the feature stored under key and its index entries.
+func (s *SQL) Delete(ctx context.Context, key string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "feature_key" = %s`, s.indexes(), s.placeholder(1)), key); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "key" = %s`, s.features(), s.placeholder(1)), key)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil
+	})
+}
+
+// QueryIndex returns a page of the features whose key in q.Index starts with q.Prefix,
+// ordered by index key and then by feature key.
+func (s *SQL) QueryIndex(ctx context.Context, q IndexQuery) (Page, error) {
+	if _, ok := s.indexMap[q.Index]; !ok {
+		return Page{}, fmt.Errorf("%w: schema %s has no index '%s'", feature.ErrInvalidIndex, s.schema.Schema, q.Index)
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return s.placeholder(len(args))
+	}
+	stmt := fmt.Sprintf(`SELECT f."schema_version", f."payload", i."index_key", i."feature_key" FROM %s i JOIN %s f ON f."key" = i."feature_key" WHERE i."index_name" = %s`,
+		s.indexes(), s.features(), arg(q.Index))
+	if q.Prefix != "" {
+		stmt += ` AND i."index_key" >= ` + arg(string(q.Prefix))
+		if end, ok := prefixEnd(string(q.Prefix)); ok {
+			stmt += ` AND i."index_key" < ` + arg(end)
+		}
+	}
+	if q.Cursor != "" {
+		position, err := s.cursors.Decode(q.fingerprint(), q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		stmt += fmt.Sprintf(` AND (i."index_key" > %s OR (i."index_key" = %s AND i."feature_key" > %s))`,
+			arg(position["key"]), arg(position["key"]), arg(position["id"]))
+	}
+	stmt += ` ORDER BY i."index_key", i."feature_key"`
+	if q.Limit > 0 {
+		// One more row than the page tells whether there is a next page.
+		stmt += " LIMIT " + strconv.Itoa(q.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	var page Page
+	var last indexEntry
+	for rows.Next() {
+		if q.Limit > 0 && len(page.Features) == q.Limit {
+			cursor, err := s.cursors.Encode(q.fingerprint(), map[string]string{"key": string(last.key), "id": last.id})
+			if err != nil {
+				return Page{}, err
+			}
+			page.Cursor = cursor
+			break
+		}
+		var schemaVersion int
+		var payload, indexKey string
+		if err := rows.Scan(&schemaVersion, &payload, &indexKey, &last.id); err != nil {
+			return Page{}, err
+		}
+		last.key = feature.Key(indexKey)
+		f, err := s.decode(schemaVersion, payload)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Features = append(page.Features, f)
+	}
+	return page, rows.Err()
+}
+
+// List returns a page of the features matching q, ordered by the components of an index
+// key and then by feature key. Key components are named after the properties they are
+// built from, so the database orders by the property values in the payloads, and the
+// filter is evaluated by the database as well.
+func (s *SQL) List(ctx context.Context, q ListQuery) (Page, error) {
+	var tmpl *feature.KeyTemplate
+	if q.Index != "" {
+		var ok bool
+		if tmpl, ok = s.templates[q.Index]; !ok {
+			return Page{}, fmt.Errorf("%w: schema %s has no index '%s'", feature.ErrInvalidIndex, s.schema.Schema, q.Index)
+		}
+	}
+	if err := q.validate(tmpl); err != nil {
+		return Page{}, err
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return s.placeholder(len(args))
+	}
+	columns := make([]string, len(q.OrderBy))
+	for i, o := range q.OrderBy {
+		columns[i] = s.field(o.Component)
+	}
+	selected := `"key", "schema_version", "payload"`
+	for _, c := range columns {
+		selected += ", " + c
+	}
+	stmt := fmt.Sprintf(`SELECT %s FROM %s WHERE TRUE`, selected, s.features())
+
+	if q.Filter != nil {
+		cond, filterArgs, err := query.ToSQL(q.Filter, query.WithColumn(s.field), query.WithPlaceholder(func(n int) string {
+			return s.placeholder(len(args) + n)
+		}))
+		if err != nil {
+			return Page{}, err
+		}
+		args = append(args, filterArgs...)
+		stmt += " AND " + cond
+	}
+	if q.Cursor != "" {
+		position, err := s.cursors.Decode(q.fingerprint(), q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		after, err := decodeListPosition(position, len(q.OrderBy))
+		if err != nil {
+			return Page{}, err
+		}
+		// Rows after the position differ from it in the first ordering column where
+		// they do not equal it, or only in the feature key.
+		var alternatives []string
+		for i := 0; i <= len(columns); i++ {
+			var terms []string
+			for j := range i {
+				terms = append(terms, fmt.Sprintf("%s = %s", columns[j], arg(after[j])))
+			}
+			if i < len(columns) {
+				op := ">"
+				if q.OrderBy[i].Descending {
+					op = "<"
+				}
+				terms = append(terms, fmt.Sprintf("%s %s %s", columns[i], op, arg(after[i])))
+			} else {
+				terms = append(terms, `"key" > `+arg(position["id"]))
+			}
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		stmt += " AND (" + strings.Join(alternatives, " OR ") + ")"
+	}
+
+	stmt += " ORDER BY "
+	for i, c := range columns {
+		stmt += c
+		if q.OrderBy[i].Descending {
+			stmt += " DESC"
+		}
+		stmt += ", "
+	}
+	stmt += `"key"`
+	if q.Limit > 0 {
+		// One more row than the page tells whether there is a next page.
+		stmt += " LIMIT " + strconv.Itoa(q.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	var page Page
+	var lastID string
+	last := make([]any, len(columns))
+	for rows.Next() {
+		if q.Limit > 0 && len(page.Features) == q.Limit {
+			position, err := encodeListPosition(lastID, last)
+			if err != nil {
+				return Page{}, err
+			}
+			cursor, err := s.cursors.Encode(q.fingerprint(), position)
+			if err != nil {
+				return Page{}, err
+			}
+			page.Cursor = cursor
+			break
+		}
+		var schemaVersion int
+		var payload string
+		dest := []any{&lastID, &schemaVersion, &payload}
+		for i := range last {
+			dest = append(dest, &last[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return Page{}, err
+		}
+		f, err := s.decode(schemaVersion, payload)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Features = append(page.Features, f)
+	}
+	return page, rows.Err()
+}
+
+// encodeListPosition records the feature key and ordering values of the last row of a
+// page. The values are kept as JSON so they keep their types.
+func encodeListPosition(id string, values []any) (map[string]string, error) {
+	position := map[string]string{"id": id}
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		position[strconv.Itoa(i)] = string(data)
+	}
+	return position, nil
+}
+
+func decodeListPosition(position map[string]string, n int) ([]any, error) {
+	values := make([]any, n)
+	for i := range values {
+		dec := json.NewDecoder(strings.NewReader(position[strconv.Itoa(i)]))
+		dec.UseNumber()
+		if err := dec.Decode(&values[i]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+		if n, ok := values[i].(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				values[i] = v
+			} else if values[i], err = n.Float64(); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+			}
+		}
+	}
+	return values, nil
+}
+
+func (s *SQL) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQL) decode(schemaVersion int, payload string) (*feature.Feature, error) {
+	m := jsonchamp.New()
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		return nil, fmt.Errorf("payload in table %s: %w", s.table, err)
+	}
+	return feature.New(s.schema, feature.WithMap(m), feature.WithSchemaVersion(schemaVersion)), nil
+}
+
+func (s *SQL) features() string {
+	return query.QuoteIdentifier(s.table)
+}
+
+func (s *SQL) indexes() string {
+	return query.QuoteIdentifier(s.table + "_index")
+}
+
+// prefixEnd returns the smallest valid UTF-8 string greater than every string starting
+// with prefix, or false if there is none. UTF-8 orders like the code points it encodes, so
+// the end is found by incrementing the last rune that is not the largest one.
+func prefixEnd(prefix string) (string, bool) {
+	runes := []rune(prefix)
+	for i := len(runes) - 1; i >= 0; i-- {
+		switch r := runes[i]; {
+		case r == utf8.MaxRune:
+			continue
+		case r == 0xd7ff:
+			// Skip the surrogate halves, which are not valid in UTF-8.
+			runes[i] = 0xe000
+		default:
+			runes[i] = r + 1
+		}
+		return string(runes[:i+1]), true
+	}
+	return "", false
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/query"
+)
+
+// newTestSQL returns a SQL store backed by an in-memory SQLite database. The driver needs
+// cgo, so the test is skipped when it is built without.
+func newTestSQL(t *testing.T) *SQL {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// Every connection opens its own in-memory database.
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		t.Skipf("sqlite3 is not available: %v", err)
+	}
+
+	s, err := NewSQL(db, "orders", customerOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range s.Definition() {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSQLIndexes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQL(t)
+	for _, f := range []*feature.Feature{
+		customerOrder("A3", 42),
+		customerOrder("A1", 42),
+		customerOrder("A2", 42),
+		customerOrder("B1", 7),
+		customerOrder("C1", 420),
+	} {
+		id, _ := f.GetString("order_id")
+		if err := s.Put(ctx, id, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(prefix feature.Key, limit int) func(string) (Page, error) {
+		return func(cursor string) (Page, error) {
+			return s.QueryIndex(ctx, IndexQuery{Index: "gsi1", Prefix: prefix, Limit: limit, Cursor: cursor})
+		}
+	}
+
+	ids, pages := orderIDs(t, query(customerPrefix(42), 2))
+	if want := []string{"A1", "A2", "A3"}; !equalStrings(ids, want) || pages != 2 {
+		t.Fatalf("QueryIndex() = %v in %d pages, want %v in 2 pages", ids, pages, want)
+	}
+
+	// Moving an order to another customer removes its stale index entry.
+	moved, err := s.Get(ctx, "A2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved.Set("customer_id", 7)
+	if err := s.Put(ctx, "A2", moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "A3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, _ := orderIDs(t, query(customerPrefix(42), 0)); !equalStrings(ids, []string{"A1"}) {
+		t.Fatalf("QueryIndex(42) = %v, want [A1]", ids)
+	}
+	if ids, _ := orderIDs(t, query(customerPrefix(7), 0)); !equalStrings(ids, []string{"A2", "B1"}) {
+		t.Fatalf("QueryIndex(7) = %v, want [A2 B1]", ids)
+	}
+	if ids, _ := orderIDs(t, query("", 0)); len(ids) != 4 {
+		t.Fatalf("QueryIndex() without prefix = %v, want 4 orders", ids)
+	}
+
+	if _, err := s.QueryIndex(ctx, IndexQuery{Index: "gsi9"}); !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("QueryIndex() on unknown index error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+	if _, err := s.QueryIndex(ctx, IndexQuery{Index: "gsi1", Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("QueryIndex() with bad cursor error = %v, want %v", err, ErrInvalidCursor)
+	}
+	if _, err := s.Get(ctx, "A3"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of deleted feature error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Delete(ctx, "A3"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() of missing feature error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"a":                    "b",
+		"customer#42":          "customer#43",
+		"a\u00ff":              "a\u0100",
+		"a\u007f":              "a\u0080",
+		"a\U0010ffff":          "b",
+		"a\ud7ff":              "a\ue000",
+		"\U0010ffff\U0010ffff": "",
+	} {
+		got, ok := prefixEnd(prefix)
+		if got != want || ok != (want != "") {
+			t.Errorf("prefixEnd(%q) = %q, %v; want %q", prefix, got, ok, want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("prefixEnd(%q) = %q, which is not valid UTF-8", prefix, got)
+		}
+	}
+}
+
+func TestSQLQueryIndexNonASCIIPrefix(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQL(t)
+	for _, id := range []string{"ÿ1", "ÿ2", "Ā1", "z1"} {
+		if err := s.Put(ctx, id, customerOrder(id, 5)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, _ := orderIDs(t, func(cursor string) (Page, error) {
+		return s.QueryIndex(ctx, IndexQuery{Index: "gsi1", Prefix: customerPrefix(5) + "ÿ", Cursor: cursor})
+	})
+	if want := []string{"ÿ1", "ÿ2"}; !equalStrings(ids, want) {
+		t.Fatalf("QueryIndex() = %q, want %q", ids, want)
+	}
+}
+
+func TestSQLList(t *testing.T) {
+	testList(t, newTestSQL(t))
+}
+
+func TestSQLListFilter(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQL(t)
+	mem, err := NewMemory(customerOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*feature.Feature{
+		customerOrder("A1", 42),
+		customerOrder("A2", 7),
+		customerOrder("A3", 42),
+		customerOrder("A4", 100),
+		customerOrder("A5", 7),
+	} {
+		id, _ := f.GetString("order_id")
+		if err := s.Put(ctx, id, f); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Put(ctx, id, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The database evaluates filters on the JSON payloads like Eval does in memory.
+	for _, text := range []string{
+		`customer_id = 42`,
+		`customer_id > 7 AND order_id != "A3"`,
+		`NOT customer_id = 7`,
+		`order_id >= "A4" OR customer_id < 10`,
+	} {
+		filter, err := query.Compile(customerOrderSchema, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := ListQuery{Filter: filter, Limit: 1}
+		got, _ := orderIDs(t, func(cursor string) (Page, error) {
+			q.Cursor = cursor
+			return s.List(ctx, q)
+		})
+		want, _ := orderIDs(t, func(cursor string) (Page, error) {
+			q.Cursor = cursor
+			return mem.List(ctx, q)
+		})
+		if len(want) == 0 || !equalStrings(got, want) {
+			t.Errorf("List(%s) = %v, want %v", text, got, want)
+		}
+	}
+}
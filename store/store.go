@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+
+	"github.com/mamaar/features/feature"
+)
+
+// Indexed is a store of features of one schema that maintains every index declared by
+// the schema, so features can be queried by index key prefix. Memory and SQL implement it.
+type Indexed interface {
+	// Get returns the feature stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) (*feature.Feature, error)
+	// Put stores f under key, replaces its index entries and marks f as clean.
+	Put(ctx context.Context, key string, f *feature.Feature) error
+	// Delete removes the feature stored under key and its index entries, or returns
+	// ErrNotFound.
+	Delete(ctx context.Context, key string) error
+	// QueryIndex returns a page of the features whose key in q.Index starts with
+	// q.Prefix, ordered by index key and then by feature key.
+	QueryIndex(ctx context.Context, q IndexQuery) (Page, error)
+	// List returns a page of the features matching q, ordered by the components of an
+	// index key and then by feature key.
+	List(ctx context.Context, q ListQuery) (Page, error)
+}
+
+var (
+	_ Indexed = (*Memory)(nil)
+	_ Indexed = (*SQL)(nil)
+)
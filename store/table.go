@@ -0,0 +1,539 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/dynamo"
+	"github.com/mamaar/features/feature"
+)
+
+var (
+	ErrConflict = errors.New("version conflict")
+)
+
+const (
+	// VersionAttribute holds the version of an item, incremented on every write.
+	VersionAttribute = "version"
+	// SchemaVersionAttribute holds the schema version of the stored feature.
+	SchemaVersionAttribute = "schema_version"
+	// IndexPartitionSuffix is appended to the name of a secondary index to name the
+	// partition key attribute of its global secondary index. The attribute holds the index
+	// name sharded with a hash of the primary key, as built by feature.ShardKeys, so the
+	// entries of an index are spread over several partitions and queries fan out over all
+	// of them.
+	IndexPartitionSuffix = "_partition"
+	// DefaultIndexShards is the number of partitions of every secondary index.
+	DefaultIndexShards = 16
+	// DefaultPutAttempts is the number of times an unconditional Put reads and writes an
+	// item before giving up on writers that keep coming in between.
+	DefaultPutAttempts = 8
+)
+
+// Table stores features of one schema in a single table of a DynamoDB-compatible
+// service. Items are built with feature.CreateKeyedPayload, so every index declared by the
+// schema becomes an attribute, and two of them form the primary key of the table.
+type Table struct {
+	client       dynamo.Client
+	name         string
+	schema       feature.Schema
+	indexMap     map[string]feature.KeyFunc
+	partitionKey string
+	sortKey      string
+	indexShards  int
+	putAttempts  int
+	cursors      CursorCodec
+}
+
+type TableOption func(*Table)
+
+// WithTableCursorSecret sets the secret used to sign cursors. Without it, a random
+// secret is used and cursors are only valid for the lifetime of the store.
+func WithTableCursorSecret(secret []byte) TableOption {
+	return func(t *Table) {
+		t.cursors = NewCursorCodec(secret)
+	}
+}
+
+// WithKeyIndexes sets the schema indexes that form the partition and sort key of the
+// table. They default to "pk" and "sk". The sort key is optional.
+func WithKeyIndexes(partition, sort string) TableOption {
+	return func(t *Table) {
+		t.partitionKey = partition
+		t.sortKey = sort
+	}
+}
+
+// WithIndexShards sets the number of partitions every secondary index is spread over.
+// Queries of an index read from all of them, so more shards spread writes further at the
+// cost of more reads per query. Changing it requires rewriting every item.
+func WithIndexShards(shards int) TableOption {
+	return func(t *Table) {
+		t.indexShards = shards
+	}
+}
+
+// WithPutAttempts sets the number of times an unconditional Put reads and writes an item
+// while other writes keep coming in between, before it fails with ErrConflict.
+func WithPutAttempts(attempts int) TableOption {
+	return func(t *Table) {
+		t.putAttempts = attempts
+	}
+}
+
+// NewTable creates a store for features of sch in the named table.
+func NewTable(client dynamo.Client, name string, sch feature.Schema, opts ...TableOption) (*Table, error) {
+	t := &Table{
+		client:       client,
+		name:         name,
+		schema:       sch,
+		partitionKey: "pk",
+		sortKey:      "sk",
+		indexShards:  DefaultIndexShards,
+		putAttempts:  DefaultPutAttempts,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.cursors.secret == nil {
+		t.cursors = NewCursorCodec(nil)
+	}
+
+	indexMap, err := sch.IndexMap()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := indexMap[t.partitionKey]; !ok {
+		return nil, fmt.Errorf("%w: schema %s has no partition key index '%s'", feature.ErrInvalidIndex, sch.Schema, t.partitionKey)
+	}
+	if _, ok := indexMap[t.sortKey]; t.sortKey != "" && !ok {
+		return nil, fmt.Errorf("%w: schema %s has no sort key index '%s'", feature.ErrInvalidIndex, sch.Schema, t.sortKey)
+	}
+	if t.indexShards <= 0 {
+		return nil, fmt.Errorf("%w: number of index shards must be positive, got %d", feature.ErrInvalidIndex, t.indexShards)
+	}
+	if t.putAttempts <= 0 {
+		return nil, fmt.Errorf("number of put attempts must be positive, got %d", t.putAttempts)
+	}
+	t.indexMap = indexMap
+	return t, nil
+}
+
+// Definition returns the table definition the store expects, for creating the table.
+// Every index of the schema other than the primary key becomes a global secondary index,
+// which the service keeps up to date as features are written and deleted.
+func (t *Table) Definition() dynamo.Definition {
+	def := dynamo.Definition{
+		Name:         t.name,
+		PartitionKey: t.partitionKey,
+		SortKey:      t.sortKey,
+	}
+	for _, name := range t.secondaryIndexes() {
+		def.Indexes = append(def.Indexes, dynamo.IndexDefinition{
+			Name:         name,
+			PartitionKey: name + IndexPartitionSuffix,
+			SortKey:      name,
+		})
+	}
+	return def
+}
+
+func (t *Table) secondaryIndexes() []string {
+	var names []string
+	for name := range t.indexMap {
+		if name != t.partitionKey && name != t.sortKey {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+type writeConditions struct {
+	notExists bool
+	version   *int64
+}
+
+type WriteOption func(*writeConditions)
+
+// IfNotExists makes a write fail with ErrConflict if the item already exists.
+func IfNotExists() WriteOption {
+	return func(c *writeConditions) {
+		c.notExists = true
+	}
+}
+
+// IfVersion makes a write fail with ErrConflict unless the stored item has the version.
+func IfVersion(version int64) WriteOption {
+	return func(c *writeConditions) {
+		c.version = &version
+	}
+}
+
+// Put writes f and returns its new version. Without conditions the write replaces any
+// stored item, after any write that came in between; with IfVersion it only succeeds if
+// nobody wrote the item in the meantime.
+func (t *Table) Put(ctx context.Context, f *feature.Feature, opts ...WriteOption) (int64, error) {
+	var conds writeConditions
+	for _, opt := range opts {
+		opt(&conds)
+	}
+
+	item, err := feature.CreateKeyedPayload(f, t.indexMap)
+	if err != nil {
+		return 0, err
+	}
+
+	item = item.Set(SchemaVersionAttribute, f.SchemaVersion())
+	shard := t.shard(item)
+	for _, name := range t.secondaryIndexes() {
+		item = item.Set(name+IndexPartitionSuffix, string(feature.ShardKeys(feature.Key(name), t.indexShards)[shard]))
+	}
+
+	switch {
+	case conds.notExists:
+		return t.put(ctx, f, item, 0, conds)
+	case conds.version != nil:
+		return t.put(ctx, f, item, *conds.version, conds)
+	}
+	// Without conditions the write is still made conditional on the version it read, so
+	// that two concurrent writes can not both store the same next version. If another
+	// write came first, the version is read again, up to the configured number of times.
+	for range t.putAttempts {
+		var next int64
+		if next, err = t.putAtCurrentVersion(ctx, f, item); !errors.Is(err, ErrConflict) {
+			return next, err
+		}
+	}
+	return 0, fmt.Errorf("%w: gave up after %d attempts", err, t.putAttempts)
+}
+
+// putAtCurrentVersion writes the item on the condition that its stored version has not
+// changed since it was read.
+func (t *Table) putAtCurrentVersion(ctx context.Context, f *feature.Feature, item *jsonchamp.Map) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	existing, err := t.client.GetItem(ctx, dynamo.GetItemInput{TableName: t.name, Key: t.itemKey(item)})
+	if err != nil {
+		return 0, err
+	}
+	read := writeConditions{notExists: true}
+	var version int64
+	if existing != nil {
+		version, _ = existing.GetInt(VersionAttribute)
+		read = writeConditions{version: &version}
+	}
+	return t.put(ctx, f, item, version, read)
+}
+
+// put writes the item at the version after the given one, on the conditions.
+func (t *Table) put(ctx context.Context, f *feature.Feature, item *jsonchamp.Map, version int64, conds writeConditions) (int64, error) {
+	item = item.Set(VersionAttribute, version+1)
+	in := dynamo.PutItemInput{TableName: t.name, Item: item}
+	in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues = t.condition(conds)
+
+	if err := t.client.PutItem(ctx, in); err != nil {
+		if errors.Is(err, dynamo.ErrConditionalCheckFailed) {
+			return 0, t.conflict(item, conds)
+		}
+		return 0, err
+	}
+	f.MarkClean()
+	return version + 1, nil
+}
+
+// Get returns the feature with the given keys and its version.
+func (t *Table) Get(ctx context.Context, partition, sort feature.Key) (*feature.Feature, int64, error) {
+	item, err := t.client.GetItem(ctx, dynamo.GetItemInput{TableName: t.name, Key: t.key(partition, sort)})
+	if err != nil {
+		return nil, 0, err
+	}
+	if item == nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, t.describe(partition, sort))
+	}
+	return t.decode(item)
+}
+
+// Delete removes the feature with the given keys. Only IfVersion applies to deletes.
+func (t *Table) Delete(ctx context.Context, partition, sort feature.Key, opts ...WriteOption) error {
+	conds := writeConditions{}
+	for _, opt := range opts {
+		opt(&conds)
+	}
+	conds.notExists = false
+
+	in := dynamo.DeleteItemInput{TableName: t.name, Key: t.key(partition, sort)}
+	in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues = t.condition(conds)
+	if in.ConditionExpression == "" {
+		// Deleting a missing feature is reported like reading one.
+		in.ConditionExpression = "attribute_exists(#pk)"
+		in.ExpressionAttributeNames = map[string]string{"#pk": t.partitionKey}
+	}
+
+	err := t.client.DeleteItem(ctx, in)
+	if errors.Is(err, dynamo.ErrConditionalCheckFailed) {
+		if conds.version != nil {
+			return fmt.Errorf("%w: %s is not at version %d", ErrConflict, t.describe(partition, sort), *conds.version)
+		}
+		return fmt.Errorf("%w: %s", ErrNotFound, t.describe(partition, sort))
+	}
+	return err
+}
+
+// Query selects features in a partition, optionally narrowed to a sort key prefix or
+// an inclusive sort key range. Results are ordered by sort key.
+type Query struct {
+	Partition  feature.Key
+	SortPrefix feature.Key
+	SortFrom   feature.Key
+	SortTo     feature.Key
+	Descending bool
+	// Limit is the maximum number of features in a page, or zero for no limit.
+	Limit int
+	// Cursor continues the query after the last feature of a previous page.
+	Cursor string
+}
+
+// Query returns a page of the features matching q.
+func (t *Table) Query(ctx context.Context, q Query) (Page, error) {
+	if q.SortPrefix != "" && (q.SortFrom != "" || q.SortTo != "") {
+		return Page{}, fmt.Errorf("%w: query can not have both a sort key prefix and range", dynamo.ErrInvalidExpression)
+	}
+	in := dynamo.QueryInput{
+		TableName:                 t.name,
+		KeyConditionExpression:    "#pk = :pk",
+		ExpressionAttributeNames:  map[string]string{"#pk": t.partitionKey},
+		ExpressionAttributeValues: map[string]any{":pk": string(q.Partition)},
+		ScanIndexForward:          !q.Descending,
+		Limit:                     q.Limit,
+	}
+	if q.SortPrefix != "" || q.SortFrom != "" || q.SortTo != "" {
+		if t.sortKey == "" {
+			return Page{}, fmt.Errorf("%w: table %s has no sort key", dynamo.ErrInvalidExpression, t.name)
+		}
+		in.ExpressionAttributeNames["#sk"] = t.sortKey
+	}
+	switch {
+	case q.SortPrefix != "":
+		in.KeyConditionExpression += " AND begins_with(#sk, :prefix)"
+		in.ExpressionAttributeValues[":prefix"] = string(q.SortPrefix)
+	case q.SortFrom != "" && q.SortTo != "":
+		in.KeyConditionExpression += " AND #sk BETWEEN :from AND :to"
+		in.ExpressionAttributeValues[":from"] = string(q.SortFrom)
+		in.ExpressionAttributeValues[":to"] = string(q.SortTo)
+	case q.SortFrom != "":
+		in.KeyConditionExpression += " AND #sk >= :from"
+		in.ExpressionAttributeValues[":from"] = string(q.SortFrom)
+	case q.SortTo != "":
+		in.KeyConditionExpression += " AND #sk <= :to"
+		in.ExpressionAttributeValues[":to"] = string(q.SortTo)
+	}
+
+	return t.page(ctx, in, q.fingerprint(), q.Cursor)
+}
+
+func (q Query) fingerprint() string {
+	return fmt.Sprintf("partition %s prefix %s from %s to %s descending %t", q.Partition, q.SortPrefix, q.SortFrom, q.SortTo, q.Descending)
+}
+
+// QueryIndex returns a page of the features whose key in a secondary index starts with
+// q.Prefix. Every shard of the index is queried for a page, and the results are merged in
+// order of the index key.
+func (t *Table) QueryIndex(ctx context.Context, q IndexQuery) (Page, error) {
+	if !slices.Contains(t.secondaryIndexes(), q.Index) {
+		return Page{}, fmt.Errorf("%w: schema %s has no secondary index '%s'", feature.ErrInvalidIndex, t.schema.Schema, q.Index)
+	}
+	var position map[string]string
+	if q.Cursor != "" {
+		var err error
+		if position, err = t.cursors.Decode(q.fingerprint(), q.Cursor); err != nil {
+			return Page{}, err
+		}
+	}
+
+	partitionAttr := q.Index + IndexPartitionSuffix
+	more := false
+	var items []*jsonchamp.Map
+	for _, partition := range feature.ShardKeys(feature.Key(q.Index), t.indexShards) {
+		in := dynamo.QueryInput{
+			TableName:                 t.name,
+			IndexName:                 q.Index,
+			KeyConditionExpression:    "#partition = :partition",
+			ExpressionAttributeNames:  map[string]string{"#partition": partitionAttr},
+			ExpressionAttributeValues: map[string]any{":partition": string(partition)},
+			ScanIndexForward:          true,
+			Limit:                     q.Limit,
+		}
+		if q.Prefix != "" {
+			in.KeyConditionExpression += " AND begins_with(#key, :prefix)"
+			in.ExpressionAttributeNames["#key"] = q.Index
+			in.ExpressionAttributeValues[":prefix"] = string(q.Prefix)
+		}
+		if position != nil {
+			// The position is the last feature of the previous page, which is in at most
+			// one of the shards; every shard continues after it in index order.
+			in.ExclusiveStartKey = map[string]any{partitionAttr: string(partition)}
+			for k, v := range position {
+				in.ExclusiveStartKey[k] = v
+			}
+		}
+		out, err := t.client.Query(ctx, in)
+		if err != nil {
+			return Page{}, err
+		}
+		items = append(items, out.Items...)
+		more = more || out.LastEvaluatedKey != nil
+	}
+
+	order := func(item *jsonchamp.Map) []string {
+		var key []string
+		for _, attr := range []string{q.Index, t.partitionKey, t.sortKey} {
+			v, _ := item.Get(attr)
+			key = append(key, fmt.Sprint(v))
+		}
+		return key
+	}
+	slices.SortFunc(items, func(a, b *jsonchamp.Map) int { return slices.Compare(order(a), order(b)) })
+	if q.Limit > 0 && len(items) > q.Limit {
+		items = items[:q.Limit]
+		more = true
+	}
+
+	var page Page
+	for _, item := range items {
+		f, _, err := t.decode(item)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Features = append(page.Features, f)
+	}
+	if more && len(items) > 0 {
+		last := items[len(items)-1]
+		position := make(map[string]string)
+		for _, attr := range []string{t.partitionKey, t.sortKey, q.Index} {
+			if v, ok := last.Get(attr); ok && attr != "" {
+				position[attr] = fmt.Sprint(v)
+			}
+		}
+		var err error
+		if page.Cursor, err = t.cursors.Encode(q.fingerprint(), position); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// page runs a query for a single page, continuing after the cursor.
+func (t *Table) page(ctx context.Context, in dynamo.QueryInput, fingerprint string, cursor string) (Page, error) {
+	if cursor != "" {
+		position, err := t.cursors.Decode(fingerprint, cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		in.ExclusiveStartKey = make(map[string]any, len(position))
+		for k, v := range position {
+			in.ExclusiveStartKey[k] = v
+		}
+	}
+
+	out, err := t.client.Query(ctx, in)
+	if err != nil {
+		return Page{}, err
+	}
+	var page Page
+	for _, item := range out.Items {
+		f, _, err := t.decode(item)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Features = append(page.Features, f)
+	}
+	if out.LastEvaluatedKey != nil {
+		position := make(map[string]string, len(out.LastEvaluatedKey))
+		for k, v := range out.LastEvaluatedKey {
+			position[k] = fmt.Sprint(v)
+		}
+		if page.Cursor, err = t.cursors.Encode(fingerprint, position); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// condition renders write conditions as a condition expression.
+func (t *Table) condition(conds writeConditions) (string, map[string]string, map[string]any) {
+	switch {
+	case conds.notExists:
+		return "attribute_not_exists(#pk)", map[string]string{"#pk": t.partitionKey}, nil
+	case conds.version != nil:
+		return "#version = :version",
+			map[string]string{"#version": VersionAttribute},
+			map[string]any{":version": *conds.version}
+	default:
+		return "", nil, nil
+	}
+}
+
+func (t *Table) conflict(item *jsonchamp.Map, conds writeConditions) error {
+	key := t.itemKey(item)
+	partition, _ := key[t.partitionKey].(string)
+	sort, _ := key[t.sortKey].(string)
+	if conds.notExists {
+		return fmt.Errorf("%w: %s already exists", ErrConflict, t.describe(feature.Key(partition), feature.Key(sort)))
+	}
+	return fmt.Errorf("%w: %s is not at version %d", ErrConflict, t.describe(feature.Key(partition), feature.Key(sort)), *conds.version)
+}
+
+// shard returns the index shard of an item, from a hash of its primary key.
+func (t *Table) shard(item *jsonchamp.Map) int {
+	h := fnv.New64a()
+	for _, attr := range []string{t.partitionKey, t.sortKey} {
+		if v, ok := item.Get(attr); ok && attr != "" {
+			_, _ = fmt.Fprint(h, v)
+			_, _ = h.Write([]byte{0})
+		}
+	}
+	return int(h.Sum64() % uint64(t.indexShards))
+}
+
+func (t *Table) key(partition, sort feature.Key) map[string]any {
+	key := map[string]any{t.partitionKey: string(partition)}
+	if t.sortKey != "" {
+		key[t.sortKey] = string(sort)
+	}
+	return key
+}
+
+func (t *Table) itemKey(item *jsonchamp.Map) map[string]any {
+	key := make(map[string]any)
+	for _, attr := range []string{t.partitionKey, t.sortKey} {
+		if v, ok := item.Get(attr); ok && attr != "" {
+			key[attr] = v
+		}
+	}
+	return key
+}
+
+func (t *Table) describe(partition, sort feature.Key) string {
+	if t.sortKey == "" {
+		return string(partition)
+	}
+	return string(partition) + " " + string(sort)
+}
+
+func (t *Table) decode(item *jsonchamp.Map) (*feature.Feature, int64, error) {
+	payload, err := item.GetMap("payload")
+	if err != nil {
+		return nil, 0, fmt.Errorf("item in table %s has no payload: %w", t.name, err)
+	}
+	version, _ := item.GetInt(VersionAttribute)
+	schemaVersion, _ := item.GetInt(SchemaVersionAttribute)
+	f := feature.New(t.schema, feature.WithMap(payload), feature.WithSchemaVersion(int(schemaVersion)))
+	return f, version, nil
+}
@@ -0,0 +1,419 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/dynamo"
+	"github.com/mamaar/features/feature"
+)
+
+var orderLineSchema = feature.Schema{
+	Schema: "urn:features:order_line",
+	Migrations: feature.Migrations{
+		{
+			Description: "Initial schema",
+			Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "order_id", Type: feature.FieldTypeString, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "line", Type: feature.FieldTypeInteger, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddIndex{Index: feature.Index{Name: "pk", Parts: []feature.IndexPart{{Literal: "ORDER"}, {Property: "order_id"}}}},
+				feature.AddIndex{Index: feature.Index{Name: "sk", Parts: []feature.IndexPart{{Literal: "LINE"}, {Property: "line"}}}},
+			},
+		},
+	},
+}
+
+func newTestTable(t *testing.T) *Table {
+	t.Helper()
+	tbl, err := NewTable(dynamo.NewLocal(), "orders", orderLineSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.client.(*dynamo.Local).CreateTable(tbl.Definition()); err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func orderLine(orderID string, line int, sku string) *feature.Feature {
+	return feature.New(orderLineSchema, feature.WithMap(jsonchamp.NewFromItems("order_id", orderID, "line", line, "sku", sku)))
+}
+
+func lineKey(line int64) feature.Key {
+	return feature.Key("LINE:" + feature.EncodeIntKey(line))
+}
+
+func TestTableOptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	tbl := newTestTable(t)
+
+	f := orderLine("A1", 1, "apple")
+	version, err := tbl.Put(ctx, f, IfNotExists())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Fatalf("Put() version = %d, want 1", version)
+	}
+	if _, err := tbl.Put(ctx, orderLine("A1", 1, "pear"), IfNotExists()); !errors.Is(err, ErrConflict) {
+		t.Fatalf("second create error = %v, want %v", err, ErrConflict)
+	}
+
+	got, version, err := tbl.Get(ctx, "ORDER:A1", lineKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Set("sku", "banana")
+	if version, err = tbl.Put(ctx, got, IfVersion(version)); err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("Put() version = %d, want 2", version)
+	}
+	if _, err := tbl.Put(ctx, got, IfVersion(1)); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale update error = %v, want %v", err, ErrConflict)
+	}
+
+	// Unconditional writes still advance the version.
+	if version, err = tbl.Put(ctx, got); err != nil || version != 3 {
+		t.Fatalf("Put() = %d, %v, want 3", version, err)
+	}
+
+	if err := tbl.Delete(ctx, "ORDER:A1", lineKey(1), IfVersion(2)); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale delete error = %v, want %v", err, ErrConflict)
+	}
+	if err := tbl.Delete(ctx, "ORDER:A1", lineKey(1), IfVersion(3)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tbl.Get(ctx, "ORDER:A1", lineKey(1)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() after delete error = %v, want %v", err, ErrNotFound)
+	}
+	if err := tbl.Delete(ctx, "ORDER:A1", lineKey(1)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() of missing feature error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// interleavedClient runs a write of its own just before the first PutItem it passes on,
+// or before every one if repeat is set, as if another writer had come in between reading
+// and writing an item.
+type interleavedClient struct {
+	dynamo.Client
+	interleave func()
+	repeat     bool
+	puts       int
+}
+
+func (c *interleavedClient) PutItem(ctx context.Context, in dynamo.PutItemInput) error {
+	c.puts++
+	if interleave := c.interleave; interleave != nil {
+		if !c.repeat {
+			c.interleave = nil
+		}
+		interleave()
+	}
+	return c.Client.PutItem(ctx, in)
+}
+
+func TestTablePutConcurrentUnconditional(t *testing.T) {
+	ctx := context.Background()
+	tbl := newTestTable(t)
+	if _, err := tbl.Put(ctx, orderLine("A1", 1, "apple")); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &interleavedClient{Client: tbl.client}
+	racing := *tbl
+	racing.client = client
+	var other int64
+	client.interleave = func() {
+		var err error
+		if other, err = tbl.Put(ctx, orderLine("A1", 1, "pear")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	version, err := racing.Put(ctx, orderLine("A1", 1, "banana"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != 2 || version != 3 {
+		t.Fatalf("concurrent Put() versions = %d and %d, want 2 and 3", other, version)
+	}
+	got, stored, err := tbl.Get(ctx, "ORDER:A1", lineKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sku, _ := got.GetString("sku"); sku != "banana" || stored != 3 {
+		t.Fatalf("Get() = %s at version %d, want banana at version 3", sku, stored)
+	}
+}
+
+func TestTablePutGivesUp(t *testing.T) {
+	ctx := context.Background()
+	tbl := newTestTable(t)
+	if _, err := tbl.Put(ctx, orderLine("A1", 1, "apple")); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &interleavedClient{Client: tbl.client, repeat: true}
+	racing := *tbl
+	racing.client = client
+	racing.putAttempts = 3
+	client.interleave = func() {
+		if _, err := tbl.Put(ctx, orderLine("A1", 1, "pear")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := racing.Put(ctx, orderLine("A1", 1, "banana")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Put() error = %v, want %v", err, ErrConflict)
+	}
+	if client.puts != 3 {
+		t.Fatalf("Put() made %d attempts, want 3", client.puts)
+	}
+}
+
+// cancelingClient cancels the request after the first PutItem it passes on, and counts
+// the items it reads.
+type cancelingClient struct {
+	dynamo.Client
+	cancel context.CancelFunc
+	gets   int
+}
+
+func (c *cancelingClient) GetItem(ctx context.Context, in dynamo.GetItemInput) (*jsonchamp.Map, error) {
+	c.gets++
+	return c.Client.GetItem(ctx, in)
+}
+
+func (c *cancelingClient) PutItem(ctx context.Context, in dynamo.PutItemInput) error {
+	err := c.Client.PutItem(ctx, in)
+	c.cancel()
+	return err
+}
+
+func TestTablePutCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tbl := newTestTable(t)
+	if _, err := tbl.Put(ctx, orderLine("A1", 1, "apple")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another write comes in between, and the request is canceled before the put is
+	// retried.
+	interleaved := &interleavedClient{Client: tbl.client}
+	interleaved.interleave = func() {
+		if _, err := tbl.Put(context.Background(), orderLine("A1", 1, "pear")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := &cancelingClient{Client: interleaved, cancel: cancel}
+	racing := *tbl
+	racing.client = client
+
+	if _, err := racing.Put(ctx, orderLine("A1", 1, "banana")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Put() error = %v, want %v", err, context.Canceled)
+	}
+	if client.gets != 1 {
+		t.Fatalf("Put() read the item %d times, want 1", client.gets)
+	}
+}
+
+func TestTableQuery(t *testing.T) {
+	ctx := context.Background()
+	tbl := newTestTable(t)
+
+	for _, f := range []*feature.Feature{
+		orderLine("A1", 10, "apple"),
+		orderLine("A1", 2, "pear"),
+		orderLine("A1", 1, "plum"),
+		orderLine("A2", 1, "fig"),
+	} {
+		if _, err := tbl.Put(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{name: "partition", q: Query{Partition: "ORDER:A1"}, want: []string{"plum", "pear", "apple"}},
+		{name: "prefix", q: Query{Partition: "ORDER:A1", SortPrefix: "LINE:"}, want: []string{"plum", "pear", "apple"}},
+		{name: "range", q: Query{Partition: "ORDER:A1", SortFrom: lineKey(2), SortTo: lineKey(10)}, want: []string{"pear", "apple"}},
+		{name: "from", q: Query{Partition: "ORDER:A1", SortFrom: lineKey(2)}, want: []string{"pear", "apple"}},
+		{name: "descending with limit", q: Query{Partition: "ORDER:A1", Descending: true, Limit: 2}, want: []string{"apple", "pear"}},
+		{name: "other partition", q: Query{Partition: "ORDER:A2"}, want: []string{"fig"}},
+		{name: "empty partition", q: Query{Partition: "ORDER:A3"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tbl.Query(ctx, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range page.Features {
+				sku, _ := f.GetString("sku")
+				got = append(got, sku)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Query() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Query() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTableQueryCursor(t *testing.T) {
+	ctx := context.Background()
+	tbl := newTestTable(t)
+	for line := 1; line <= 5; line++ {
+		if _, err := tbl.Put(ctx, orderLine("A1", line, "sku")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := Query{Partition: "ORDER:A1", Descending: true, Limit: 2}
+	var lines []int64
+	for {
+		page, err := tbl.Query(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range page.Features {
+			line, _ := f.GetInt("line")
+			lines = append(lines, line)
+		}
+		if page.Cursor == "" {
+			break
+		}
+		q.Cursor = page.Cursor
+	}
+	if len(lines) != 5 || lines[0] != 5 || lines[4] != 1 {
+		t.Fatalf("Query() lines = %v, want 5 to 1", lines)
+	}
+
+	first, err := tbl.Query(ctx, Query{Partition: "ORDER:A1", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tbl.Query(ctx, Query{Partition: "ORDER:A2", Limit: 2, Cursor: first.Cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("Query() with cursor of another query error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestNewTableMissingIndex(t *testing.T) {
+	_, err := NewTable(dynamo.NewLocal(), "orders", orderLineSchema, WithKeyIndexes("gsi1", ""))
+	if !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("NewTable() error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+}
+
+func TestTableQueryIndex(t *testing.T) {
+	ctx := context.Background()
+	client := dynamo.NewLocal()
+	tbl, err := NewTable(client, "orders", customerOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateTable(tbl.Definition()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []*feature.Feature{
+		customerOrder("A3", 42),
+		customerOrder("A1", 42),
+		customerOrder("A2", 42),
+		customerOrder("B1", 7),
+	} {
+		if _, err := tbl.Put(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved, _, err := tbl.Get(ctx, "ORDER:A2", "META")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved.Set("customer_id", 7)
+	if _, err := tbl.Put(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Delete(ctx, "ORDER:A3", "META"); err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(prefix feature.Key) func(string) (Page, error) {
+		return func(cursor string) (Page, error) {
+			return tbl.QueryIndex(ctx, IndexQuery{Index: "gsi1", Prefix: prefix, Limit: 1, Cursor: cursor})
+		}
+	}
+	if ids, _ := orderIDs(t, query(customerPrefix(42))); !equalStrings(ids, []string{"A1"}) {
+		t.Fatalf("QueryIndex(42) = %v, want [A1]", ids)
+	}
+	ids, pages := orderIDs(t, query(customerPrefix(7)))
+	if !equalStrings(ids, []string{"A2", "B1"}) || pages != 2 {
+		t.Fatalf("QueryIndex(7) = %v in %d pages, want [A2 B1] in 2 pages", ids, pages)
+	}
+
+	if _, err := tbl.QueryIndex(ctx, IndexQuery{Index: "pk"}); !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("QueryIndex() on primary key error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+}
+
+func TestTableQueryIndexShards(t *testing.T) {
+	ctx := context.Background()
+	client := dynamo.NewLocal()
+	tbl, err := NewTable(client, "orders", customerOrderSchema, WithIndexShards(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateTable(tbl.Definition()); err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := range 20 {
+		id := fmt.Sprintf("A%02d", i)
+		want = append(want, id)
+		if _, err := tbl.Put(ctx, customerOrder(id, 42)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partitions := make(map[string]bool)
+	for _, id := range want {
+		item, err := client.GetItem(ctx, dynamo.GetItemInput{TableName: "orders", Key: map[string]any{"pk": "ORDER:" + id, "sk": "META"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		partition, _ := item.GetString("gsi1" + IndexPartitionSuffix)
+		partitions[partition] = true
+	}
+	if len(partitions) < 2 {
+		t.Fatalf("index entries are in partitions %v, want them spread over several", partitions)
+	}
+
+	ids, pages := orderIDs(t, func(cursor string) (Page, error) {
+		return tbl.QueryIndex(ctx, IndexQuery{Index: "gsi1", Prefix: customerPrefix(42), Limit: 3, Cursor: cursor})
+	})
+	if !equalStrings(ids, want) || pages != 7 {
+		t.Fatalf("QueryIndex(42) = %v in %d pages, want %v in 7 pages", ids, pages, want)
+	}
+
+	if _, err := NewTable(client, "orders", customerOrderSchema, WithIndexShards(0)); !errors.Is(err, feature.ErrInvalidIndex) {
+		t.Fatalf("NewTable() with no index shards error = %v, want %v", err, feature.ErrInvalidIndex)
+	}
+}
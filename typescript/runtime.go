@@ -0,0 +1,117 @@
+package typescript
+
+// runtime is written once at the top of every generated file. validate implements the
+// part of JSON Schema that Migrations.Reduce produces, and reports invalid values with
+// the same paths and messages as feature.Validator. Formats it does not know are
+// accepted, leaving them to the server.
+const runtime = `export interface ValidationError {
+  path: string[];
+  message: string;
+}
+
+export interface JSONSchema {
+  type?: string;
+  format?: string;
+  enum?: readonly unknown[];
+  minimum?: number;
+  maximum?: number;
+  pattern?: string;
+  properties?: { readonly [name: string]: JSONSchema };
+  required?: readonly string[];
+  items?: JSONSchema;
+}
+
+function isDate(value: string): boolean {
+  const m = /^(\d{4})-(\d{2})-(\d{2})$/.exec(value);
+  if (m === null) {
+    return false;
+  }
+  const date = new Date(Date.UTC(Number(m[1]), Number(m[2]) - 1, Number(m[3])));
+  return date.getUTCFullYear() === Number(m[1]) && date.getUTCMonth() === Number(m[2]) - 1 && date.getUTCDate() === Number(m[3]);
+}
+
+function isTime(value: string): boolean {
+  return /^([01]\d|2[0-3]):[0-5]\d:([0-5]\d|60)(\.\d+)?([Zz]|[+-]([01]\d|2[0-3]):[0-5]\d)$/.test(value);
+}
+
+const formats: { readonly [name: string]: (value: string) => boolean } = {
+  date: isDate,
+  time: isTime,
+  "date-time": (value) => {
+    const i = value.search(/[Tt]/);
+    return i === 10 && isDate(value.slice(0, i)) && isTime(value.slice(i + 1));
+  },
+  email: (value) => /^[^\s@]+@[^\s@]+$/.test(value),
+  uuid: (value) => /^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$/.test(value),
+  uri: (value) => /^[A-Za-z][A-Za-z0-9+.-]*:/.test(value),
+  ipv4: (value) => /^((25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)$/.test(value),
+};
+
+function typeOf(value: unknown): string {
+  if (value === null) {
+    return "null";
+  }
+  if (Array.isArray(value)) {
+    return "array";
+  }
+  return typeof value;
+}
+
+function hasType(value: unknown, type: string): boolean {
+  switch (type) {
+    case "integer":
+      return Number.isInteger(value);
+    case "number":
+      return typeof value === "number" && Number.isFinite(value);
+    default:
+      return typeOf(value) === type;
+  }
+}
+
+/** Returns the errors of a value against a schema, or an empty list if it is valid. */
+export function validate(schema: JSONSchema, value: unknown, path: string[] = []): ValidationError[] {
+  if (schema.type !== undefined && !hasType(value, schema.type)) {
+    return [{ path, message: "must be " + schema.type + ", got " + typeOf(value) }];
+  }
+
+  const errors: ValidationError[] = [];
+  if (schema.enum !== undefined && !schema.enum.includes(value)) {
+    errors.push({ path, message: "must be one of " + schema.enum.join(", ") });
+  }
+  if (typeof value === "number") {
+    if (schema.minimum !== undefined && value < schema.minimum) {
+      errors.push({ path, message: "must be at least " + schema.minimum });
+    }
+    if (schema.maximum !== undefined && value > schema.maximum) {
+      errors.push({ path, message: "must be at most " + schema.maximum });
+    }
+  }
+  if (typeof value === "string") {
+    if (schema.pattern !== undefined && !new RegExp(schema.pattern, "u").test(value)) {
+      errors.push({ path, message: "must match " + schema.pattern });
+    }
+    const format = schema.format !== undefined ? formats[schema.format] : undefined;
+    if (format !== undefined && !format(value)) {
+      errors.push({ path, message: "must be a valid " + schema.format });
+    }
+  }
+  if (typeOf(value) === "object") {
+    const object = value as { [name: string]: unknown };
+    for (const name of schema.required ?? []) {
+      if (!(name in object)) {
+        errors.push({ path: [...path, name], message: "is required" });
+      }
+    }
+    for (const [name, property] of Object.entries(schema.properties ?? {})) {
+      if (name in object) {
+        errors.push(...validate(property, object[name], [...path, name]));
+      }
+    }
+  }
+  if (schema.items !== undefined && Array.isArray(value)) {
+    const items = schema.items;
+    value.forEach((item, i) => errors.push(...validate(items, item, [...path, String(i)])));
+  }
+  return errors;
+}
+`
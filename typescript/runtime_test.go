@@ -0,0 +1,132 @@
+package typescript
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+// runTypeScript runs a TypeScript program with Node.js, which strips the types, and
+// returns what it prints. The test is skipped without a Node.js that can do so.
+func runTypeScript(t *testing.T, files map[string]string, main string, stdin string) []byte {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	if out, err := exec.Command(node, "--experimental-strip-types", "--no-warnings", "-e", "").CombinedOutput(); err != nil {
+		t.Skipf("node can not run TypeScript: %s", out)
+	}
+
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(node, "--experimental-strip-types", "--no-warnings", filepath.Join(dir, main))
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			t.Fatalf("node: %v\n%s", err, exitErr.Stderr)
+		}
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestValidateMatchesValidator(t *testing.T) {
+	one, ten, zero := 1.0, 10.0, 0.0
+	sch := feature.Schema{
+		Schema: "urn:features:shipment",
+		Migrations: feature.Migrations{
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true, Pattern: "^[A-Z]+$"}},
+				feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true, Minimum: &one, Maximum: &ten}},
+				feature.AddField{Field: feature.Field{Name: "weight", Type: feature.FieldTypeNumber, Minimum: &zero}},
+				feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString, Enum: []any{"open", "shipped"}}},
+				feature.AddField{Field: feature.Field{Name: "ship_on", Type: feature.FieldTypeString, Format: "date"}},
+				feature.AddField{Field: feature.Field{Name: "express", Type: feature.FieldTypeBoolean}},
+				feature.AddField{Field: feature.Field{Name: "ship-to", Type: feature.FieldTypeObject, Properties: []feature.Field{
+					{Name: "city", Type: feature.FieldTypeString, Required: true},
+				}}},
+				feature.AddField{Field: feature.Field{Name: "tags", Type: feature.FieldTypeArray, Items: &feature.Field{Type: feature.FieldTypeString}}},
+			}},
+		},
+	}
+	payloads := []string{
+		`{"sku": "A", "count": 1}`,
+		`{}`,
+		`{"sku": "abc", "count": 0, "weight": -0.5}`,
+		`{"sku": "A", "count": 11, "status": "lost", "ship_on": "2024-02-30"}`,
+		`{"sku": "A", "count": "two"}`,
+		`{"sku": "A", "count": 1.5}`,
+		`{"sku": 7, "count": 1, "express": "yes"}`,
+		`{"sku": "A", "count": 1, "ship-to": {}}`,
+		`{"sku": "A", "count": 1, "ship-to": "Oslo"}`,
+		`{"sku": "A", "count": 1, "tags": ["a", 2, true]}`,
+		`{"sku": "A", "count": 1, "tags": "a"}`,
+		`{"sku": "A", "count": 1, "status": 3}`,
+	}
+
+	validator, err := sch.ToJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want [][]string
+	for _, payload := range payloads {
+		m := jsonchamp.New()
+		if err := json.Unmarshal([]byte(payload), &m); err != nil {
+			t.Fatal(err)
+		}
+		messages := []string{}
+		err := validator.Validate(feature.New(sch, feature.WithMap(m)))
+		var verr *feature.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			for _, fe := range verr.Errors {
+				messages = append(messages, strings.Join(fe.Path, ".")+": "+fe.Message)
+			}
+		case err != nil:
+			t.Fatalf("Validate(%s) = %v", payload, err)
+		}
+		slices.Sort(messages)
+		want = append(want, messages)
+	}
+
+	src, err := Generate([]feature.Schema{sch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := runTypeScript(t, map[string]string{
+		"shipment.mts": string(src),
+		"main.mts": `import { readFileSync } from "node:fs";
+import { validateShipmentV1 } from "./shipment.mts";
+
+const payloads: unknown[] = JSON.parse(readFileSync(0, "utf8"));
+const messages = payloads.map((p) => validateShipmentV1(p).map((e) => e.path.join(".") + ": " + e.message).sort());
+console.log(JSON.stringify(messages));
+`,
+	}, "main.mts", "["+strings.Join(payloads, ",")+"]")
+
+	var got [][]string
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("decoding %s: %v", out, err)
+	}
+	for i, payload := range payloads {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("validating %s:\nTypeScript reports %q\nfeature.Validator reports %q", payload, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package typescript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mamaar/features/feature"
+	"github.com/mamaar/features/openapi"
+)
+
+var (
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// Generate returns a TypeScript module for the schemas. Types are named like the
+// components of openapi.Generate: every version of a schema becomes an interface such as
+// OrderV2, OrderV2Feature is its feature envelope, Order refers to the latest version
+// and OrderFeature is the envelope of any version.
+//
+// For every version the module also holds the JSON Schema of Migrations.Reduce, as
+// orderV2Schema, with validateOrderV2 returning the errors of a payload against it and
+// isOrderV2 narrowing a value to the interface. migrateOrderV1ToV2 upgrades a payload to
+// the next version the way Migrate does: it sets the defaults of added and altered fields
+// that have no value, and deletes removed fields, throwing an Error with the message of
+// RemoveField when a removed field is missing. migrateOrder upgrades a feature of any
+// version to the latest.
+func Generate(schemas []feature.Schema) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("// Code generated by github.com/mamaar/features/typescript. DO NOT EDIT.\n\n")
+	b.WriteString(runtime)
+
+	names := make(map[string]string)
+	for _, sch := range schemas {
+		name, err := openapi.ComponentName(sch.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("%w: no type name for URN %q", ErrInvalidSchema, sch.Schema)
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("%w: %q and %q are both named %s", ErrInvalidSchema, other, sch.Schema, name)
+		}
+		names[name] = sch.Schema
+		if err := writeSchema(&b, name, sch); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+func writeSchema(b *strings.Builder, name string, sch feature.Schema) error {
+	latest := len(sch.Migrations)
+	if latest == 0 {
+		return fmt.Errorf("%w: %q has no migrations", ErrInvalidSchema, sch.Schema)
+	}
+	urn, err := literal(sch.Schema)
+	if err != nil {
+		return err
+	}
+
+	var fields []feature.Field
+	for version := 1; version <= latest; version++ {
+		migration := sch.Migrations[version-1]
+		schema, err := reduce(sch.Migrations[:version])
+		if err != nil {
+			return fmt.Errorf("%w: %q version %d: %w", ErrInvalidSchema, sch.Schema, version, err)
+		}
+		fields = applyFields(fields, migration)
+
+		typeName := versionName(name, version)
+		deprecated := ""
+		if version < latest {
+			deprecated = " * @deprecated\n"
+		}
+		title := fmt.Sprintf("%s version %d", name, version)
+		if migration.Description != "" {
+			title += ": " + migration.Description
+		}
+
+		fmt.Fprintf(b, "\n/**\n * %s\n%s */\n", comment(title), deprecated)
+		fmt.Fprintf(b, "export interface %s %s\n", typeName, objectType(fields, ""))
+
+		fmt.Fprintf(b, "\n/**\n * The feature envelope of %s.\n%s */\n", typeName, deprecated)
+		fmt.Fprintf(b, "export interface %sFeature {\n  schema_urn: %s;\n  schema_version: %d;\n  payload: %s;\n}\n", typeName, urn, version, typeName)
+
+		fmt.Fprintf(b, "\nexport const %s: JSONSchema = %s;\n", schemaConst(typeName), schema)
+
+		fmt.Fprintf(b, "\nexport function validate%s(value: unknown): ValidationError[] {\n  return validate(%s, value);\n}\n", typeName, schemaConst(typeName))
+		fmt.Fprintf(b, "\nexport function is%s(value: unknown): value is %s {\n  return validate%s(value).length === 0;\n}\n", typeName, typeName, typeName)
+
+		if version > 1 {
+			if err := writeMigration(b, name, version, migration); err != nil {
+				return fmt.Errorf("%w: %q version %d: %w", ErrInvalidSchema, sch.Schema, version, err)
+			}
+		}
+	}
+
+	latestName := versionName(name, latest)
+	envelopes := make([]string, latest)
+	for version := 1; version <= latest; version++ {
+		envelopes[version-1] = versionName(name, version) + "Feature"
+	}
+	fmt.Fprintf(b, "\nexport type %s = %s;\n", name, latestName)
+	fmt.Fprintf(b, "\nexport type %sFeature = %s;\n", name, strings.Join(envelopes, " | "))
+
+	fmt.Fprintf(b, "\n/** Upgrades a feature of any version of %s to the latest version. */\n", name)
+	fmt.Fprintf(b, "export function migrate%s(feature: %sFeature): %sFeature {\n", name, name, latestName)
+	if latest == 1 {
+		b.WriteString("  return feature;\n}\n")
+		return nil
+	}
+	b.WriteString("  let payload: unknown = feature.payload;\n  switch (feature.schema_version) {\n")
+	for version := 1; version < latest; version++ {
+		fmt.Fprintf(b, "    case %d:\n      payload = migrate%sTo%s(payload as %s);\n    // falls through\n",
+			version, versionName(name, version), "V"+strconv.Itoa(version+1), versionName(name, version))
+	}
+	fmt.Fprintf(b, "  }\n  return { schema_urn: %s, schema_version: %d, payload: payload as %s };\n}\n", urn, latest, latestName)
+	return nil
+}
+
+// writeMigration writes the function upgrading a payload from the previous version to
+// the given one.
+func writeMigration(b *strings.Builder, name string, version int, migration *feature.Migration) error {
+	from, to := versionName(name, version-1), versionName(name, version)
+	fmt.Fprintf(b, "\n/** Upgrades a %s to %s. */\n", from, to)
+	fmt.Fprintf(b, "export function migrate%sTo%s(value: %s): %s {\n", from, "V"+strconv.Itoa(version), from, to)
+	b.WriteString("  const out: { [name: string]: unknown } = { ...value };\n")
+	for _, op := range migration.Operations {
+		switch op := op.(type) {
+		case feature.AddField:
+			if err := writeDefault(b, op.Field); err != nil {
+				return err
+			}
+		case feature.AlterField:
+			if err := writeDefault(b, op.Field); err != nil {
+				return err
+			}
+		case feature.RemoveField:
+			key, err := literal(op.FieldName)
+			if err != nil {
+				return err
+			}
+			msg, err := literal(fmt.Sprintf("field '%s' does not exist", op.FieldName))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "  if (!(%s in out)) {\n    throw new Error(%s);\n  }\n  delete out[%s];\n", key, msg, key)
+		}
+	}
+	fmt.Fprintf(b, "  return out as unknown as %s;\n}\n", to)
+	return nil
+}
+
+// writeDefault writes the statement setting the default of a field without a value, as
+// AddField.Apply and AlterField.Apply do.
+func writeDefault(b *strings.Builder, f feature.Field) error {
+	if f.Default == nil {
+		return nil
+	}
+	key, err := literal(f.Name)
+	if err != nil {
+		return err
+	}
+	def, err := literal(f.Default)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "  if (!(%s in out)) {\n    out[%s] = %s;\n  }\n", key, key, def)
+	return nil
+}
+
+// applyFields returns the fields after the migration, in the order they were added.
+func applyFields(fields []feature.Field, migration *feature.Migration) []feature.Field {
+	fields = slices.Clone(fields)
+	for _, op := range migration.Operations {
+		switch op := op.(type) {
+		case feature.AddField:
+			fields = append(fields, op.Field)
+		case feature.AlterField:
+			if i := slices.IndexFunc(fields, func(f feature.Field) bool { return f.Name == op.Field.Name }); i >= 0 {
+				fields[i] = op.Field
+			}
+		case feature.RemoveField:
+			fields = slices.DeleteFunc(fields, func(f feature.Field) bool { return f.Name == op.FieldName })
+		}
+	}
+	return fields
+}
+
+// reduce returns the JSON Schema of the migrations as a TypeScript literal.
+func reduce(migrations feature.Migrations) (string, error) {
+	reduced, err := migrations.Reduce()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(reduced)
+	if err != nil {
+		return "", err
+	}
+	var s map[string]any
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", err
+	}
+	s["type"] = "object"
+	// The top-level required fields come from a map; sort them for stable output.
+	if required, ok := s["required"].([]any); ok {
+		slices.SortFunc(required, func(a, b any) int { return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)) })
+	}
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func versionName(name string, version int) string {
+	return name + "V" + strconv.Itoa(version)
+}
+
+// schemaConst returns the name of the constant holding the JSON Schema of a version,
+// such as orderLineV2Schema for OrderLineV2.
+func schemaConst(typeName string) string {
+	return strings.ToLower(typeName[:1]) + typeName[1:] + "Schema"
+}
+
+// objectType returns the type of an object with the fields, with lines indented by
+// indent.
+func objectType(fields []feature.Field, indent string) string {
+	if len(fields) == 0 {
+		return "{ [name: string]: unknown }"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, f := range fields {
+		optional := "?"
+		if f.Required {
+			optional = ""
+		}
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, propertyName(f.Name), optional, fieldType(f, indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// fieldType returns the type of the values of a field.
+func fieldType(f feature.Field, indent string) string {
+	if len(f.Enum) > 0 {
+		values := make([]string, 0, len(f.Enum))
+		for _, v := range f.Enum {
+			if l, err := literal(v); err == nil {
+				values = append(values, l)
+			}
+		}
+		return strings.Join(values, " | ")
+	}
+	switch f.Type {
+	case feature.FieldTypeString:
+		return "string"
+	case feature.FieldTypeNumber, feature.FieldTypeInteger:
+		return "number"
+	case feature.FieldTypeBoolean:
+		return "boolean"
+	case feature.FieldTypeObject:
+		return objectType(f.Properties, indent)
+	case feature.FieldTypeArray:
+		if f.Items == nil {
+			return "Array<unknown>"
+		}
+		return "Array<" + fieldType(*f.Items, indent) + ">"
+	default:
+		return "unknown"
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func propertyName(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	l, _ := literal(name)
+	return l
+}
+
+// literal returns a value as a TypeScript literal.
+func literal(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	return string(data), nil
+}
+
+// comment escapes text for a line of a block comment.
+func comment(text string) string {
+	return strings.NewReplacer("*/", "*\\/", "\n", "\n * ").Replace(text)
+}
@@ -0,0 +1,161 @@
+package typescript
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mamaar/jsonchamp"
+
+	"github.com/mamaar/features/feature"
+)
+
+var orderSchema = feature.Schema{
+	Schema: "urn:features:order_line",
+	Migrations: feature.Migrations{
+		{Description: "Initial schema", Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+			feature.AddField{Field: feature.Field{Name: "note", Type: feature.FieldTypeString}},
+			feature.AddField{Field: feature.Field{Name: "status", Type: feature.FieldTypeString, Enum: []any{"open", "shipped"}}},
+		}},
+		{Description: "Add count, drop note", Operations: []feature.Operation{
+			feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true, Default: 1}},
+			feature.AddField{Field: feature.Field{Name: "ship-to", Type: feature.FieldTypeObject, Properties: []feature.Field{
+				{Name: "city", Type: feature.FieldTypeString, Required: true},
+			}}},
+			feature.AddField{Field: feature.Field{Name: "tags", Type: feature.FieldTypeArray, Items: &feature.Field{Type: feature.FieldTypeString}}},
+			feature.RemoveField{FieldName: "note"},
+		}},
+	},
+}
+
+func TestGenerate(t *testing.T) {
+	out, err := Generate([]feature.Schema{orderSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(out)
+
+	for _, want := range []string{
+		"export function validate(schema: JSONSchema",
+		"/**\n * OrderLine version 1: Initial schema\n * @deprecated\n */\nexport interface OrderLineV1 {\n  sku: string;\n  note?: string;\n  status?: \"open\" | \"shipped\";\n}\n",
+		"export interface OrderLineV2 {\n  sku: string;\n  status?: \"open\" | \"shipped\";\n  count: number;\n  \"ship-to\"?: {\n    city: string;\n  };\n  tags?: Array<string>;\n}\n",
+		"  schema_urn: \"urn:features:order_line\";\n  schema_version: 2;\n  payload: OrderLineV2;\n",
+		"export const orderLineV2Schema: JSONSchema = {",
+		"\"required\": [\n    \"count\",\n    \"sku\"\n  ],",
+		"export function isOrderLineV2(value: unknown): value is OrderLineV2 {",
+		"export function migrateOrderLineV1ToV2(value: OrderLineV1): OrderLineV2 {\n  const out: { [name: string]: unknown } = { ...value };\n  if (!(\"count\" in out)) {\n    out[\"count\"] = 1;\n  }\n  if (!(\"note\" in out)) {\n    throw new Error(\"field 'note' does not exist\");\n  }\n  delete out[\"note\"];\n",
+		"export type OrderLine = OrderLineV2;",
+		"export type OrderLineFeature = OrderLineV1Feature | OrderLineV2Feature;",
+		"    case 1:\n      payload = migrateOrderLineV1ToV2(payload as OrderLineV1);\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected the output to contain\n%s\ngot\n%s", want, src)
+		}
+	}
+	_, v2, _ := strings.Cut(src, "orderLineV2Schema")
+	if strings.Contains(v2, "\"note\": {") {
+		t.Errorf("expected note to be removed from the schema of the second version")
+	}
+}
+
+func TestGenerate_InvalidSchema(t *testing.T) {
+	cases := map[string]feature.Schema{
+		"no migrations": {Schema: "urn:features:empty"},
+		"no name":       {Schema: "urn:features:", Migrations: orderSchema.Migrations},
+		"unreducible": {Schema: "urn:features:broken", Migrations: feature.Migrations{
+			{Operations: []feature.Operation{feature.RemoveField{FieldName: "missing"}}},
+		}},
+	}
+	for name, sch := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Generate([]feature.Schema{sch}); !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("expected ErrInvalidSchema, got %v", err)
+			}
+		})
+	}
+
+	if _, err := Generate([]feature.Schema{orderSchema, {Schema: "urn:other:order_line", Migrations: orderSchema.Migrations}}); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected schemas with the same name to be rejected, got %v", err)
+	}
+}
+
+func TestMigrateMatchesMigrate(t *testing.T) {
+	var zero int64
+	sch := feature.Schema{
+		Schema: "urn:features:parcel",
+		Migrations: feature.Migrations{
+			{Operations: []feature.Operation{
+				feature.AddField{Field: feature.Field{Name: "sku", Type: feature.FieldTypeString, Required: true}},
+				feature.AddField{Field: feature.Field{Name: "note", Type: feature.FieldTypeString}},
+				feature.AddField{Field: feature.Field{Name: "qty", Type: feature.FieldTypeString}},
+			}},
+			{Operations: []feature.Operation{
+				feature.RemoveField{FieldName: "note"},
+				feature.AddField{Field: feature.Field{Name: "count", Type: feature.FieldTypeInteger, Required: true, Default: 1}},
+				feature.AlterField{Field: feature.Field{Name: "qty", Type: feature.FieldTypeInteger, Default: zero}},
+			}},
+		},
+	}
+	payloads := []string{
+		`{"sku": "A", "note": "fragile"}`,
+		`{"sku": "A", "note": "fragile", "count": 5, "qty": "3"}`,
+		`{"sku": "A"}`,
+	}
+
+	// Every result is the migrated payload, or the error of the migration.
+	var want []any
+	for _, payload := range payloads {
+		m := jsonchamp.New()
+		if err := json.Unmarshal([]byte(payload), &m); err != nil {
+			t.Fatal(err)
+		}
+		f := feature.New(sch, feature.WithMap(m), feature.WithSchemaVersion(1))
+		if err := f.Migrate(sch); err != nil {
+			want = append(want, err.Error())
+			continue
+		}
+		data, err := json.Marshal(f.Map())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, v)
+	}
+
+	src, err := Generate([]feature.Schema{sch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := runTypeScript(t, map[string]string{
+		"parcel.mts": string(src),
+		"main.mts": `import { readFileSync } from "node:fs";
+import { migrateParcel } from "./parcel.mts";
+
+const payloads: any[] = JSON.parse(readFileSync(0, "utf8"));
+const results = payloads.map((payload) => {
+  try {
+    return migrateParcel({ schema_urn: "urn:features:parcel", schema_version: 1, payload }).payload;
+  } catch (e) {
+    return (e as Error).message;
+  }
+});
+console.log(JSON.stringify(results));
+`,
+	}, "main.mts", "["+strings.Join(payloads, ",")+"]")
+
+	var got []any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("decoding %s: %v", out, err)
+	}
+	for i, payload := range payloads {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("migrating %s:\nTypeScript returns %v\nMigrate returns %v", payload, got[i], want[i])
+		}
+	}
+}